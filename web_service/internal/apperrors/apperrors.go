@@ -27,6 +27,21 @@ var (
 		Code:     "ENV_PARSE_ERR",
 		HTTPCode: http.StatusInternalServerError,
 	}
+	ConfigFlagsErr = AppError{
+		Message:  "Failed to parse command-line flags",
+		Code:     "CONFIG_FLAGS_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ConfigFileLoadErr = AppError{
+		Message:  "Failed to load config file",
+		Code:     "CONFIG_FILE_LOAD_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ConfigValidationErr = AppError{
+		Message:  "Invalid configuration",
+		Code:     "CONFIG_VALIDATION_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
 	InitPostgressErr = AppError{
 		Message:  "Failed to InitPostgress",
 		Code:     "INIT_CLIENT_POSTGRESS_ERR",
@@ -42,16 +57,46 @@ var (
 		Code:     "DATABASE_POSTGRES_ERR",
 		HTTPCode: http.StatusInternalServerError,
 	}
+	TracingSetupErr = AppError{
+		Message:  "Failed to SetupTracing",
+		Code:     "TRACING_SETUP_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	MigrationErr = AppError{
+		Message:  "Failed to Migrate",
+		Code:     "DATABASE_MIGRATION_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
 	//REPO
 	CreateUserErr = AppError{
 		Message:  "Failed to CreateUser",
 		Code:     "User_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	GetUserByIDErr = AppError{
+		Message:  "Failed to GetUserByIDErr",
+		Code:     "User_REPO",
 		HTTPCode: http.StatusNotFound,
 	}
+	GetUserLecturesErr = AppError{
+		Message:  "Failed to GetUserLecturesErr",
+		Code:     "User_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	GetUsersErr = AppError{
+		Message:  "Failed to GetUsersErr",
+		Code:     "User_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	InvalidCursorErr = AppError{
+		Message:  "Invalid pagination cursor",
+		Code:     "REPO_CURSOR",
+		HTTPCode: http.StatusBadRequest,
+	}
 	CreateLectureErr = AppError{
 		Message:  "Failed to CreateLecture",
 		Code:     "Lecture_REPO",
-		HTTPCode: http.StatusNotFound,
+		HTTPCode: http.StatusInternalServerError,
 	}
 	AddStudentToLectureRepoErr = AppError{
 		Message:  "Failed to AddStudentToLectureErr",
@@ -68,6 +113,126 @@ var (
 		Code:     "Lecture_REPO",
 		HTTPCode: http.StatusNotFound,
 	}
+	GetLectureByIDErr = AppError{
+		Message:  "Failed to GetLectureByIDErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusNotFound,
+	}
+	GetLectureStudentsErr = AppError{
+		Message:  "Failed to GetLectureStudentsErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusNotFound,
+	}
+	UpdateLectureStatusErr = AppError{
+		Message:  "Failed to UpdateLectureStatusErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusConflict,
+	}
+	GetLecturesStartingBetweenErr = AppError{
+		Message:  "Failed to GetLecturesStartingBetweenErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	CountLectureStudentsErr = AppError{
+		Message:  "Failed to CountLectureStudentsErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	UpdateLectureErr = AppError{
+		Message:  "Failed to UpdateLectureErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	DeleteLectureErr = AppError{
+		Message:  "Failed to DeleteLectureErr",
+		Code:     "Lecture_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	LectureHasStudentsErr = AppError{
+		Message:  "Lecture has enrolled students, cancel it instead",
+		Code:     "Lecture_REPO_HAS_STUDENTS",
+		HTTPCode: http.StatusConflict,
+	}
+	UpdateUserErr = AppError{
+		Message:  "Failed to UpdateUserErr",
+		Code:     "User_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	DeleteUserErr = AppError{
+		Message:  "Failed to DeleteUserErr",
+		Code:     "User_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	StaleVersionErr = AppError{
+		Message:  "Resource has been modified since it was read",
+		Code:     "REPO_STALE_VERSION",
+		HTTPCode: http.StatusPreconditionFailed,
+	}
+	IdempotencyRepoErr = AppError{
+		Message:  "Failed to store idempotency key",
+		Code:     "Idempotency_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	AuditRepoErr = AppError{
+		Message:  "Failed to AuditRepoErr",
+		Code:     "Audit_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	OutboxRepoErr = AppError{
+		Message:  "Failed to OutboxRepoErr",
+		Code:     "Outbox_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	OutboxPublisherErr = AppError{
+		Message:  "Failed to publish domain event",
+		Code:     "Outbox_PUBLISHER",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	WebhookRepoErr = AppError{
+		Message:  "Failed to WebhookRepoErr",
+		Code:     "Webhook_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	WebhookSubscriptionNotFoundErr = AppError{
+		Message:  "Webhook subscription not found",
+		Code:     "Webhook_REPO",
+		HTTPCode: http.StatusNotFound,
+	}
+	WebhookDeliveryNotFoundErr = AppError{
+		Message:  "Webhook delivery not found",
+		Code:     "Webhook_REPO",
+		HTTPCode: http.StatusNotFound,
+	}
+	WebhookDeliveryNotDeadErr = AppError{
+		Message:  "Only dead webhook deliveries can be retried",
+		Code:     "Webhook_REPO",
+		HTTPCode: http.StatusConflict,
+	}
+	WebhookDeliveryErr = AppError{
+		Message:  "Failed to deliver webhook",
+		Code:     "Webhook_DELIVERY",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	WebhookSignatureErr = AppError{
+		Message:  "Invalid webhook signature",
+		Code:     "Webhook_SIGNATURE",
+		HTTPCode: http.StatusUnauthorized,
+	}
+	NotificationRepoErr = AppError{
+		Message:  "Failed to NotificationRepoErr",
+		Code:     "Notification_REPO",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	NotificationTemplateErr = AppError{
+		Message:  "Failed to render notification",
+		Code:     "Notification_TEMPLATE",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	NotifierErr = AppError{
+		Message:  "Failed to send notification",
+		Code:     "Notification_NOTIFIER",
+		HTTPCode: http.StatusInternalServerError,
+	}
 	//HANDLERS
 	CreateUserHandlerErr = AppError{
 		Message:  "Failed to createUserHandlerErr",
@@ -94,6 +259,96 @@ var (
 		Code:     "Server_handlers",
 		HTTPCode: http.StatusBadRequest,
 	}
+	GetLectureStudentsHandlerErr = AppError{
+		Message:  "Failed to getLectureStudentsHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	GetUserLecturesHandlerErr = AppError{
+		Message:  "Failed to getUserLecturesHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	RateLimitedErr = AppError{
+		Message:  "Too many requests",
+		Code:     "RATE_LIMITED",
+		HTTPCode: http.StatusTooManyRequests,
+	}
+	RateLimitStoreErr = AppError{
+		Message:  "Failed to check the rate limit",
+		Code:     "RATE_LIMIT_STORE_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	UnauthenticatedErr = AppError{
+		Message:  "Authentication required",
+		Code:     "Server_handlers_UNAUTHENTICATED",
+		HTTPCode: http.StatusUnauthorized,
+	}
+	ForbiddenErr = AppError{
+		Message:  "Not allowed",
+		Code:     "Server_handlers_FORBIDDEN",
+		HTTPCode: http.StatusForbidden,
+	}
+	ChangeLectureStatusHandlerErr = AppError{
+		Message:  "Failed to changeLectureStatusHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	PreconditionRequiredErr = AppError{
+		Message:  "If-Match header is required",
+		Code:     "Server_handlers_PRECONDITION_REQUIRED",
+		HTTPCode: http.StatusPreconditionRequired,
+	}
+	GetLectureHandlerErr = AppError{
+		Message:  "Failed to getLectureHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	UpdateLectureHandlerErr = AppError{
+		Message:  "Failed to updateLectureHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	DeleteLectureHandlerErr = AppError{
+		Message:  "Failed to deleteLectureHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	GetUserHandlerErr = AppError{
+		Message:  "Failed to getUserHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	UpdateUserHandlerErr = AppError{
+		Message:  "Failed to updateUserHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	DeleteUserHandlerErr = AppError{
+		Message:  "Failed to deleteUserHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	CreateWebhookHandlerErr = AppError{
+		Message:  "Failed to createWebhookHandlerErr",
+		Code:     "Server_handlers",
+		HTTPCode: http.StatusBadRequest,
+	}
+	IdempotencyKeyInvalidErr = AppError{
+		Message:  "Invalid Idempotency-Key",
+		Code:     "Server_handlers_IDEMPOTENCY_KEY",
+		HTTPCode: http.StatusBadRequest,
+	}
+	IdempotencyKeyReusedErr = AppError{
+		Message:  "Idempotency-Key was already used for a different request",
+		Code:     "Server_handlers_IDEMPOTENCY_KEY_REUSED",
+		HTTPCode: http.StatusUnprocessableEntity,
+	}
+	IdempotencyKeyInProgressErr = AppError{
+		Message:  "A request with this Idempotency-Key is still being processed",
+		Code:     "Server_handlers_IDEMPOTENCY_KEY_IN_PROGRESS",
+		HTTPCode: http.StatusConflict,
+	}
 	//SERVICES
 	CreateLectureServiceErr = AppError{
 		Message:  "Failed to CreateLectureServiceErr",
@@ -115,6 +370,86 @@ var (
 		Code:     "Lecture_Service",
 		HTTPCode: http.StatusInternalServerError,
 	}
+	LectureNotBookableErr = AppError{
+		Message:  "Lecture is not open for enrollment",
+		Code:     "Lecture_Service_NOT_BOOKABLE",
+		HTTPCode: http.StatusConflict,
+	}
+	GetLectureStudentsServiceErr = AppError{
+		Message:  "Failed to GetLectureStudentsServiceErr",
+		Code:     "Lecture_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	InvalidLecturesQueryErr = AppError{
+		Message:  "Invalid lectures query",
+		Code:     "Lecture_Service_QUERY",
+		HTTPCode: http.StatusBadRequest,
+	}
+	ChangeLectureStatusServiceErr = AppError{
+		Message:  "Failed to ChangeLectureStatusServiceErr",
+		Code:     "Lecture_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	LectureStatusTransitionErr = AppError{
+		Message:  "Lecture status transition is not allowed",
+		Code:     "Lecture_Service_STATUS_TRANSITION",
+		HTTPCode: http.StatusConflict,
+	}
+	GetLectureServiceErr = AppError{
+		Message:  "Failed to GetLectureServiceErr",
+		Code:     "Lecture_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	UpdateLectureServiceErr = AppError{
+		Message:  "Failed to UpdateLectureServiceErr",
+		Code:     "Lecture_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	DeleteLectureServiceErr = AppError{
+		Message:  "Failed to DeleteLectureServiceErr",
+		Code:     "Lecture_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	InvalidPageRequestErr = AppError{
+		Message:  "Invalid pagination parameters",
+		Code:     "Service_PAGINATION",
+		HTTPCode: http.StatusBadRequest,
+	}
+	GetUserLecturesServiceErr = AppError{
+		Message:  "Failed to GetUserLecturesServiceErr",
+		Code:     "User_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	GetUserServiceErr = AppError{
+		Message:  "Failed to GetUserServiceErr",
+		Code:     "User_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	UpdateUserServiceErr = AppError{
+		Message:  "Failed to UpdateUserServiceErr",
+		Code:     "User_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	DeleteUserServiceErr = AppError{
+		Message:  "Failed to DeleteUserServiceErr",
+		Code:     "User_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	GetAuditEventsServiceErr = AppError{
+		Message:  "Failed to GetAuditEventsServiceErr",
+		Code:     "Audit_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	CreateWebhookServiceErr = AppError{
+		Message:  "Failed to CreateWebhookServiceErr",
+		Code:     "Webhook_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
+	WebhookServiceErr = AppError{
+		Message:  "Failed to WebhookServiceErr",
+		Code:     "Webhook_Service",
+		HTTPCode: http.StatusBadRequest,
+	}
 	CreateUserServiceErr = AppError{
 		Message:  "Failed to CreateUserServiceErr",
 		Code:     "User_Service",
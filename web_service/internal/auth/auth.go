@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// UserIDHeader carries the ID of the caller. The service sits behind a gateway
+// that authenticates users and sets it, so it is only believed on requests
+// coming from the gateway's addresses; anyone else could send any ID.
+const UserIDHeader = "X-User-ID"
+
+type contextKey struct{}
+
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(contextKey{}).(uuid.UUID)
+	return userID, ok
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Networks is a set of addresses, such as the proxies in front of the service.
+type Networks []netip.Prefix
+
+// ParseNetworks accepts IPs and CIDR ranges.
+func ParseNetworks(addresses []string) (Networks, error) {
+	networks := Networks{}
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if !strings.Contains(address, "/") {
+			addr, err := netip.ParseAddr(address)
+			if err != nil {
+				return nil, err
+			}
+
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(address)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, prefix.Masked())
+	}
+
+	return networks, nil
+}
+
+// Contains reports whether ip, which may carry a port, is in one of the
+// networks.
+func (networks Networks) Contains(ip string) bool {
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range networks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
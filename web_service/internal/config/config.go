@@ -1,49 +1,384 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"os"
+	"time"
 	"web_service/internal/apperrors"
+	"web_service/internal/auth"
 
 	"github.com/caarlos0/env"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
-var path = "configs/.env"
+const defaultEnvFile = "configs/.env"
 
+// Config is assembled from, in increasing order of precedence: the defaults
+// below, the YAML file passed with -config, the .env file and finally the
+// process environment. A field missing from a source keeps the value of the
+// previous one.
 type Config struct {
-	AppPort  string `required:"true" split_words:"true"`
-	Postgres *PostgresConfig
-}
-
-type PostgresConfig struct {
-	AppPort      string `env:"APP_PORT"`
-	LogLevel     string `env:"LOGGER_LEVEL"`
-	SqlHost      string `env:"SQL_HOST"`
-	SqlPort      string `env:"SQL_PORT"`
-	SqlType      string `env:"SQL_TYPE"`
-	SqlMode      string `env:"SQL_MODE"`
-	UserName     string `env:"USER_NAME"`
-	Password     string `env:"PASSWORD"`
-	DBName       string `env:"DB_NAME"`
-	TimeZone     string `env:"TIME_ZONE"`
-	TimeoutQuery string `env:"TIMEOUT_QUERY"`
-}
-
-func NewConfig(logger *zap.Logger) (*Config, error) {
-	err := godotenv.Load(path)
-	if err != nil {
+	HTTP          *HTTPConfig          `yaml:"http"`
+	DB            *DBConfig            `yaml:"db"`
+	Auth          *AuthConfig          `yaml:"auth"`
+	CORS          *CORSConfig          `yaml:"cors"`
+	RateLimit     *RateLimitConfig     `yaml:"rate_limit"`
+	Idempotency   *IdempotencyConfig   `yaml:"idempotency"`
+	Audit         *AuditConfig         `yaml:"audit"`
+	Outbox        *OutboxConfig        `yaml:"outbox"`
+	Webhooks      *WebhooksConfig      `yaml:"webhooks"`
+	Notifications *NotificationsConfig `yaml:"notifications"`
+	Logging       *LoggingConfig       `yaml:"logging"`
+	Tracing       *TracingConfig       `yaml:"tracing"`
+}
+
+// HTTPConfig configures the listener. On shutdown /readyz fails for
+// ShutdownDelay before the listener closes, so load balancers stop sending
+// traffic first, and in-flight requests then get ShutdownTimeout to finish.
+// MaxBodyBytes caps a JSON request body; Strict-Transport-Security is only
+// sent while HSTSMaxAge is positive, since it is only meaningful behind TLS.
+type HTTPConfig struct {
+	Port              string        `env:"APP_PORT" yaml:"port"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" yaml:"write_timeout"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" yaml:"idle_timeout"`
+	ShutdownDelay     time.Duration `env:"HTTP_SHUTDOWN_DELAY" yaml:"shutdown_delay"`
+	ShutdownTimeout   time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+	MaxBodyBytes      int64         `env:"HTTP_MAX_BODY_BYTES" yaml:"max_body_bytes"`
+	HSTSMaxAge        time.Duration `env:"HTTP_HSTS_MAX_AGE" yaml:"hsts_max_age"`
+}
+
+// DBConfig describes the Postgres connection and its pool. On startup the
+// connection is attempted up to ConnectAttempts times, waiting ConnectBackoff
+// after the first failure and doubling it up to ConnectBackoffMax; each ping
+// is bounded by TimeoutQuery seconds. ReplicaDSNs are optional postgres://
+// URLs of read replicas, pinged every ReplicaHealthInterval; commas inside a
+// DSN must be escaped as %2C.
+type DBConfig struct {
+	Host                  string        `env:"SQL_HOST" yaml:"host"`
+	Port                  string        `env:"SQL_PORT" yaml:"port"`
+	Type                  string        `env:"SQL_TYPE" yaml:"type"`
+	SSLMode               string        `env:"SQL_MODE" yaml:"sslmode"`
+	User                  string        `env:"USER_NAME" yaml:"user"`
+	Password              string        `env:"PASSWORD" yaml:"password"`
+	Name                  string        `env:"DB_NAME" yaml:"name"`
+	TimeZone              string        `env:"TIME_ZONE" yaml:"time_zone"`
+	TimeoutQuery          int           `env:"TIMEOUT_QUERY" yaml:"timeout_query_seconds"`
+	MaxOpenConns          int           `env:"DB_MAX_OPEN_CONNS" yaml:"max_open_conns"`
+	MaxIdleConns          int           `env:"DB_MAX_IDLE_CONNS" yaml:"max_idle_conns"`
+	ConnMaxLifetime       time.Duration `env:"DB_CONN_MAX_LIFETIME" yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime       time.Duration `env:"DB_CONN_MAX_IDLE_TIME" yaml:"conn_max_idle_time"`
+	ConnectAttempts       int           `env:"DB_CONNECT_ATTEMPTS" yaml:"connect_attempts"`
+	ConnectBackoff        time.Duration `env:"DB_CONNECT_BACKOFF" yaml:"connect_backoff"`
+	ConnectBackoffMax     time.Duration `env:"DB_CONNECT_BACKOFF_MAX" yaml:"connect_backoff_max"`
+	ReplicaDSNs           []string      `env:"DB_REPLICA_DSNS" envSeparator:"," yaml:"replica_dsns"`
+	ReplicaHealthInterval time.Duration `env:"DB_REPLICA_HEALTH_INTERVAL" yaml:"replica_health_interval"`
+}
+
+// AuthConfig names the header the gateway sets to the authenticated user ID.
+// The header is only accepted from the gateway's own addresses,
+// TrustedProxies (IPs or CIDRs); while that is empty every caller is
+// anonymous. Rate limiting believes X-Forwarded-For from the same addresses.
+type AuthConfig struct {
+	UserIDHeader   string   `env:"AUTH_USER_ID_HEADER" yaml:"user_id_header"`
+	TrustedProxies []string `env:"AUTH_TRUSTED_PROXIES" envSeparator:"," yaml:"trusted_proxies"`
+}
+
+// CORSConfig lets browsers on AllowedOrigins call the API. CORS is off while
+// AllowedOrigins is empty; "*" allows any origin but can't be combined with
+// AllowCredentials. The user ID header is the gateway's to set, not a
+// browser's, so it is left out of the default AllowedHeaders.
+type CORSConfig struct {
+	AllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" envSeparator:"," yaml:"allowed_origins"`
+	AllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" envSeparator:"," yaml:"allowed_methods"`
+	AllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" envSeparator:"," yaml:"allowed_headers"`
+	ExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" envSeparator:"," yaml:"exposed_headers"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" yaml:"allow_credentials"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" yaml:"max_age"`
+}
+
+// RateLimitConfig sets a token bucket per route group: signup is POST /users,
+// enrollment adds and removes students, default covers the other API routes.
+// Callers are keyed by the user ID accepted from the gateway, or by client IP
+// where X-Forwarded-For is only trusted from the auth TrustedProxies.
+type RateLimitConfig struct {
+	Enabled             bool `env:"RATE_LIMIT_ENABLED" yaml:"enabled"`
+	DefaultPerMinute    int  `env:"RATE_LIMIT_DEFAULT_PER_MINUTE" yaml:"default_per_minute"`
+	DefaultBurst        int  `env:"RATE_LIMIT_DEFAULT_BURST" yaml:"default_burst"`
+	SignupPerMinute     int  `env:"RATE_LIMIT_SIGNUP_PER_MINUTE" yaml:"signup_per_minute"`
+	SignupBurst         int  `env:"RATE_LIMIT_SIGNUP_BURST" yaml:"signup_burst"`
+	EnrollmentPerMinute int  `env:"RATE_LIMIT_ENROLLMENT_PER_MINUTE" yaml:"enrollment_per_minute"`
+	EnrollmentBurst     int  `env:"RATE_LIMIT_ENROLLMENT_BURST" yaml:"enrollment_burst"`
+}
+
+// IdempotencyConfig says how long the response to a request sent with an
+// Idempotency-Key is kept for replay, and how often expired ones are purged.
+// A key whose request hasn't finished after LockTimeout, because its instance
+// died, can be claimed by a retry.
+type IdempotencyConfig struct {
+	TTL             time.Duration `env:"IDEMPOTENCY_TTL" yaml:"ttl"`
+	LockTimeout     time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" yaml:"lock_timeout"`
+	CleanupInterval time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" yaml:"cleanup_interval"`
+}
+
+// AuditConfig keys the fingerprints that stand in for emails in the audit log.
+// FingerprintKey is an HMAC key, at least 32 bytes, so the fingerprints can't
+// be reversed by hashing candidate addresses. Changing it means fingerprints
+// from before and after can no longer be compared.
+type AuditConfig struct {
+	FingerprintKey string `env:"AUDIT_FINGERPRINT_KEY" yaml:"fingerprint_key"`
+}
+
+// OutboxConfig drives the relay that publishes domain events from the outbox.
+// Publisher is log, memory or webhook, which POSTs every event to WebhookURL.
+// The relay polls every PollInterval and keeps a claimed batch hidden from
+// other instances for Lease, which must outlast publishing it. A failed event
+// is retried after RetryBackoff, doubling up to RetryBackoffMax, until it
+// goes through; published events are deleted once older than Retention.
+type OutboxConfig struct {
+	Publisher       string        `env:"OUTBOX_PUBLISHER" yaml:"publisher"`
+	WebhookURL      string        `env:"OUTBOX_WEBHOOK_URL" yaml:"webhook_url"`
+	WebhookTimeout  time.Duration `env:"OUTBOX_WEBHOOK_TIMEOUT" yaml:"webhook_timeout"`
+	PollInterval    time.Duration `env:"OUTBOX_POLL_INTERVAL" yaml:"poll_interval"`
+	BatchSize       int           `env:"OUTBOX_BATCH_SIZE" yaml:"batch_size"`
+	Lease           time.Duration `env:"OUTBOX_LEASE" yaml:"lease"`
+	RetryBackoff    time.Duration `env:"OUTBOX_RETRY_BACKOFF" yaml:"retry_backoff"`
+	RetryBackoffMax time.Duration `env:"OUTBOX_RETRY_BACKOFF_MAX" yaml:"retry_backoff_max"`
+	Retention       time.Duration `env:"OUTBOX_RETENTION" yaml:"retention"`
+}
+
+// WebhooksConfig drives the delivery of events to webhook subscriptions. Each
+// attempt may take up to Timeout; pending deliveries are polled every
+// PollInterval and a claimed batch of BatchSize stays hidden from other
+// instances for Lease, which must cover the whole batch. A failed delivery is
+// retried after RetryBackoff, doubling up to RetryBackoffMax, and is
+// dead-lettered after MaxAttempts. Subscriptions may only point at public
+// addresses unless AllowPrivateNetworks is set.
+type WebhooksConfig struct {
+	Timeout         time.Duration `env:"WEBHOOKS_TIMEOUT" yaml:"timeout"`
+	PollInterval    time.Duration `env:"WEBHOOKS_POLL_INTERVAL" yaml:"poll_interval"`
+	BatchSize       int           `env:"WEBHOOKS_BATCH_SIZE" yaml:"batch_size"`
+	Lease           time.Duration `env:"WEBHOOKS_LEASE" yaml:"lease"`
+	RetryBackoff    time.Duration `env:"WEBHOOKS_RETRY_BACKOFF" yaml:"retry_backoff"`
+	RetryBackoffMax time.Duration `env:"WEBHOOKS_RETRY_BACKOFF_MAX" yaml:"retry_backoff_max"`
+	MaxAttempts     int           `env:"WEBHOOKS_MAX_ATTEMPTS" yaml:"max_attempts"`
+
+	AllowPrivateNetworks bool `env:"WEBHOOKS_ALLOW_PRIVATE_NETWORKS" yaml:"allow_private_networks"`
+}
+
+// NotificationsConfig drives the emails queued for students. Notifier is log,
+// which only logs them, or smtp, which sends them from From through
+// SMTPHost:SMTPPort, authenticating when SMTPUsername is set. Emails use the
+// student's locale, or DefaultLocale when it has no templates. The queue is
+// polled like the webhook deliveries; a failed email is retried until
+// MaxAttempts. Every ReminderInterval, students of lectures starting within
+// ReminderLead are queued a reminder.
+type NotificationsConfig struct {
+	Notifier         string        `env:"NOTIFICATIONS_NOTIFIER" yaml:"notifier"`
+	From             string        `env:"NOTIFICATIONS_FROM" yaml:"from"`
+	DefaultLocale    string        `env:"NOTIFICATIONS_DEFAULT_LOCALE" yaml:"default_locale"`
+	SMTPHost         string        `env:"NOTIFICATIONS_SMTP_HOST" yaml:"smtp_host"`
+	SMTPPort         string        `env:"NOTIFICATIONS_SMTP_PORT" yaml:"smtp_port"`
+	SMTPUsername     string        `env:"NOTIFICATIONS_SMTP_USERNAME" yaml:"smtp_username"`
+	SMTPPassword     string        `env:"NOTIFICATIONS_SMTP_PASSWORD" yaml:"smtp_password"`
+	SMTPTimeout      time.Duration `env:"NOTIFICATIONS_SMTP_TIMEOUT" yaml:"smtp_timeout"`
+	PollInterval     time.Duration `env:"NOTIFICATIONS_POLL_INTERVAL" yaml:"poll_interval"`
+	BatchSize        int           `env:"NOTIFICATIONS_BATCH_SIZE" yaml:"batch_size"`
+	Lease            time.Duration `env:"NOTIFICATIONS_LEASE" yaml:"lease"`
+	RetryBackoff     time.Duration `env:"NOTIFICATIONS_RETRY_BACKOFF" yaml:"retry_backoff"`
+	RetryBackoffMax  time.Duration `env:"NOTIFICATIONS_RETRY_BACKOFF_MAX" yaml:"retry_backoff_max"`
+	MaxAttempts      int           `env:"NOTIFICATIONS_MAX_ATTEMPTS" yaml:"max_attempts"`
+	ReminderLead     time.Duration `env:"NOTIFICATIONS_REMINDER_LEAD" yaml:"reminder_lead"`
+	ReminderInterval time.Duration `env:"NOTIFICATIONS_REMINDER_INTERVAL" yaml:"reminder_interval"`
+}
+
+// LoggingConfig builds the application logger. Encoding is json or console;
+// sampling is off while SamplingInitial is 0, and logs are only written to
+// File, rotated by size, when it is set. RedactFields lists the field names,
+// Go or json, masked whenever a DTO is logged.
+type LoggingConfig struct {
+	Level              string   `env:"LOGGER_LEVEL" yaml:"level"`
+	Encoding           string   `env:"LOG_ENCODING" yaml:"encoding"`
+	SamplingInitial    int      `env:"LOG_SAMPLING_INITIAL" yaml:"sampling_initial"`
+	SamplingThereafter int      `env:"LOG_SAMPLING_THEREAFTER" yaml:"sampling_thereafter"`
+	File               string   `env:"LOG_FILE" yaml:"file"`
+	FileMaxSizeMB      int      `env:"LOG_FILE_MAX_SIZE_MB" yaml:"file_max_size_mb"`
+	FileMaxBackups     int      `env:"LOG_FILE_MAX_BACKUPS" yaml:"file_max_backups"`
+	FileMaxAgeDays     int      `env:"LOG_FILE_MAX_AGE_DAYS" yaml:"file_max_age_days"`
+	FileCompress       bool     `env:"LOG_FILE_COMPRESS" yaml:"file_compress"`
+	RedactFields       []string `env:"LOG_REDACT_FIELDS" envSeparator:"," yaml:"redact_fields"`
+}
+
+// TracingConfig picks the span exporter: none, stdout or otlp. The OTLP
+// exporter reads its endpoint from the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	Exporter    string  `env:"TRACING_EXPORTER" yaml:"exporter"`
+	ServiceName string  `env:"TRACING_SERVICE_NAME" yaml:"service_name"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" yaml:"sample_ratio"`
+}
+
+func Default() *Config {
+	return &Config{
+		HTTP: &HTTPConfig{
+			Port:              "8081",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownDelay:     5 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			MaxBodyBytes:      1 << 20,
+		},
+		DB: &DBConfig{
+			Host:                  "localhost",
+			Port:                  "5432",
+			Type:                  "postgres",
+			SSLMode:               "disable",
+			TimeZone:              "UTC",
+			TimeoutQuery:          15,
+			MaxOpenConns:          25,
+			MaxIdleConns:          25,
+			ConnMaxLifetime:       30 * time.Minute,
+			ConnMaxIdleTime:       5 * time.Minute,
+			ConnectAttempts:       10,
+			ConnectBackoff:        500 * time.Millisecond,
+			ConnectBackoffMax:     10 * time.Second,
+			ReplicaHealthInterval: 5 * time.Second,
+		},
+		Auth: &AuthConfig{
+			UserIDHeader: auth.UserIDHeader,
+		},
+		CORS: &CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "X-Request-ID", "Idempotency-Key", "If-Match", "If-None-Match"},
+			ExposedHeaders: []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Deprecation", "Sunset", "Link", "Idempotent-Replayed", "ETag"},
+			MaxAge:         10 * time.Minute,
+		},
+		RateLimit: &RateLimitConfig{
+			Enabled:             true,
+			DefaultPerMinute:    600,
+			DefaultBurst:        100,
+			SignupPerMinute:     5,
+			SignupBurst:         5,
+			EnrollmentPerMinute: 30,
+			EnrollmentBurst:     10,
+		},
+		Idempotency: &IdempotencyConfig{
+			TTL:             24 * time.Hour,
+			LockTimeout:     time.Minute,
+			CleanupInterval: time.Hour,
+		},
+		Audit: &AuditConfig{},
+		Outbox: &OutboxConfig{
+			Publisher:       "log",
+			WebhookTimeout:  10 * time.Second,
+			PollInterval:    time.Second,
+			BatchSize:       100,
+			Lease:           time.Minute,
+			RetryBackoff:    time.Second,
+			RetryBackoffMax: 5 * time.Minute,
+			Retention:       7 * 24 * time.Hour,
+		},
+		Webhooks: &WebhooksConfig{
+			Timeout:         10 * time.Second,
+			PollInterval:    time.Second,
+			BatchSize:       10,
+			Lease:           2 * time.Minute,
+			RetryBackoff:    10 * time.Second,
+			RetryBackoffMax: time.Hour,
+			MaxAttempts:     10,
+		},
+		Notifications: &NotificationsConfig{
+			Notifier:         "log",
+			From:             "School <noreply@localhost>",
+			DefaultLocale:    "en",
+			SMTPPort:         "587",
+			SMTPTimeout:      10 * time.Second,
+			PollInterval:     time.Second,
+			BatchSize:        50,
+			Lease:            time.Minute,
+			RetryBackoff:     30 * time.Second,
+			RetryBackoffMax:  time.Hour,
+			MaxAttempts:      8,
+			ReminderLead:     24 * time.Hour,
+			ReminderInterval: 15 * time.Minute,
+		},
+		Logging: &LoggingConfig{
+			Level:              "info",
+			Encoding:           "console",
+			SamplingThereafter: 100,
+			FileMaxSizeMB:      100,
+			FileMaxBackups:     5,
+			FileMaxAgeDays:     30,
+			RedactFields:       []string{"password", "email", "secret"},
+		},
+		Tracing: &TracingConfig{
+			Exporter:    "none",
+			ServiceName: "serviceschool",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Sources are the files configuration is read from, set with command-line
+// flags. An empty File means no YAML file; the .env file is optional unless
+// its path was given explicitly.
+type Sources struct {
+	File            string
+	EnvFile         string
+	EnvFileExplicit bool
+}
+
+func ParseFlags(args []string) (*Sources, error) {
+	sources := &Sources{}
+	flags := flag.NewFlagSet("serviceschool", flag.ContinueOnError)
+	flags.StringVar(&sources.File, "config", "", "path to a YAML config file")
+	flags.StringVar(&sources.EnvFile, "env-file", defaultEnvFile, "path to a .env file")
+	if err := flags.Parse(args); err != nil {
+		return nil, apperrors.ConfigFlagsErr.AppendMessage(err)
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		sources.EnvFileExplicit = sources.EnvFileExplicit || f.Name == "env-file"
+	})
+
+	return sources, nil
+}
+
+func NewConfig(logger *zap.Logger, sources *Sources) (*Config, error) {
+	conf := Default()
+	if sources.File != "" {
+		content, err := os.ReadFile(sources.File)
+		if err != nil {
+			return nil, apperrors.ConfigFileLoadErr.AppendMessage(err)
+		}
+
+		if err := yaml.Unmarshal(content, conf); err != nil {
+			return nil, apperrors.ConfigFileLoadErr.AppendMessage(sources.File, err)
+		}
+	}
+
+	// godotenv never overrides variables that are already set, which is what
+	// puts the real environment above the .env file.
+	err := godotenv.Load(sources.EnvFile)
+	if err != nil && (sources.EnvFileExplicit || !errors.Is(err, os.ErrNotExist)) {
 		appErr := apperrors.EnvConfigLoadError.AppendMessage(err)
 		return nil, appErr
 	}
 
-	confPsql := PostgresConfig{}
-	if err := env.Parse(&confPsql); err != nil {
+	if err := env.Parse(conf); err != nil {
 		appErr := apperrors.EnvConfigParseError.AppendMessage(err)
 		return nil, appErr
 	}
 
-	conf := Config{AppPort: confPsql.AppPort, Postgres: &confPsql}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
 
 	logger.Sugar().Info("Config has been parsed")
-	return &conf, nil
+	return conf, nil
 }
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestNewConfigPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+http:
+  port: "9000"
+  read_timeout: 7s
+  write_timeout: 8s
+db:
+  user: yaml_user
+  password: yaml_password
+  name: yaml_db
+audit:
+  fingerprint_key: yaml-fingerprint-key-of-32-bytes!
+`)
+	envFile := writeFile(t, ".env", "HTTP_READ_TIMEOUT=9s\nHTTP_WRITE_TIMEOUT=10s\n")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "11s")
+
+	sources, err := ParseFlags([]string{"-config", yamlFile, "-env-file", envFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := NewConfig(zap.NewNop(), sources)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "9000", conf.HTTP.Port, "YAML over defaults")
+		assert.Equal(t, 9*time.Second, conf.HTTP.ReadTimeout, ".env over YAML")
+		assert.Equal(t, 11*time.Second, conf.HTTP.WriteTimeout, "environment over .env")
+		assert.Equal(t, 60*time.Second, conf.HTTP.IdleTimeout, "default kept when no source sets it")
+		assert.Equal(t, "yaml_db", conf.DB.Name)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	testTable := []struct {
+		scenario        string
+		args            []string
+		file            string
+		envFile         string
+		envFileExplicit bool
+		wantErr         bool
+	}{
+		{"no_flags", nil, "", defaultEnvFile, false, false},
+		{"config_and_env_file", []string{"-config", "a.yaml", "-env-file", "b.env"}, "a.yaml", "b.env", true, false},
+		{"unknown_flag", []string{"-port", "1"}, "", "", false, true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			sources, err := ParseFlags(tc.args)
+			if tc.wantErr {
+				assert.True(t, apperrors.IsAppError(err, &apperrors.ConfigFlagsErr))
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, &Sources{File: tc.file, EnvFile: tc.envFile, EnvFileExplicit: tc.envFileExplicit}, sources)
+			}
+		})
+	}
+}
+
+func TestNewConfigMissingEnvFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.env")
+	t.Setenv("USER_NAME", "user")
+	t.Setenv("PASSWORD", "password")
+	t.Setenv("DB_NAME", "db")
+	t.Setenv("AUDIT_FINGERPRINT_KEY", strings.Repeat("k", 32))
+
+	_, err := NewConfig(zap.NewNop(), &Sources{EnvFile: missing})
+	assert.NoError(t, err, "a missing default .env file is fine")
+
+	_, err = NewConfig(zap.NewNop(), &Sources{EnvFile: missing, EnvFileExplicit: true})
+	assert.True(t, apperrors.IsAppError(err, &apperrors.EnvConfigLoadError))
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	conf := Default()
+	conf.DB.User, conf.DB.Password, conf.DB.Name = "user", "password", "db"
+	conf.Audit.FingerprintKey = strings.Repeat("k", 32)
+	assert.NoError(t, conf.Validate())
+
+	conf.HTTP.Port = "http"
+	conf.HTTP.ShutdownDelay = -time.Second
+	conf.DB.SSLMode = "sometimes"
+	conf.Logging.Level = "loud"
+	conf.Webhooks.BatchSize = 100
+	conf.Audit.FingerprintKey = "short"
+	err := conf.Validate()
+	if assert.True(t, apperrors.IsAppError(err, &apperrors.ConfigValidationErr)) {
+		for _, field := range []string{"http.port", "http.shutdown_delay", "db.sslmode", "audit.fingerprint_key", "logging.level", "webhooks.lease"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	}
+}
+
+func TestNewConfigEnvParseErrorSkipsValidation(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", "http:\n  port: not-a-port\n")
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+
+	_, err := NewConfig(zap.NewNop(), &Sources{File: yamlFile, EnvFile: filepath.Join(t.TempDir(), "missing.env")})
+	if assert.True(t, apperrors.IsAppError(err, &apperrors.EnvConfigParseError)) {
+		assert.Contains(t, err.Error(), `"soon"`)
+		assert.False(t, strings.Contains(err.Error(), "http.port"), "validation must not run on a half-parsed config")
+	}
+}
+
+func TestDefaultCORSLeavesOutUserIDHeader(t *testing.T) {
+	for _, header := range Default().CORS.AllowedHeaders {
+		assert.NotEqual(t, strings.ToLower(auth.UserIDHeader), strings.ToLower(header))
+	}
+}
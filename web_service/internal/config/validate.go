@@ -0,0 +1,294 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+const minFingerprintKeyBytes = 32
+
+// Validate reports every invalid field at once rather than stopping at the
+// first, so a broken deployment can be fixed in one go.
+func (conf *Config) Validate() error {
+	if conf.HTTP == nil || conf.DB == nil || conf.Auth == nil || conf.CORS == nil || conf.RateLimit == nil || conf.Idempotency == nil || conf.Audit == nil || conf.Outbox == nil || conf.Webhooks == nil || conf.Notifications == nil || conf.Logging == nil || conf.Tracing == nil {
+		return apperrors.ConfigValidationErr.AppendMessage("http, db, auth, cors, rate_limit, idempotency, audit, outbox, webhooks, notifications, logging and tracing sections must not be empty")
+	}
+
+	problems := []string{}
+	invalid := func(field string, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if !isPort(conf.HTTP.Port) {
+		invalid("http.port", "must be a port number, got %q", conf.HTTP.Port)
+	}
+
+	timeouts := []struct {
+		field   string
+		timeout time.Duration
+	}{
+		{"http.read_timeout", conf.HTTP.ReadTimeout},
+		{"http.read_header_timeout", conf.HTTP.ReadHeaderTimeout},
+		{"http.write_timeout", conf.HTTP.WriteTimeout},
+		{"http.idle_timeout", conf.HTTP.IdleTimeout},
+		{"http.shutdown_timeout", conf.HTTP.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.timeout <= 0 {
+			invalid(timeout.field, "must be positive, got %v", timeout.timeout)
+		}
+	}
+
+	if conf.HTTP.ShutdownDelay < 0 {
+		invalid("http.shutdown_delay", "must not be negative, got %v", conf.HTTP.ShutdownDelay)
+	}
+
+	if conf.HTTP.MaxBodyBytes <= 0 {
+		invalid("http.max_body_bytes", "must be positive, got %d", conf.HTTP.MaxBodyBytes)
+	}
+
+	if conf.HTTP.HSTSMaxAge < 0 {
+		invalid("http.hsts_max_age", "must not be negative, got %v", conf.HTTP.HSTSMaxAge)
+	}
+
+	if conf.DB.Host == "" {
+		invalid("db.host", "is required")
+	}
+
+	if !isPort(conf.DB.Port) {
+		invalid("db.port", "must be a port number, got %q", conf.DB.Port)
+	}
+
+	if conf.DB.User == "" {
+		invalid("db.user", "is required")
+	}
+
+	if conf.DB.Name == "" {
+		invalid("db.name", "is required")
+	}
+
+	if !oneOf(conf.DB.SSLMode, sslModes...) {
+		invalid("db.sslmode", "must be one of %v, got %q", sslModes, conf.DB.SSLMode)
+	}
+
+	if !oneOf(conf.DB.Type, "postgres", "postgresql") {
+		invalid("db.type", "must be postgres or postgresql, got %q", conf.DB.Type)
+	}
+
+	if conf.DB.TimeoutQuery <= 0 {
+		invalid("db.timeout_query_seconds", "must be positive, got %d", conf.DB.TimeoutQuery)
+	}
+
+	if conf.DB.MaxOpenConns < 0 || conf.DB.MaxIdleConns < 0 || conf.DB.ConnMaxLifetime < 0 || conf.DB.ConnMaxIdleTime < 0 {
+		invalid("db.pool", "settings must not be negative")
+	}
+
+	if conf.DB.ConnectAttempts < 1 {
+		invalid("db.connect_attempts", "must be at least 1, got %d", conf.DB.ConnectAttempts)
+	}
+
+	if conf.DB.ConnectBackoff <= 0 || conf.DB.ConnectBackoffMax < conf.DB.ConnectBackoff {
+		invalid("db.connect_backoff", "must be positive and not above connect_backoff_max, got %v and %v", conf.DB.ConnectBackoff, conf.DB.ConnectBackoffMax)
+	}
+
+	for i, dsn := range conf.DB.ReplicaDSNs {
+		parsed, err := url.Parse(dsn)
+		if err != nil || !oneOf(parsed.Scheme, "postgres", "postgresql") || parsed.Host == "" {
+			invalid(fmt.Sprintf("db.replica_dsns[%d]", i), "must be a postgres:// URL")
+		}
+	}
+
+	if len(conf.DB.ReplicaDSNs) > 0 && conf.DB.ReplicaHealthInterval <= 0 {
+		invalid("db.replica_health_interval", "must be positive, got %v", conf.DB.ReplicaHealthInterval)
+	}
+
+	if conf.Auth.UserIDHeader == "" {
+		invalid("auth.user_id_header", "is required")
+	}
+
+	for i, proxy := range conf.Auth.TrustedProxies {
+		if _, err := auth.ParseNetworks([]string{proxy}); err != nil {
+			invalid(fmt.Sprintf("auth.trusted_proxies[%d]", i), "must be an IP or CIDR, got %q", proxy)
+		}
+	}
+
+	for i, origin := range conf.CORS.AllowedOrigins {
+		if origin == "*" {
+			if conf.CORS.AllowCredentials {
+				invalid("cors.allowed_origins", "\"*\" can't be combined with allow_credentials")
+			}
+
+			continue
+		}
+
+		parsed, err := url.Parse(origin)
+		if err != nil || !oneOf(parsed.Scheme, "http", "https") || parsed.Host == "" || strings.TrimPrefix(origin, parsed.Scheme+"://") != parsed.Host {
+			invalid(fmt.Sprintf("cors.allowed_origins[%d]", i), "must be a scheme://host[:port] origin or *, got %q", origin)
+		}
+	}
+
+	if len(conf.CORS.AllowedOrigins) > 0 && len(conf.CORS.AllowedMethods) == 0 {
+		invalid("cors.allowed_methods", "must not be empty while CORS is enabled")
+	}
+
+	if conf.CORS.MaxAge < 0 {
+		invalid("cors.max_age", "must not be negative, got %v", conf.CORS.MaxAge)
+	}
+
+	rateLimits := []struct {
+		group     string
+		perMinute int
+		burst     int
+	}{
+		{"default", conf.RateLimit.DefaultPerMinute, conf.RateLimit.DefaultBurst},
+		{"signup", conf.RateLimit.SignupPerMinute, conf.RateLimit.SignupBurst},
+		{"enrollment", conf.RateLimit.EnrollmentPerMinute, conf.RateLimit.EnrollmentBurst},
+	}
+	for _, rateLimit := range rateLimits {
+		if conf.RateLimit.Enabled && (rateLimit.perMinute <= 0 || rateLimit.burst <= 0) {
+			invalid("rate_limit."+rateLimit.group, "per_minute and burst must be positive, got %d and %d", rateLimit.perMinute, rateLimit.burst)
+		}
+	}
+
+	if conf.Idempotency.TTL <= 0 || conf.Idempotency.CleanupInterval <= 0 {
+		invalid("idempotency", "ttl and cleanup_interval must be positive, got %v and %v", conf.Idempotency.TTL, conf.Idempotency.CleanupInterval)
+	}
+
+	if conf.Idempotency.LockTimeout < conf.HTTP.WriteTimeout || conf.Idempotency.LockTimeout > conf.Idempotency.TTL {
+		invalid("idempotency.lock_timeout", "must be between http.write_timeout and ttl, got %v", conf.Idempotency.LockTimeout)
+	}
+
+	if len(conf.Audit.FingerprintKey) < minFingerprintKeyBytes {
+		invalid("audit.fingerprint_key", "must be at least %d bytes, got %d", minFingerprintKeyBytes, len(conf.Audit.FingerprintKey))
+	}
+
+	if !oneOf(conf.Outbox.Publisher, "log", "memory", "webhook") {
+		invalid("outbox.publisher", "must be log, memory or webhook, got %q", conf.Outbox.Publisher)
+	}
+
+	if conf.Outbox.Publisher == "webhook" {
+		parsed, err := url.Parse(conf.Outbox.WebhookURL)
+		if err != nil || !oneOf(parsed.Scheme, "http", "https") || parsed.Host == "" {
+			invalid("outbox.webhook_url", "must be an http(s) URL while the webhook publisher is used, got %q", conf.Outbox.WebhookURL)
+		}
+
+		if conf.Outbox.WebhookTimeout <= 0 || conf.Outbox.WebhookTimeout >= conf.Outbox.Lease {
+			invalid("outbox.webhook_timeout", "must be positive and below lease, got %v and %v", conf.Outbox.WebhookTimeout, conf.Outbox.Lease)
+		}
+	}
+
+	if conf.Outbox.PollInterval <= 0 || conf.Outbox.Lease <= 0 || conf.Outbox.Retention <= 0 {
+		invalid("outbox", "poll_interval, lease and retention must be positive, got %v, %v and %v", conf.Outbox.PollInterval, conf.Outbox.Lease, conf.Outbox.Retention)
+	}
+
+	if conf.Outbox.BatchSize <= 0 {
+		invalid("outbox.batch_size", "must be positive, got %d", conf.Outbox.BatchSize)
+	}
+
+	if conf.Outbox.RetryBackoff <= 0 || conf.Outbox.RetryBackoffMax < conf.Outbox.RetryBackoff {
+		invalid("outbox.retry_backoff", "must be positive and not above retry_backoff_max, got %v and %v", conf.Outbox.RetryBackoff, conf.Outbox.RetryBackoffMax)
+	}
+
+	if conf.Webhooks.Timeout <= 0 {
+		invalid("webhooks.timeout", "must be positive, got %v", conf.Webhooks.Timeout)
+	}
+
+	// A batch is delivered one at a time, so it must be done before its lease
+	// runs out and another instance claims it as well.
+	if conf.Webhooks.BatchSize > 0 && time.Duration(conf.Webhooks.BatchSize)*conf.Webhooks.Timeout >= conf.Webhooks.Lease {
+		invalid("webhooks.lease", "must exceed batch_size × timeout, got %v for %d × %v", conf.Webhooks.Lease, conf.Webhooks.BatchSize, conf.Webhooks.Timeout)
+	}
+
+	if conf.Webhooks.PollInterval <= 0 {
+		invalid("webhooks.poll_interval", "must be positive, got %v", conf.Webhooks.PollInterval)
+	}
+
+	if conf.Webhooks.BatchSize <= 0 || conf.Webhooks.MaxAttempts <= 0 {
+		invalid("webhooks", "batch_size and max_attempts must be positive, got %d and %d", conf.Webhooks.BatchSize, conf.Webhooks.MaxAttempts)
+	}
+
+	if conf.Webhooks.RetryBackoff <= 0 || conf.Webhooks.RetryBackoffMax < conf.Webhooks.RetryBackoff {
+		invalid("webhooks.retry_backoff", "must be positive and not above retry_backoff_max, got %v and %v", conf.Webhooks.RetryBackoff, conf.Webhooks.RetryBackoffMax)
+	}
+
+	if !oneOf(conf.Notifications.Notifier, "log", "smtp") {
+		invalid("notifications.notifier", "must be log or smtp, got %q", conf.Notifications.Notifier)
+	}
+
+	if _, err := mail.ParseAddress(conf.Notifications.From); err != nil {
+		invalid("notifications.from", "must be an email address, got %q", conf.Notifications.From)
+	}
+
+	if conf.Notifications.Notifier == "smtp" {
+		if conf.Notifications.SMTPHost == "" || conf.Notifications.SMTPPort == "" {
+			invalid("notifications.smtp_host", "and smtp_port must be set while the smtp notifier is used")
+		}
+
+		if conf.Notifications.SMTPTimeout <= 0 || conf.Notifications.SMTPTimeout >= conf.Notifications.Lease {
+			invalid("notifications.smtp_timeout", "must be positive and below lease, got %v and %v", conf.Notifications.SMTPTimeout, conf.Notifications.Lease)
+		}
+	}
+
+	if conf.Notifications.PollInterval <= 0 || conf.Notifications.ReminderInterval <= 0 || conf.Notifications.ReminderLead <= 0 {
+		invalid("notifications", "poll_interval, reminder_interval and reminder_lead must be positive, got %v, %v and %v", conf.Notifications.PollInterval, conf.Notifications.ReminderInterval, conf.Notifications.ReminderLead)
+	}
+
+	if conf.Notifications.BatchSize <= 0 || conf.Notifications.MaxAttempts <= 0 {
+		invalid("notifications", "batch_size and max_attempts must be positive, got %d and %d", conf.Notifications.BatchSize, conf.Notifications.MaxAttempts)
+	}
+
+	if conf.Notifications.RetryBackoff <= 0 || conf.Notifications.RetryBackoffMax < conf.Notifications.RetryBackoff {
+		invalid("notifications.retry_backoff", "must be positive and not above retry_backoff_max, got %v and %v", conf.Notifications.RetryBackoff, conf.Notifications.RetryBackoffMax)
+	}
+
+	if _, err := zapcore.ParseLevel(conf.Logging.Level); err != nil {
+		invalid("logging.level", "unknown level %q", conf.Logging.Level)
+	}
+
+	if !oneOf(conf.Logging.Encoding, "json", "console") {
+		invalid("logging.encoding", "must be json or console, got %q", conf.Logging.Encoding)
+	}
+
+	if conf.Logging.SamplingInitial < 0 || conf.Logging.SamplingThereafter < 0 {
+		invalid("logging.sampling", "must not be negative")
+	}
+
+	if !oneOf(conf.Tracing.Exporter, "none", "stdout", "otlp") {
+		invalid("tracing.exporter", "must be none, stdout or otlp, got %q", conf.Tracing.Exporter)
+	}
+
+	if conf.Tracing.SampleRatio < 0 || conf.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "must be between 0 and 1, got %v", conf.Tracing.SampleRatio)
+	}
+
+	if len(problems) > 0 {
+		return apperrors.ConfigValidationErr.AppendMessage(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func isPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"net"
+	"net/url"
+	"web_service/internal/config"
+)
+
+// dsnURL builds the connection URL with every part escaped, so passwords and
+// names containing @, / or ? can't break it. TimeZone is passed to pgx as a
+// session parameter.
+func dsnURL(conf *config.DBConfig) *url.URL {
+	query := url.Values{}
+	query.Set("sslmode", conf.SSLMode)
+	if conf.TimeZone != "" {
+		query.Set("TimeZone", conf.TimeZone)
+	}
+
+	return &url.URL{
+		Scheme:   conf.Type,
+		User:     url.UserPassword(conf.User, conf.Password),
+		Host:     net.JoinHostPort(conf.Host, conf.Port),
+		Path:     "/" + conf.Name,
+		RawQuery: query.Encode(),
+	}
+}
+
+func BuildDSN(conf *config.DBConfig) string {
+	return dsnURL(conf).String()
+}
+
+// RedactedDSN is BuildDSN with the password masked, for logs.
+func RedactedDSN(conf *config.DBConfig) string {
+	return dsnURL(conf).Redacted()
+}
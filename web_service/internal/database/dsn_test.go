@@ -0,0 +1,57 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"web_service/internal/config"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDSN(t *testing.T) {
+	testTable := []struct {
+		scenario string
+		user     string
+		password string
+		name     string
+	}{
+		{"plain", "school", "secret", "school"},
+		{"at_sign", "school", "p@ss@word", "school"},
+		{"colon", "school:admin", "pa:ss", "school"},
+		{"slash", "school", "pa/ss/", "school/db"},
+		{"spaces", "school", " pa ss ", "school db"},
+		{"quotes", "school", `pa'ss"word`, "school"},
+		{"query_characters", "school", "pa?ss#word&sslmode=require", "school"},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			conf := &config.DBConfig{
+				Type:     "postgres",
+				Host:     "db.internal",
+				Port:     "5432",
+				User:     tc.user,
+				Password: tc.password,
+				Name:     tc.name,
+				SSLMode:  "disable",
+				TimeZone: "Europe/Berlin",
+			}
+
+			parsed, err := pgconn.ParseConfig(BuildDSN(conf))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.user, parsed.User)
+				assert.Equal(t, tc.password, parsed.Password)
+				assert.Equal(t, tc.name, parsed.Database)
+				assert.Equal(t, "db.internal", parsed.Host)
+				assert.Equal(t, uint16(5432), parsed.Port)
+				assert.Nil(t, parsed.TLSConfig, "sslmode must not be overridable from the password")
+				assert.Equal(t, "Europe/Berlin", parsed.RuntimeParams["TimeZone"])
+			}
+
+			redacted := RedactedDSN(conf)
+			assert.NotContains(t, redacted, tc.password)
+			assert.True(t, strings.HasPrefix(redacted, "postgres://"))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// rawMigrations cover what gorm's AutoMigrate can't express. Every statement has
+// to be idempotent because they run on each start.
+var rawMigrations = []string{
+	`ALTER TABLE lectures ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(description, ''))) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_lectures_search_vector ON lectures USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_lectures_date ON lectures (date)`,
+	`CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_events is append-only';
+		END
+		$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+	`CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE FUNCTION reject_audit_event_change()`,
+	// Row triggers don't fire on TRUNCATE.
+	`DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events`,
+	`CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+		FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_change()`,
+}
+
+// Migrate runs on the primary: the read replicas are already registered and
+// would otherwise answer the schema checks below.
+func Migrate(db *gorm.DB, log *zap.Logger) error {
+	db = db.Clauses(dbresolver.Write)
+
+	// Lectures from before statuses existed were all live; AutoMigrate would
+	// leave them as drafts, hidden from listings and closed for enrolment.
+	migrator := db.Migrator()
+	backfillLectureStatus := migrator.HasTable(&models.Lecture{}) && !migrator.HasColumn(&models.Lecture{}, "status")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.User{}, &models.Lecture{}, &models.IdempotencyKey{}, &models.AuditEvent{}, &models.OutboxEvent{},
+			&models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.WebhookDeliveryAttempt{}, &models.Notification{}); err != nil {
+			return err
+		}
+
+		if !backfillLectureStatus {
+			return nil
+		}
+
+		return tx.Model(&models.Lecture{}).Unscoped().Where("1 = 1").UpdateColumn("status", models.LectureStatusPublished).Error
+	})
+	if err != nil {
+		appErr := apperrors.MigrationErr.AppendMessage(err)
+		log.Sugar().Error(appErr)
+		return appErr
+	}
+
+	for _, statement := range rawMigrations {
+		if err := db.Exec(statement).Error; err != nil {
+			appErr := apperrors.MigrationErr.AppendMessage(err)
+			log.Sugar().Error(appErr)
+			return appErr
+		}
+	}
+
+	log.Info("Migration success")
+	return nil
+}
+
+// CheckMigrations reports whether the schema Migrate produces is in place.
+// It asks the primary, since a lagging replica says nothing about the schema.
+func CheckMigrations(db *gorm.DB) error {
+	migrator := db.Clauses(dbresolver.Write).Migrator()
+	for _, table := range []interface{}{&models.User{}, &models.Lecture{}, "lecture_students", &models.IdempotencyKey{}, &models.AuditEvent{}, &models.OutboxEvent{},
+		&models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.WebhookDeliveryAttempt{}, &models.Notification{}} {
+		if !migrator.HasTable(table) {
+			return apperrors.MigrationErr.AppendMessage("missing table", table)
+		}
+	}
+
+	if !migrator.HasColumn(&models.User{}, "version") {
+		return apperrors.MigrationErr.AppendMessage("missing users column", "version")
+	}
+
+	for _, column := range []string{"status", "capacity", "search_vector", "version"} {
+		if !migrator.HasColumn(&models.Lecture{}, column) {
+			return apperrors.MigrationErr.AppendMessage("missing lectures column", column)
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"strconv"
 	"time"
 	"web_service/internal/apperrors"
 	"web_service/internal/config"
@@ -12,14 +12,17 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 type PostgresDB interface {
 	SetupDatabase(ctx context.Context, conf *config.Config, log *zap.Logger) (*gorm.DB, error)
+	Close() error
 }
 
 type postgresDB struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	replicas []*readReplica
 }
 
 func NewPostgresDB() PostgresDB {
@@ -27,19 +30,17 @@ func NewPostgresDB() PostgresDB {
 }
 
 func (p *postgresDB) SetupDatabase(ctx context.Context, conf *config.Config, log *zap.Logger) (*gorm.DB, error) {
-	if conf.Postgres.DBName == "" {
+	if conf.DB.Name == "" {
 		appErr := apperrors.SetupDatabaseErr.AppendMessage("config DBName is empty")
 		log.Sugar().Error(appErr)
 		return nil, appErr
 	}
 
-	dsn := fmt.Sprintf("%v://%v:%v/%v?sslmode=%v&user=%v&password=%v&dbname=%v",
-		conf.Postgres.SqlType, conf.Postgres.SqlHost, conf.Postgres.SqlPort, conf.Postgres.SqlType,
-		conf.Postgres.SqlMode, conf.Postgres.UserName, conf.Postgres.Password, conf.Postgres.DBName,
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+	// The connection is checked by connect below, with retries; gorm's own
+	// ping would fail right away while Postgres is still starting.
+	db, err := gorm.Open(postgres.Open(BuildDSN(conf.DB)), &gorm.Config{
+		Logger:               logger.Default.LogMode(logger.Silent),
+		DisableAutomaticPing: true,
 	})
 	if err != nil {
 		appErr := apperrors.SetupDatabaseErr.AppendMessage(err)
@@ -47,35 +48,94 @@ func (p *postgresDB) SetupDatabase(ctx context.Context, conf *config.Config, log
 		return nil, appErr
 	}
 
-	sqlDB, err := db.DB()
+	// Statements become child spans of the request; query arguments are left
+	// out so user data never ends up in a trace backend.
+	err = db.Use(tracing.NewPlugin(tracing.WithoutQueryVariables(), tracing.WithoutMetrics()))
 	if err != nil {
 		appErr := apperrors.SetupDatabaseErr.AppendMessage(err)
 		log.Sugar().Error(appErr)
 		return nil, appErr
 	}
 
-	tNum, err := strconv.Atoi(conf.Postgres.TimeoutQuery)
+	sqlDB, err := db.DB()
 	if err != nil {
 		appErr := apperrors.SetupDatabaseErr.AppendMessage(err)
 		log.Sugar().Error(appErr)
 		return nil, appErr
 	}
 
-	dsnWithoutPassword := fmt.Sprintf("%v://%v:%v/%v?sslmode=%v&user=%v&password=[great secret]&dbname=%v&TimeZone=%s",
-		conf.Postgres.SqlType, conf.Postgres.SqlHost, conf.Postgres.SqlPort, conf.Postgres.SqlType,
-		conf.Postgres.SqlMode, conf.Postgres.UserName, conf.Postgres.DBName, conf.Postgres.TimeZone,
-	)
-	log.Sugar().Infof("Trying to connect to Postgres.\n %s", dsnWithoutPassword)
-	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(tNum))
-	defer cancel()
+	sqlDB.SetMaxOpenConns(conf.DB.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(conf.DB.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(conf.DB.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(conf.DB.ConnMaxIdleTime)
 
-	err = sqlDB.PingContext(ctx)
+	log.Sugar().Infof("Trying to connect to Postgres.\n %s", RedactedDSN(conf.DB))
+	err = connect(ctx, sqlDB, conf.DB, log.Sugar())
 	if err != nil {
+		sqlDB.Close()
 		appErr := apperrors.SetupDatabaseErr.AppendMessage(fmt.Sprintf("PingErr %v", err))
 		log.Sugar().Error(appErr)
 		return nil, appErr
 	}
 
 	log.Info("DB Postgres has been connected, DB.Ping success ")
+	if len(conf.DB.ReplicaDSNs) > 0 {
+		p.replicas, err = useReplicas(ctx, db, sqlDB, conf.DB, log.Sugar())
+		if err != nil {
+			sqlDB.Close()
+			appErr := apperrors.SetupDatabaseErr.AppendMessage("read replicas", err)
+			log.Sugar().Error(appErr)
+			return nil, appErr
+		}
+
+		log.Sugar().Infof("Routing reads to %d read replicas", len(p.replicas))
+	}
+
+	p.DB = db
 	return db, nil
 }
+
+// Close releases the primary and replica pools.
+func (p *postgresDB) Close() error {
+	replicasErr := closeReplicas(p.replicas)
+	if p.DB == nil {
+		return replicasErr
+	}
+
+	sqlDB, err := p.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+
+	return replicasErr
+}
+
+// connect pings until Postgres answers, backing off exponentially between
+// attempts, e.g. while docker-compose is still bringing the database up.
+func connect(ctx context.Context, sqlDB *sql.DB, conf *config.DBConfig, log *zap.SugaredLogger) error {
+	backoff := conf.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(conf.TimeoutQuery))
+		err := sqlDB.PingContext(pingCtx)
+		cancel()
+		if err == nil || attempt >= conf.ConnectAttempts {
+			return err
+		}
+
+		log.Warnf("Postgres is not reachable, attempt %d of %d: %v. Retrying in %v", attempt, conf.ConnectAttempts, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > conf.ConnectBackoffMax {
+			backoff = conf.ConnectBackoffMax
+		}
+	}
+}
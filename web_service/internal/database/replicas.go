@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sync/atomic"
+	"time"
+	"web_service/internal/config"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type readReplica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaPolicy spreads reads round-robin over the replicas that passed their
+// last health check, and sends them to the primary when none did.
+type replicaPolicy struct {
+	primary  gorm.ConnPool
+	replicas []*readReplica
+	next     atomic.Uint64
+}
+
+func (policy *replicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	start := policy.next.Add(1)
+	for i := range policy.replicas {
+		replica := policy.replicas[(start+uint64(i))%uint64(len(policy.replicas))]
+		if replica.healthy.Load() {
+			return replica.db
+		}
+	}
+
+	return policy.primary
+}
+
+// useReplicas routes plain SELECTs to the read replicas. Writes, transactions
+// and queries marked with dbresolver.Write stay on the primary.
+func useReplicas(ctx context.Context, db *gorm.DB, primary *sql.DB, conf *config.DBConfig, log *zap.SugaredLogger) ([]*readReplica, error) {
+	replicas := []*readReplica{}
+	dialectors := []gorm.Dialector{}
+	for _, dsn := range conf.ReplicaDSNs {
+		replicaDB, err := sql.Open("pgx", dsn)
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, err
+		}
+
+		replicaDB.SetMaxOpenConns(conf.MaxOpenConns)
+		replicaDB.SetMaxIdleConns(conf.MaxIdleConns)
+		replicaDB.SetConnMaxLifetime(conf.ConnMaxLifetime)
+		replicaDB.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+
+		// Starting out healthy makes the first check log replicas that are down.
+		replica := &readReplica{name: redactDSN(dsn), db: replicaDB}
+		replica.healthy.Store(true)
+		replicas = append(replicas, replica)
+		dialectors = append(dialectors, postgres.New(postgres.Config{Conn: replicaDB}))
+	}
+
+	policy := &replicaPolicy{primary: primary, replicas: replicas}
+	err := db.Use(dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: policy}))
+	if err != nil {
+		closeReplicas(replicas)
+		return nil, err
+	}
+
+	checkReplicas(ctx, replicas, conf, log)
+	go func() {
+		ticker := time.NewTicker(conf.ReplicaHealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkReplicas(ctx, replicas, conf, log)
+			}
+		}
+	}()
+
+	return replicas, nil
+}
+
+func checkReplicas(ctx context.Context, replicas []*readReplica, conf *config.DBConfig, log *zap.SugaredLogger) {
+	for _, replica := range replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(conf.TimeoutQuery))
+		err := replica.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if replica.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Infof("Read replica %s is healthy, routing reads to it", replica.name)
+			} else {
+				log.Warnf("Read replica %s is unhealthy, taking it out of rotation: %v", replica.name, err)
+			}
+		}
+	}
+}
+
+func closeReplicas(replicas []*readReplica) error {
+	var firstErr error
+	for _, replica := range replicas {
+		if err := replica.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func redactDSN(dsn string) string {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return "[unparsable DSN]"
+	}
+
+	return parsed.Redacted()
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"web_service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+// fakeDriver stands in for Postgres: a named database can be taken down, and
+// every query it answers is counted and returns a single 1.
+type fakeDriver struct {
+	mu      sync.Mutex
+	down    map[string]bool
+	queries map[string]int
+}
+
+var fakes = &fakeDriver{down: map[string]bool{}, queries: map[string]int{}}
+
+func init() {
+	sql.Register("fake", fakes)
+}
+
+func (d *fakeDriver) setDown(name string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down[name] = down
+}
+
+func (d *fakeDriver) isDown(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.down[name]
+}
+
+func (d *fakeDriver) queried(name string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queries[name]
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if d.isDown(name) {
+		return nil, errors.New("connection refused")
+	}
+
+	return &fakeConn{name: name}, nil
+}
+
+type fakeConn struct {
+	name string
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{name: conn.name}, nil
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	return conn, nil
+}
+
+func (conn *fakeConn) Commit() error {
+	return nil
+}
+
+func (conn *fakeConn) Rollback() error {
+	return nil
+}
+
+func (conn *fakeConn) Ping(ctx context.Context) error {
+	if fakes.isDown(conn.name) {
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
+
+type fakeStmt struct {
+	name string
+}
+
+func (stmt *fakeStmt) Close() error {
+	return nil
+}
+
+func (stmt *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (stmt *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	fakes.mu.Lock()
+	fakes.queries[stmt.name]++
+	fakes.mu.Unlock()
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	done bool
+}
+
+func (rows *fakeRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.done {
+		return io.EOF
+	}
+
+	rows.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func openFake(t *testing.T, name string) *sql.DB {
+	db, err := sql.Open("fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReplicaPolicyResolve(t *testing.T) {
+	primary := openFake(t, "resolve-primary")
+	first := &readReplica{name: "first", db: openFake(t, "resolve-first")}
+	second := &readReplica{name: "second", db: openFake(t, "resolve-second")}
+	policy := &replicaPolicy{primary: primary, replicas: []*readReplica{first, second}}
+
+	first.healthy.Store(true)
+	second.healthy.Store(true)
+	resolved := map[gorm.ConnPool]int{}
+	for i := 0; i < 4; i++ {
+		resolved[policy.Resolve(nil)]++
+	}
+
+	assert.Equal(t, map[gorm.ConnPool]int{first.db: 2, second.db: 2}, resolved, "round-robin over healthy replicas")
+
+	first.healthy.Store(false)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, gorm.ConnPool(second.db), policy.Resolve(nil), "unhealthy replica skipped")
+	}
+
+	second.healthy.Store(false)
+	assert.Equal(t, gorm.ConnPool(primary), policy.Resolve(nil), "primary when no replica is healthy")
+}
+
+func TestCheckReplicas(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	conf := &config.DBConfig{TimeoutQuery: 1}
+	up := &readReplica{name: "up", db: openFake(t, "check-up")}
+	down := &readReplica{name: "down", db: openFake(t, "check-down")}
+	up.healthy.Store(true)
+	down.healthy.Store(true)
+	replicas := []*readReplica{up, down}
+
+	fakes.setDown("check-down", true)
+	checkReplicas(context.Background(), replicas, conf, zap.New(core).Sugar())
+	assert.True(t, up.healthy.Load())
+	assert.False(t, down.healthy.Load())
+	assert.Equal(t, 1, logs.FilterMessageSnippet("down is unhealthy").Len())
+
+	checkReplicas(context.Background(), replicas, conf, zap.New(core).Sugar())
+	assert.Equal(t, 1, logs.Len(), "only changes of health are logged")
+
+	fakes.setDown("check-down", false)
+	checkReplicas(context.Background(), replicas, conf, zap.New(core).Sugar())
+	assert.True(t, down.healthy.Load())
+	assert.Equal(t, 1, logs.FilterMessageSnippet("down is healthy").Len())
+}
+
+func TestCheckMigrationsAsksThePrimary(t *testing.T) {
+	primary := openFake(t, "migrations-primary")
+	replica := &readReplica{name: "replica", db: openFake(t, "migrations-replica")}
+	replica.healthy.Store(true)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: primary}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{postgres.New(postgres.Config{Conn: replica.db})},
+		Policy:   &replicaPolicy{primary: primary, replicas: []*readReplica{replica}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	assert.NoError(t, db.Raw("SELECT count(*) FROM lectures").Scan(&count).Error)
+	assert.Equal(t, 1, fakes.queried("migrations-replica"), "plain reads go to the replica")
+
+	assert.NoError(t, CheckMigrations(db))
+	assert.Equal(t, 1, fakes.queried("migrations-replica"))
+	assert.Greater(t, fakes.queried("migrations-primary"), 0)
+}
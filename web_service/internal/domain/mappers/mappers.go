@@ -1,7 +1,11 @@
 package mappers
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 	"web_service/internal/domain/models"
 	"web_service/internal/domain/requests"
@@ -18,6 +22,7 @@ func MapCreateUserRequestToUser(createUserRequest *requests.CreateUserRequest) *
 		FirstName: createUserRequest.FirstName,
 		LastName:  createUserRequest.LastName,
 		Role:      createUserRequest.Role,
+		Locale:    createUserRequest.Locale,
 		Password:  createUserRequest.Password,
 	}
 }
@@ -28,6 +33,14 @@ func MapCreateLectureReqToLecture(createLectureReq *requests.CreateLectureReques
 		return nil, err
 	}
 
+	capacityNum := 0
+	if createLectureReq.Capacity != "" {
+		capacityNum, err = parseCapacity(createLectureReq.Capacity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	bid := uuid.New()
 	dateTime, err := time.Parse(time.RFC3339, createLectureReq.Date)
 	if err != nil {
@@ -41,28 +54,112 @@ func MapCreateLectureReqToLecture(createLectureReq *requests.CreateLectureReques
 		Speaker:     createLectureReq.Speaker,
 		Location:    createLectureReq.Location,
 		Duration:    durationNum,
+		Capacity:    capacityNum,
 		Date:        dateTime,
+		Status:      models.LectureStatusDraft,
 	}, nil
 }
 
+func parseCapacity(capacity string) (int, error) {
+	capacityNum, err := strconv.Atoi(capacity)
+	if err != nil {
+		return 0, err
+	}
+
+	if capacityNum < 0 {
+		return 0, fmt.Errorf("capacity must not be negative: %d", capacityNum)
+	}
+
+	return capacityNum, nil
+}
+
+// MapUpdateLectureReqToLecture applies the fields present in the request to
+// lecture, leaving it untouched if any of them is invalid.
+func MapUpdateLectureReqToLecture(updateLectureReq *requests.UpdateLectureRequest, lecture *models.Lecture) error {
+	updated := *lecture
+	if updateLectureReq.Title != nil {
+		updated.Title = *updateLectureReq.Title
+	}
+
+	if updateLectureReq.Description != nil {
+		updated.Description = *updateLectureReq.Description
+	}
+
+	if updateLectureReq.Speaker != nil {
+		updated.Speaker = *updateLectureReq.Speaker
+	}
+
+	if updateLectureReq.Location != nil {
+		updated.Location = *updateLectureReq.Location
+	}
+
+	if updateLectureReq.Date != nil {
+		dateTime, err := time.Parse(time.RFC3339, *updateLectureReq.Date)
+		if err != nil {
+			return err
+		}
+
+		updated.Date = dateTime
+	}
+
+	if updateLectureReq.Duration != nil {
+		durationNum, err := strconv.Atoi(*updateLectureReq.Duration)
+		if err != nil {
+			return err
+		}
+
+		updated.Duration = durationNum
+	}
+
+	if updateLectureReq.Capacity != nil {
+		capacityNum, err := parseCapacity(*updateLectureReq.Capacity)
+		if err != nil {
+			return err
+		}
+
+		updated.Capacity = capacityNum
+	}
+
+	*lecture = updated
+	return nil
+}
+
+func MapLectureToLectureResponse(lecture *models.Lecture) *responses.LectureResp {
+	return &responses.LectureResp{
+		ID:           lecture.ID.String(),
+		Title:        lecture.Title,
+		Description:  lecture.Description,
+		Speaker:      lecture.Speaker,
+		Date:         lecture.Date.Format(time.RFC3339),
+		Location:     lecture.Location,
+		Duration:     lecture.Duration,
+		Capacity:     lecture.Capacity,
+		Status:       string(lecture.Status),
+		CancelReason: lecture.CancelReason,
+		Version:      lecture.Version,
+	}
+}
+
 func MapGetAllLecturesAndStudentsToGetLecturesAndStudentsPPRespResponse(lectures []*models.Lecture) ([]*responses.GetLecturesAndStudentsPPResponse, error) {
 	lecturesResp := []*responses.GetLecturesAndStudentsPPResponse{}
 	for _, lecture := range lectures {
-		studentsResp := []*responses.StudentResp{}
-
-		for _, student := range lecture.Students {
-			studentResp := &responses.StudentResp{
-				ID:    student.ID.String(),
-				Email: student.Email,
-			}
-
-			studentsResp = append(studentsResp, studentResp)
+		var studentsResp []*responses.StudentResp
+		if lecture.Students != nil {
+			studentsResp = MapUsersToStudentResponses(lecture.Students)
 		}
 
 		lectureGetAllLectsResp := &responses.GetLecturesAndStudentsPPResponse{
-			ID:       lecture.ID.String(),
-			Title:    lecture.Title,
-			Students: studentsResp,
+			ID:            lecture.ID.String(),
+			Title:         lecture.Title,
+			Description:   lecture.Description,
+			Speaker:       lecture.Speaker,
+			Date:          lecture.Date.Format(time.RFC3339),
+			Location:      lecture.Location,
+			Duration:      lecture.Duration,
+			Capacity:      lecture.Capacity,
+			Status:        string(lecture.Status),
+			StudentsCount: lecture.StudentsCount,
+			Students:      studentsResp,
 		}
 
 		lecturesResp = append(lecturesResp, lectureGetAllLectsResp)
@@ -70,3 +167,450 @@ func MapGetAllLecturesAndStudentsToGetLecturesAndStudentsPPRespResponse(lectures
 
 	return lecturesResp, nil
 }
+
+func MapGetLecturesPPRequestToLectureFilter(getLectsPPRequest *requests.GetLecturesPPRequest) (*models.LectureFilter, error) {
+	filter := &models.LectureFilter{
+		Speaker:  getLectsPPRequest.Speaker,
+		Location: getLectsPPRequest.Location,
+		Query:    getLectsPPRequest.Q,
+		Status:   models.LectureStatusPublished,
+		Sort:     models.LectureSortCreatedAtDesc,
+	}
+
+	if getLectsPPRequest.Q != "" {
+		filter.Sort = models.LectureSortRelevance
+	}
+
+	if getLectsPPRequest.From != "" {
+		from, err := time.Parse(time.RFC3339, getLectsPPRequest.From)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.From = &from
+	}
+
+	if getLectsPPRequest.To != "" {
+		to, err := time.Parse(time.RFC3339, getLectsPPRequest.To)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.To = &to
+	}
+
+	if getLectsPPRequest.HasFreeSeats != "" {
+		hasFreeSeats, err := strconv.ParseBool(getLectsPPRequest.HasFreeSeats)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.HasFreeSeats = hasFreeSeats
+	}
+
+	if getLectsPPRequest.Status != "" {
+		status, ok := models.ParseLectureStatus(getLectsPPRequest.Status)
+		if !ok || !status.IsPubliclyListed() {
+			return nil, fmt.Errorf("status %q can't be listed", getLectsPPRequest.Status)
+		}
+
+		filter.Status = status
+	}
+
+	if getLectsPPRequest.Include != "" {
+		for _, include := range strings.Split(getLectsPPRequest.Include, ",") {
+			if strings.TrimSpace(include) != "students" {
+				return nil, fmt.Errorf("unknown include %q", include)
+			}
+
+			filter.IncludeStudents = true
+		}
+	}
+
+	if getLectsPPRequest.Sort != "" {
+		if !models.IsLectureSortKey(getLectsPPRequest.Sort) {
+			return nil, fmt.Errorf("unknown sort key %q", getLectsPPRequest.Sort)
+		}
+
+		if getLectsPPRequest.Sort == models.LectureSortRelevance && getLectsPPRequest.Q == "" {
+			return nil, fmt.Errorf("sort %q requires q", models.LectureSortRelevance)
+		}
+
+		filter.Sort = getLectsPPRequest.Sort
+	}
+
+	return filter, nil
+}
+
+func MapUsersToStudentResponses(students []*models.User) []*responses.StudentResp {
+	studentsResp := []*responses.StudentResp{}
+	for _, student := range students {
+		studentsResp = append(studentsResp, &responses.StudentResp{
+			ID:        student.ID.String(),
+			FirstName: student.FirstName,
+			LastName:  student.LastName,
+		})
+	}
+
+	return studentsResp
+}
+
+// MapUsersToUserResponses maps a listing of users, which leaves out emails.
+func MapUsersToUserResponses(users []*models.User) []*responses.UserResp {
+	usersResp := []*responses.UserResp{}
+	for _, user := range users {
+		userResp := MapUserToUserResponse(user)
+		userResp.Email = ""
+		usersResp = append(usersResp, userResp)
+	}
+
+	return usersResp
+}
+
+func MapUserToUserResponse(user *models.User) *responses.UserResp {
+	return &responses.UserResp{
+		ID:        user.ID.String(),
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Role:      user.Role,
+		Locale:    user.Locale,
+		Version:   user.Version,
+	}
+}
+
+// MapUpdateUserReqToUser applies the fields present in the request to user.
+func MapUpdateUserReqToUser(updateUserReq *requests.UpdateUserRequest, user *models.User) {
+	if updateUserReq.FirstName != nil {
+		user.FirstName = *updateUserReq.FirstName
+	}
+
+	if updateUserReq.LastName != nil {
+		user.LastName = *updateUserReq.LastName
+	}
+
+	if updateUserReq.Role != nil {
+		user.Role = *updateUserReq.Role
+	}
+
+	if updateUserReq.Locale != nil {
+		user.Locale = *updateUserReq.Locale
+	}
+}
+
+func MapGetUserScheduleRequestToScheduleFilter(getScheduleRequest *requests.GetUserScheduleRequest) (*models.ScheduleFilter, error) {
+	filter := &models.ScheduleFilter{}
+	if getScheduleRequest.From != "" {
+		from, err := time.Parse(time.RFC3339, getScheduleRequest.From)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.From = &from
+	}
+
+	if getScheduleRequest.To != "" {
+		to, err := time.Parse(time.RFC3339, getScheduleRequest.To)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+// MapUserLecturesToGetUserScheduleResponse splits the date-ordered lectures at
+// now; both halves keep the ascending order.
+func MapUserLecturesToGetUserScheduleResponse(user *models.User, lectures []*models.Lecture, now time.Time) *responses.GetUserScheduleResponse {
+	scheduleResp := &responses.GetUserScheduleResponse{
+		UserId:   user.ID.String(),
+		Upcoming: []*responses.UserLectureResp{},
+		Past:     []*responses.UserLectureResp{},
+	}
+
+	for _, lecture := range lectures {
+		lectureResp := &responses.UserLectureResp{
+			LectureId:    lecture.ID.String(),
+			Title:        lecture.Title,
+			Description:  lecture.Description,
+			Speaker:      lecture.Speaker,
+			Date:         lecture.Date.Format(time.RFC3339),
+			Location:     lecture.Location,
+			Duration:     lecture.Duration,
+			Status:       string(lecture.Status),
+			CancelReason: lecture.CancelReason,
+		}
+
+		if lecture.Date.After(now) {
+			scheduleResp.Upcoming = append(scheduleResp.Upcoming, lectureResp)
+		} else {
+			scheduleResp.Past = append(scheduleResp.Past, lectureResp)
+		}
+	}
+
+	return scheduleResp
+}
+
+func MapGetAuditEventsRequestToAuditFilter(getAuditEventsRequest *requests.GetAuditEventsRequest) (*models.AuditFilter, error) {
+	filter := &models.AuditFilter{EntityType: getAuditEventsRequest.Entity, EntityID: getAuditEventsRequest.EntityId}
+	switch filter.EntityType {
+	case "", models.EntityLecture, models.EntityUser, models.EntityWebhook, models.EntityWebhookDelivery:
+	default:
+		return nil, fmt.Errorf("unknown entity %q", filter.EntityType)
+	}
+
+	if getAuditEventsRequest.Actor != "" {
+		actorID, err := uuid.Parse(getAuditEventsRequest.Actor)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.ActorID = &actorID
+	}
+
+	if getAuditEventsRequest.From != "" {
+		from, err := time.Parse(time.RFC3339, getAuditEventsRequest.From)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.From = &from
+	}
+
+	if getAuditEventsRequest.To != "" {
+		to, err := time.Parse(time.RFC3339, getAuditEventsRequest.To)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+func MapAuditEventsToAuditEventResponses(events []*models.AuditEvent) ([]*responses.AuditEventResp, error) {
+	eventsResp := []*responses.AuditEventResp{}
+	for _, event := range events {
+		eventResp := &responses.AuditEventResp{
+			ID:         event.ID.String(),
+			CreatedAt:  event.CreatedAt.Format(time.RFC3339Nano),
+			Action:     event.Action,
+			EntityType: event.EntityType,
+			EntityId:   event.EntityID,
+			RequestId:  event.RequestID,
+		}
+		if event.ActorID != nil {
+			eventResp.ActorId = event.ActorID.String()
+		}
+
+		if len(event.Before) > 0 {
+			if err := json.Unmarshal(event.Before, &eventResp.Before); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(event.After) > 0 {
+			if err := json.Unmarshal(event.After, &eventResp.After); err != nil {
+				return nil, err
+			}
+		}
+
+		eventsResp = append(eventsResp, eventResp)
+	}
+
+	return eventsResp, nil
+}
+
+func MapUserToUserCreatedEvent(user *models.User) (*models.OutboxEvent, error) {
+	return models.NewOutboxEvent(models.EventUserCreated, models.EntityUser, user.ID.String(), &models.UserCreatedEvent{
+		UserID:    user.ID.String(),
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Role:      user.Role,
+	})
+}
+
+func MapLectureToLectureCreatedEvent(lecture *models.Lecture) (*models.OutboxEvent, error) {
+	return models.NewOutboxEvent(models.EventLectureCreated, models.EntityLecture, lecture.ID.String(), &models.LectureCreatedEvent{
+		LectureID: lecture.ID.String(),
+		Title:     lecture.Title,
+		Speaker:   lecture.Speaker,
+		Date:      lecture.Date,
+		Location:  lecture.Location,
+		Duration:  lecture.Duration,
+		Capacity:  lecture.Capacity,
+		Status:    string(lecture.Status),
+	})
+}
+
+// MapLectureToLectureUpdatedEvent lists the lecture's loaded students only when
+// it is cancelled; they are the ones the cancellation notifies.
+func MapLectureToLectureUpdatedEvent(lecture *models.Lecture) (*models.OutboxEvent, error) {
+	var studentIDs []string
+	if lecture.Status == models.LectureStatusCancelled {
+		for _, student := range lecture.Students {
+			studentIDs = append(studentIDs, student.ID.String())
+		}
+	}
+
+	return models.NewOutboxEvent(models.EventLectureUpdated, models.EntityLecture, lecture.ID.String(), &models.LectureUpdatedEvent{
+		LectureID:    lecture.ID.String(),
+		Title:        lecture.Title,
+		Speaker:      lecture.Speaker,
+		Date:         lecture.Date,
+		Location:     lecture.Location,
+		Duration:     lecture.Duration,
+		Capacity:     lecture.Capacity,
+		Status:       string(lecture.Status),
+		CancelReason: lecture.CancelReason,
+		StudentIDs:   studentIDs,
+	})
+}
+
+func MapLectureToLectureDeletedEvent(lecture *models.Lecture) (*models.OutboxEvent, error) {
+	return models.NewOutboxEvent(models.EventLectureDeleted, models.EntityLecture, lecture.ID.String(), &models.LectureDeletedEvent{
+		LectureID: lecture.ID.String(),
+	})
+}
+
+func MapEnrollmentToStudentEnrolledEvent(lecture *models.Lecture, student *models.User) (*models.OutboxEvent, error) {
+	return models.NewOutboxEvent(models.EventStudentEnrolled, models.EntityLecture, lecture.ID.String(), &models.StudentEnrolledEvent{
+		LectureID: lecture.ID.String(),
+		StudentID: student.ID.String(),
+	})
+}
+
+func MapEnrollmentToStudentDroppedEvent(lecture *models.Lecture, student *models.User) (*models.OutboxEvent, error) {
+	return models.NewOutboxEvent(models.EventStudentDropped, models.EntityLecture, lecture.ID.String(), &models.StudentDroppedEvent{
+		LectureID: lecture.ID.String(),
+		StudentID: student.ID.String(),
+	})
+}
+
+// minWebhookSecretLength keeps subscription secrets long enough that their
+// signatures can't be brute-forced.
+const minWebhookSecretLength = 16
+
+func MapCreateWebhookRequestToWebhookSubscription(createWebhookRequest *requests.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	parsed, err := url.Parse(createWebhookRequest.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http(s) URL, got %q", createWebhookRequest.URL)
+	}
+
+	if len(createWebhookRequest.Secret) < minWebhookSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d characters", minWebhookSecretLength)
+	}
+
+	if len(createWebhookRequest.EventTypes) == 0 {
+		return nil, fmt.Errorf("event_types must not be empty")
+	}
+
+	requested := map[string]bool{}
+	for _, eventType := range createWebhookRequest.EventTypes {
+		requested[eventType] = true
+	}
+
+	// Kept in the order of models.EventTypes, without duplicates.
+	eventTypes := []string{}
+	for _, eventType := range models.EventTypes {
+		if requested[eventType] {
+			eventTypes = append(eventTypes, eventType)
+			delete(requested, eventType)
+		}
+	}
+
+	for eventType := range requested {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+
+	rawEventTypes, err := json.Marshal(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New()
+	return &models.WebhookSubscription{
+		ID:         &id,
+		URL:        createWebhookRequest.URL,
+		EventTypes: rawEventTypes,
+		Secret:     createWebhookRequest.Secret,
+	}, nil
+}
+
+func MapWebhookSubscriptionsToWebhookResponses(subscriptions []*models.WebhookSubscription) ([]*responses.WebhookResp, error) {
+	webhooksResp := []*responses.WebhookResp{}
+	for _, subscription := range subscriptions {
+		webhookResp := &responses.WebhookResp{
+			ID:        subscription.ID.String(),
+			URL:       subscription.URL,
+			CreatedAt: subscription.CreatedAt.Format(time.RFC3339Nano),
+		}
+		if err := json.Unmarshal(subscription.EventTypes, &webhookResp.EventTypes); err != nil {
+			return nil, err
+		}
+
+		webhooksResp = append(webhooksResp, webhookResp)
+	}
+
+	return webhooksResp, nil
+}
+
+func MapGetWebhookDeliveriesRequestToFilter(webhookId string, getWebhookDeliveriesRequest *requests.GetWebhookDeliveriesRequest) (*models.WebhookDeliveryFilter, error) {
+	subscriptionID, err := uuid.Parse(webhookId)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &models.WebhookDeliveryFilter{SubscriptionID: &subscriptionID, Status: getWebhookDeliveriesRequest.Status}
+	switch filter.Status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		return nil, fmt.Errorf("unknown status %q", filter.Status)
+	}
+
+	return filter, nil
+}
+
+func MapWebhookDeliveryToWebhookDeliveryResponse(delivery *models.WebhookDelivery) *responses.WebhookDeliveryResp {
+	deliveryResp := &responses.WebhookDeliveryResp{
+		ID:        delivery.ID.String(),
+		WebhookID: delivery.SubscriptionID.String(),
+		EventID:   delivery.EventID.String(),
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+		CreatedAt: delivery.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		deliveryResp.NextAttemptAt = delivery.NextAttemptAt.Format(time.RFC3339Nano)
+	}
+
+	if delivery.DeliveredAt != nil {
+		deliveryResp.DeliveredAt = delivery.DeliveredAt.Format(time.RFC3339Nano)
+	}
+
+	for _, attempt := range delivery.AttemptLog {
+		deliveryResp.AttemptLog = append(deliveryResp.AttemptLog, &responses.WebhookDeliveryAttemptResp{
+			Attempt:     attempt.Attempt,
+			AttemptedAt: attempt.AttemptedAt.Format(time.RFC3339Nano),
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			DurationMs:  attempt.DurationMs,
+		})
+	}
+
+	return deliveryResp
+}
+
+func MapWebhookDeliveriesToWebhookDeliveryResponses(deliveries []*models.WebhookDelivery) []*responses.WebhookDeliveryResp {
+	deliveriesResp := []*responses.WebhookDeliveryResp{}
+	for _, delivery := range deliveries {
+		deliveriesResp = append(deliveriesResp, MapWebhookDeliveryToWebhookDeliveryResponse(delivery))
+	}
+
+	return deliveriesResp
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entity types, shared by the audit log and the outbox.
+const (
+	EntityLecture         = "lecture"
+	EntityUser            = "user"
+	EntityWebhook         = "webhook"
+	EntityWebhookDelivery = "webhook_delivery"
+)
+
+const (
+	AuditActionLectureCreated       = "lecture.created"
+	AuditActionLectureUpdated       = "lecture.updated"
+	AuditActionLectureStatusChanged = "lecture.status_changed"
+	AuditActionLectureDeleted       = "lecture.deleted"
+	AuditActionStudentEnrolled      = "lecture.student_enrolled"
+	AuditActionStudentRemoved       = "lecture.student_removed"
+	AuditActionUserCreated          = "user.created"
+	AuditActionUserUpdated          = "user.updated"
+	AuditActionUserDeleted          = "user.deleted"
+	AuditActionWebhookCreated       = "webhook.created"
+	AuditActionWebhookDeleted       = "webhook.deleted"
+	AuditActionDeliveryRetried      = "webhook_delivery.retried"
+)
+
+// AuditEvent records who changed what and when. Before and After are JSON
+// objects holding only the fields that changed; a creation has no Before and
+// a deletion no After. ActorID is nil for anonymous requests such as sign-ups.
+// Rows are append-only: the database rejects updates and deletes.
+type AuditEvent struct {
+	ID         *uuid.UUID `gorm:"primaryKey"`
+	CreatedAt  time.Time  `gorm:"not null;index"`
+	ActorID    *uuid.UUID `gorm:"index"`
+	Action     string     `gorm:"size:64;not null"`
+	EntityType string     `gorm:"size:32;not null;index:idx_audit_events_entity"`
+	EntityID   string     `gorm:"size:64;not null;index:idx_audit_events_entity"`
+	Before     []byte     `gorm:"type:jsonb"`
+	After      []byte     `gorm:"type:jsonb"`
+	RequestID  string     `gorm:"size:128"`
+}
+
+// AuditFilter narrows the audit log; zero values mean "no restriction".
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	ActorID    *uuid.UUID
+	From       *time.Time
+	To         *time.Time
+}
+
+type AuditEventsPage struct {
+	Events []*AuditEvent
+	PageInfo
+}
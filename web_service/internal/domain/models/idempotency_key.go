@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// IdempotencyKey is the stored outcome of a request sent with an
+// Idempotency-Key header, per key and user. A zero Status marks a request
+// that is still being processed; past LockedUntil it is taken to have died
+// with its instance, and the key is free again.
+type IdempotencyKey struct {
+	Key         string    `gorm:"primaryKey;size:255"`
+	Scope       string    `gorm:"primaryKey;size:64"`
+	RequestHash string    `gorm:"size:64;not null"`
+	Status      int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"size:255"`
+	Body        []byte    `gorm:"type:bytea"`
+	CreatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	LockedUntil time.Time
+}
+
+// IsCompleted reports whether the response has been stored and can be replayed.
+func (key *IdempotencyKey) IsCompleted() bool {
+	return key.Status != 0
+}
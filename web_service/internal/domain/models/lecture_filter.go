@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+const (
+	LectureSortDate          = "date"
+	LectureSortDateDesc      = "-date"
+	LectureSortCreatedAt     = "created_at"
+	LectureSortCreatedAtDesc = "-created_at"
+	LectureSortTitle         = "title"
+	LectureSortTitleDesc     = "-title"
+	LectureSortRelevance     = "relevance"
+)
+
+// LectureFilter narrows the lecture listing. Zero values mean "no restriction",
+// except Status and Sort which the service always fills in. IncludeStudents
+// loads the enrolled students on top of their count.
+type LectureFilter struct {
+	From            *time.Time
+	To              *time.Time
+	Speaker         string
+	Location        string
+	HasFreeSeats    bool
+	Status          LectureStatus
+	Query           string
+	Sort            string
+	IncludeStudents bool
+}
+
+func IsLectureSortKey(sort string) bool {
+	switch sort {
+	case LectureSortDate, LectureSortDateDesc, LectureSortCreatedAt, LectureSortCreatedAtDesc,
+		LectureSortTitle, LectureSortTitleDesc, LectureSortRelevance:
+		return true
+	}
+
+	return false
+}
+
+// ScheduleFilter limits a student's lectures to a date range; nil bounds are open.
+type ScheduleFilter struct {
+	From *time.Time
+	To   *time.Time
+}
@@ -7,14 +7,74 @@ import (
 	"gorm.io/gorm"
 )
 
+type LectureStatus string
+
+const (
+	LectureStatusDraft     LectureStatus = "draft"
+	LectureStatusPublished LectureStatus = "published"
+	LectureStatusCancelled LectureStatus = "cancelled"
+	LectureStatusCompleted LectureStatus = "completed"
+)
+
+var lectureStatusTransitions = map[LectureStatus][]LectureStatus{
+	LectureStatusDraft:     {LectureStatusPublished, LectureStatusCancelled},
+	LectureStatusPublished: {LectureStatusCancelled, LectureStatusCompleted},
+}
+
 type Lecture struct {
 	gorm.Model
-	ID          *uuid.UUID `json:"id" gorm:"primaryKey"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Speaker     string     `json:"speaker"`
-	Date        time.Time  `json:"date"`
-	Location    string     `json:"location"`
-	Duration    int        `json:"duration"`
-	Students    []*User    `gorm:"many2many:lecture_students;" json:"lecture_students"`
+	ID            *uuid.UUID    `json:"id" gorm:"primaryKey"`
+	Title         string        `json:"title"`
+	Description   string        `json:"description"`
+	Speaker       string        `json:"speaker"`
+	Date          time.Time     `json:"date"`
+	Location      string        `json:"location"`
+	Duration      int           `json:"duration"`
+	Capacity      int           `json:"capacity"`
+	Status        LectureStatus `json:"status" gorm:"type:varchar(16);not null;default:draft;index"`
+	CancelReason  string        `json:"cancel_reason"`
+	Version       int64         `json:"version" gorm:"not null;default:1"`
+	SearchRank    float32       `json:"-" gorm:"->;-:migration"`
+	StudentsCount int64         `json:"students_count" gorm:"->;-:migration"`
+	Students      []*User       `gorm:"many2many:lecture_students;" json:"lecture_students"`
+}
+
+func ParseLectureStatus(status string) (LectureStatus, bool) {
+	lectureStatus := LectureStatus(status)
+	switch lectureStatus {
+	case LectureStatusDraft, LectureStatusPublished, LectureStatusCancelled, LectureStatusCompleted:
+		return lectureStatus, true
+	}
+
+	return "", false
+}
+
+// CanTransitionTo reports whether a lecture in this status may be moved to next.
+// Cancelled and completed are terminal.
+func (status LectureStatus) CanTransitionTo(next LectureStatus) bool {
+	for _, allowed := range lectureStatusTransitions[status] {
+		if allowed == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPubliclyListed reports whether lectures in this status may be shown in the
+// public listing. Drafts are only visible to their authors.
+func (status LectureStatus) IsPubliclyListed() bool {
+	return status == LectureStatusPublished || status == LectureStatusCancelled || status == LectureStatusCompleted
+}
+
+// HasFreeSeats reports whether one more student fits. A zero capacity means the
+// lecture is not limited.
+func (lecture *Lecture) HasFreeSeats(enrolled int64) bool {
+	return lecture.Capacity == 0 || enrolled < int64(lecture.Capacity)
+}
+
+// IsBookable reports whether students may still enroll: the lecture has to be
+// published and must not have started yet.
+func (lecture *Lecture) IsBookable(now time.Time) bool {
+	return lecture.Status == LectureStatusPublished && lecture.Date.After(now)
 }
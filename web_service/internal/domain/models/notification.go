@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NotificationEnrollment   = "enrollment"
+	NotificationDrop         = "drop"
+	NotificationCancellation = "cancellation"
+	NotificationReminder     = "reminder"
+)
+
+// NotificationKinds are the emails students get, each with its own templates.
+var NotificationKinds = []string{NotificationEnrollment, NotificationDrop, NotificationCancellation, NotificationReminder}
+
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationSkipped = "skipped"
+)
+
+// Notification is an email to a student about a lecture, waiting in the queue
+// to be rendered and sent. It only references the student and the lecture, so
+// the email shows them as they are when it goes out. DedupKey keeps the same
+// notice from being queued twice.
+type Notification struct {
+	ID            *uuid.UUID `gorm:"primaryKey"`
+	Kind          string     `gorm:"size:32;not null"`
+	DedupKey      string     `gorm:"not null;uniqueIndex"`
+	UserID        *uuid.UUID `gorm:"not null"`
+	User          *User      `gorm:"constraint:OnDelete:CASCADE"`
+	LectureID     *uuid.UUID `gorm:"not null"`
+	Lecture       *Lecture   `gorm:"constraint:OnDelete:CASCADE"`
+	Status        string     `gorm:"size:16;not null;index"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"not null;index"`
+	LastError     string
+	CreatedAt     time.Time `gorm:"not null"`
+	SentAt        *time.Time
+}
+
+// NewNotification queues a kind email to student about lecture. cause, when
+// set, tells apart notices that may legitimately repeat, such as enrolling
+// twice.
+func NewNotification(kind string, lectureID *uuid.UUID, studentID *uuid.UUID, cause string) *Notification {
+	dedupKey := fmt.Sprintf("%s:%s:%s", kind, lectureID, studentID)
+	if cause != "" {
+		dedupKey += ":" + cause
+	}
+
+	id := uuid.New()
+	now := time.Now()
+	return &Notification{
+		ID:            &id,
+		Kind:          kind,
+		DedupKey:      dedupKey,
+		UserID:        studentID,
+		LectureID:     lectureID,
+		Status:        NotificationPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
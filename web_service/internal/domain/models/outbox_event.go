@@ -0,0 +1,106 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventUserCreated     = "UserCreated"
+	EventLectureCreated  = "LectureCreated"
+	EventLectureUpdated  = "LectureUpdated"
+	EventLectureDeleted  = "LectureDeleted"
+	EventStudentEnrolled = "StudentEnrolled"
+	EventStudentDropped  = "StudentDropped"
+)
+
+// OutboxEvent is a domain event waiting in the outbox for the relay. It is
+// written in the same transaction as the change it announces, so an event is
+// published if and only if the change was committed. PublishedAt stays nil
+// until a publisher accepted it; failed attempts are retried from
+// NextAttemptAt on.
+type OutboxEvent struct {
+	ID            *uuid.UUID `gorm:"primaryKey"`
+	Type          string     `gorm:"size:64;not null"`
+	AggregateType string     `gorm:"size:32;not null"`
+	AggregateID   string     `gorm:"size:64;not null"`
+	Payload       []byte     `gorm:"type:jsonb;not null"`
+	OccurredAt    time.Time  `gorm:"not null"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"not null;index"`
+	LastError     string
+	PublishedAt   *time.Time `gorm:"index"`
+}
+
+func NewOutboxEvent(eventType string, aggregateType string, aggregateID string, payload interface{}) (*OutboxEvent, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New()
+	now := time.Now()
+	return &OutboxEvent{
+		ID:            &id,
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       raw,
+		OccurredAt:    now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// The payloads of the domain events, as consumers receive them.
+
+// UserCreatedEvent leaves the email out: events leave the service for
+// partners, who have no business with it.
+type UserCreatedEvent struct {
+	UserID    string `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `json:"role"`
+}
+
+type LectureCreatedEvent struct {
+	LectureID string    `json:"lecture_id"`
+	Title     string    `json:"title"`
+	Speaker   string    `json:"speaker"`
+	Date      time.Time `json:"date"`
+	Location  string    `json:"location"`
+	Duration  int       `json:"duration"`
+	Capacity  int       `json:"capacity"`
+	Status    string    `json:"status"`
+}
+
+// LectureUpdatedEvent carries the lecture as it is after an edit or a status
+// change. A cancellation also lists the students enrolled at that moment, so
+// they are the ones told about it however late the event is published.
+type LectureUpdatedEvent struct {
+	LectureID    string    `json:"lecture_id"`
+	Title        string    `json:"title"`
+	Speaker      string    `json:"speaker"`
+	Date         time.Time `json:"date"`
+	Location     string    `json:"location"`
+	Duration     int       `json:"duration"`
+	Capacity     int       `json:"capacity"`
+	Status       string    `json:"status"`
+	CancelReason string    `json:"cancel_reason,omitempty"`
+	StudentIDs   []string  `json:"student_ids,omitempty"`
+}
+
+type LectureDeletedEvent struct {
+	LectureID string `json:"lecture_id"`
+}
+
+type StudentEnrolledEvent struct {
+	LectureID string `json:"lecture_id"`
+	StudentID string `json:"student_id"`
+}
+
+type StudentDroppedEvent struct {
+	LectureID string `json:"lecture_id"`
+	StudentID string `json:"student_id"`
+}
@@ -0,0 +1,31 @@
+package models
+
+const (
+	DefaultPerPage = 10
+	MaxPerPage     = 100
+)
+
+// PageRequest selects one page of a listing. Cursor takes precedence over Page,
+// which is only kept for clients that still paginate by offset.
+type PageRequest struct {
+	Page      int
+	PerPage   int
+	Cursor    string
+	WithTotal bool
+}
+
+type PageInfo struct {
+	NextCursor string
+	PrevCursor string
+	TotalCount *int64
+}
+
+type LecturesPage struct {
+	Lectures []*Lecture
+	PageInfo
+}
+
+type UsersPage struct {
+	Users []*User
+	PageInfo
+}
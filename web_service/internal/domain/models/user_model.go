@@ -5,6 +5,11 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleStudent = "student"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	gorm.Model
 	ID        *uuid.UUID `json:"id" gorm:"primaryKey"`
@@ -13,4 +18,7 @@ type User struct {
 	LastName  string     `json:"last_name"`
 	Password  string     `json:"password"`
 	Role      string     `json:"role"`
+	Locale    string     `json:"locale"`
+	Version   int64      `json:"version" gorm:"not null;default:1"`
+	Lectures  []*Lecture `gorm:"many2many:lecture_students;" json:"lectures,omitempty"`
 }
@@ -0,0 +1,22 @@
+package models
+
+// VersionPrecondition is what an If-Match header allows a write to replace:
+// any version of the resource, or one of Versions.
+type VersionPrecondition struct {
+	AnyVersion bool
+	Versions   []int64
+}
+
+func (precondition *VersionPrecondition) Matches(version int64) bool {
+	if precondition.AnyVersion {
+		return true
+	}
+
+	for _, allowed := range precondition.Versions {
+		if allowed == version {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventTypes are the domain events a webhook subscription may ask for.
+var EventTypes = []string{
+	EventUserCreated,
+	EventLectureCreated,
+	EventLectureUpdated,
+	EventLectureDeleted,
+	EventStudentEnrolled,
+	EventStudentDropped,
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookSubscription asks for the events named in EventTypes, a JSON array, to
+// be POSTed to URL. Every request is signed with Secret, which is never handed
+// back once registered.
+type WebhookSubscription struct {
+	ID         *uuid.UUID `gorm:"primaryKey"`
+	URL        string     `gorm:"not null"`
+	EventTypes []byte     `gorm:"type:jsonb;not null"`
+	Secret     string     `gorm:"not null"`
+	CreatedAt  time.Time  `gorm:"not null"`
+}
+
+// WebhookDelivery is one event on its way to one subscription. Body is the
+// exact JSON sent on every attempt. A pending delivery is retried from
+// NextAttemptAt on until it is delivered or, after too many attempts, dead.
+// Attempts keeps counting when a dead delivery is retried by hand;
+// AttemptsBeforeRetry is where the count stood then.
+type WebhookDelivery struct {
+	ID                  *uuid.UUID           `gorm:"primaryKey"`
+	SubscriptionID      *uuid.UUID           `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	Subscription        *WebhookSubscription `gorm:"constraint:OnDelete:CASCADE"`
+	EventID             *uuid.UUID           `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventType           string               `gorm:"size:64;not null"`
+	Body                []byte               `gorm:"type:jsonb;not null"`
+	Status              string               `gorm:"size:16;not null;index"`
+	Attempts            int                  `gorm:"not null;default:0"`
+	AttemptsBeforeRetry int                  `gorm:"not null;default:0"`
+	NextAttemptAt       time.Time            `gorm:"not null;index"`
+	LastError           string
+	CreatedAt           time.Time `gorm:"not null"`
+	DeliveredAt         *time.Time
+	AttemptLog          []*WebhookDeliveryAttempt `gorm:"foreignKey:DeliveryID;constraint:OnDelete:CASCADE"`
+}
+
+// WebhookDeliveryAttempt records one POST of a delivery. StatusCode is 0 when
+// no response came back.
+type WebhookDeliveryAttempt struct {
+	ID          *uuid.UUID `gorm:"primaryKey"`
+	DeliveryID  *uuid.UUID `gorm:"not null;index"`
+	Attempt     int        `gorm:"not null"`
+	AttemptedAt time.Time  `gorm:"not null"`
+	StatusCode  int
+	Error       string
+	DurationMs  int64
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID *uuid.UUID
+	Status         string
+}
+
+type WebhookDeliveriesPage struct {
+	Deliveries []*WebhookDelivery
+	PageInfo
+}
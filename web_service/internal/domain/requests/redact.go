@@ -0,0 +1,24 @@
+package requests
+
+import (
+	"fmt"
+	"web_service/internal/logging"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func (request CreateUserRequest) Format(state fmt.State, verb rune) {
+	logging.FormatRedacted(state, verb, request)
+}
+
+func (request CreateUserRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return logging.MarshalRedacted(enc, request)
+}
+
+func (request CreateWebhookRequest) Format(state fmt.State, verb rune) {
+	logging.FormatRedacted(state, verb, request)
+}
+
+func (request CreateWebhookRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return logging.MarshalRedacted(enc, request)
+}
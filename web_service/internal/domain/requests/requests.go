@@ -6,6 +6,15 @@ type CreateUserRequest struct {
 	LastName  string `json:"last_name"`
 	Password  string `json:"password"`
 	Role      string `json:"role"`
+	Locale    string `json:"locale"`
+}
+
+// UpdateUserRequest is a partial update: fields left out keep their value.
+type UpdateUserRequest struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Role      *string `json:"role"`
+	Locale    *string `json:"locale"`
 }
 
 type CreateLectureRequest struct {
@@ -15,6 +24,18 @@ type CreateLectureRequest struct {
 	Date        string `json:"date"`
 	Location    string `json:"location"`
 	Duration    string `json:"duration"`
+	Capacity    string `json:"capacity"`
+}
+
+// UpdateLectureRequest is a partial update: fields left out keep their value.
+type UpdateLectureRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Speaker     *string `json:"speaker"`
+	Date        *string `json:"date"`
+	Location    *string `json:"location"`
+	Duration    *string `json:"duration"`
+	Capacity    *string `json:"capacity"`
 }
 
 type AddStudentToLectureReq struct {
@@ -26,6 +47,67 @@ type DeleteStudentFromLectureRequest struct {
 }
 
 type GetLecturesPPRequest struct {
-	Page    string `json:"page"`
-	PerPage string `json:"per_page"`
+	Page         string `json:"page"`
+	PerPage      string `json:"per_page"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Speaker      string `json:"speaker"`
+	Location     string `json:"location"`
+	HasFreeSeats string `json:"has_free_seats"`
+	Status       string `json:"status"`
+	Sort         string `json:"sort"`
+	Q            string `json:"q"`
+	Cursor       string `json:"cursor"`
+	IncludeTotal string `json:"include_total"`
+	Include      string `json:"include"`
+}
+
+type GetLectureStudentsRequest struct {
+	Page         string `json:"page"`
+	PerPage      string `json:"per_page"`
+	Cursor       string `json:"cursor"`
+	IncludeTotal string `json:"include_total"`
+}
+
+type GetUsersRequest struct {
+	Page         string `json:"page"`
+	PerPage      string `json:"per_page"`
+	Cursor       string `json:"cursor"`
+	IncludeTotal string `json:"include_total"`
+}
+
+type ChangeLectureStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type GetUserScheduleRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type GetAuditEventsRequest struct {
+	Entity       string `json:"entity"`
+	EntityId     string `json:"entity_id"`
+	Actor        string `json:"actor"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Page         string `json:"page"`
+	PerPage      string `json:"per_page"`
+	Cursor       string `json:"cursor"`
+	IncludeTotal string `json:"include_total"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+type GetWebhookDeliveriesRequest struct {
+	Status       string `json:"status"`
+	Page         string `json:"page"`
+	PerPage      string `json:"per_page"`
+	Cursor       string `json:"cursor"`
+	IncludeTotal string `json:"include_total"`
 }
@@ -0,0 +1,23 @@
+package responses
+
+import (
+	"fmt"
+	"web_service/internal/logging"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Users carry emails. The page holding them only needs the zap side: fmt
+// already calls the element's Format for nested values.
+
+func (resp UserResp) Format(state fmt.State, verb rune) {
+	logging.FormatRedacted(state, verb, resp)
+}
+
+func (resp UserResp) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return logging.MarshalRedacted(enc, resp)
+}
+
+func (resp GetUsersPageResponse) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return logging.MarshalRedacted(enc, resp)
+}
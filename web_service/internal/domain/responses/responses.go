@@ -8,6 +8,20 @@ type CreateLectureResponse struct {
 	LectureId string `json:"lecture_id"`
 }
 
+type LectureResp struct {
+	ID           string `json:"lecture_id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Speaker      string `json:"speaker"`
+	Date         string `json:"date"`
+	Location     string `json:"location"`
+	Duration     int    `json:"duration"`
+	Capacity     int    `json:"capacity"`
+	Status       string `json:"status"`
+	CancelReason string `json:"cancel_reason,omitempty"`
+	Version      int64  `json:"version"`
+}
+
 type AddUserToLecture struct {
 	LectureId string `json:"lecture_id"`
 }
@@ -16,13 +30,152 @@ type DeleteStudentFromLectureResponse struct {
 	Result string `json:"result"`
 }
 
+type ChangeLectureStatusResponse struct {
+	LectureId string `json:"lecture_id"`
+	Status    string `json:"status"`
+}
+
+type GetLecturesPageResponse struct {
+	Lectures   []*GetLecturesAndStudentsPPResponse `json:"lectures"`
+	PerPage    int                                 `json:"per_page"`
+	NextCursor string                              `json:"next_cursor,omitempty"`
+	PrevCursor string                              `json:"prev_cursor,omitempty"`
+	TotalCount *int64                              `json:"total_count,omitempty"`
+}
+
 type GetLecturesAndStudentsPPResponse struct {
-	ID       string         `json:"lecture_id"`
-	Title    string         `json:"title"`
-	Students []*StudentResp `json:"students"`
+	ID            string         `json:"lecture_id"`
+	Title         string         `json:"title"`
+	Description   string         `json:"description"`
+	Speaker       string         `json:"speaker"`
+	Date          string         `json:"date"`
+	Location      string         `json:"location"`
+	Duration      int            `json:"duration"`
+	Capacity      int            `json:"capacity"`
+	Status        string         `json:"status"`
+	StudentsCount int64          `json:"students_count"`
+	Students      []*StudentResp `json:"students,omitempty"`
 }
 
+// StudentResp leaves out the email: lectures and their students are public.
 type StudentResp struct {
-	ID    string `json:"student_id"`
-	Email string `json:"user_email"`
+	ID        string `json:"student_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+type GetLectureStudentsPageResponse struct {
+	LectureId  string         `json:"lecture_id"`
+	Students   []*StudentResp `json:"students"`
+	PerPage    int            `json:"per_page"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+	TotalCount *int64         `json:"total_count,omitempty"`
+}
+
+type UserResp struct {
+	ID        string `json:"user_id"`
+	Email     string `json:"user_email,omitempty"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `json:"role"`
+	Locale    string `json:"locale,omitempty"`
+	Version   int64  `json:"version"`
+}
+
+type GetUsersPageResponse struct {
+	Users      []*UserResp `json:"users"`
+	PerPage    int         `json:"per_page"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+	TotalCount *int64      `json:"total_count,omitempty"`
+}
+
+type UserLectureResp struct {
+	LectureId    string `json:"lecture_id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Speaker      string `json:"speaker"`
+	Date         string `json:"date"`
+	Location     string `json:"location"`
+	Duration     int    `json:"duration"`
+	Status       string `json:"status"`
+	CancelReason string `json:"cancel_reason,omitempty"`
+}
+
+type GetUserScheduleResponse struct {
+	UserId   string             `json:"user_id"`
+	Upcoming []*UserLectureResp `json:"upcoming"`
+	Past     []*UserLectureResp `json:"past"`
+}
+
+type HealthResponse struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentHealth `json:"components,omitempty"`
+}
+
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type AuditEventResp struct {
+	ID         string                 `json:"event_id"`
+	CreatedAt  string                 `json:"created_at"`
+	ActorId    string                 `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	EntityType string                 `json:"entity_type"`
+	EntityId   string                 `json:"entity_id"`
+	Before     map[string]interface{} `json:"before,omitempty"`
+	After      map[string]interface{} `json:"after,omitempty"`
+	RequestId  string                 `json:"request_id,omitempty"`
+}
+
+type GetAuditEventsPageResponse struct {
+	Events     []*AuditEventResp `json:"events"`
+	PerPage    int               `json:"per_page"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	PrevCursor string            `json:"prev_cursor,omitempty"`
+	TotalCount *int64            `json:"total_count,omitempty"`
+}
+
+type WebhookResp struct {
+	ID         string   `json:"webhook_id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []*WebhookResp `json:"webhooks"`
+}
+
+type WebhookDeliveryAttemptResp struct {
+	Attempt     int    `json:"attempt"`
+	AttemptedAt string `json:"attempted_at"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+type WebhookDeliveryResp struct {
+	ID            string                        `json:"delivery_id"`
+	WebhookID     string                        `json:"webhook_id"`
+	EventID       string                        `json:"event_id"`
+	EventType     string                        `json:"event_type"`
+	Status        string                        `json:"status"`
+	Attempts      int                           `json:"attempts"`
+	NextAttemptAt string                        `json:"next_attempt_at,omitempty"`
+	LastError     string                        `json:"last_error,omitempty"`
+	CreatedAt     string                        `json:"created_at"`
+	DeliveredAt   string                        `json:"delivered_at,omitempty"`
+	AttemptLog    []*WebhookDeliveryAttemptResp `json:"attempt_log,omitempty"`
+}
+
+type GetWebhookDeliveriesPageResponse struct {
+	Deliveries []*WebhookDeliveryResp `json:"deliveries"`
+	PerPage    int                    `json:"per_page"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+	PrevCursor string                 `json:"prev_cursor,omitempty"`
+	TotalCount *int64                 `json:"total_count,omitempty"`
 }
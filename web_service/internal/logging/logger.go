@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"os"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// New builds the application logger from config. The returned level is
+// shared with the logger, so changing it takes effect immediately.
+func New(cfg *config.LoggingConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zap.ParseAtomicLevel(cfg.Level)
+	if err != nil {
+		return nil, level, apperrors.NewLoggerErr.AppendMessage(err)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	switch cfg.Encoding {
+	case EncodingJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case EncodingConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		if cfg.File == "" {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, level, apperrors.NewLoggerErr.AppendMessage("unknown encoding", cfg.Encoding)
+	}
+
+	output := zapcore.AddSync(os.Stdout)
+	if cfg.File != "" {
+		output = zapcore.NewMultiWriteSyncer(output, zapcore.AddSync(&lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.FileMaxSizeMB,
+			MaxBackups: cfg.FileMaxBackups,
+			MaxAge:     cfg.FileMaxAgeDays,
+			Compress:   cfg.FileCompress,
+		}))
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.Lock(output), level)
+	if cfg.SamplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.SamplingInitial, cfg.SamplingThereafter)
+	}
+
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)), level, nil
+}
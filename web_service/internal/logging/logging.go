@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader correlates the log lines of one request. It is taken from
+// the caller when present, so IDs set by the gateway survive, and echoed back.
+const RequestIDHeader = "X-Request-ID"
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger, or fallback outside of a
+// request, e.g. during startup or in tests calling services directly.
+func FromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+var sensitiveFields atomic.Pointer[map[string]struct{}]
+
+func init() {
+	SetSensitiveFields([]string{"password", "email", "secret"})
+}
+
+// SetSensitiveFields replaces the list of field names masked in logs. Names
+// match either the Go field name or its json tag, case-insensitively.
+func SetSensitiveFields(names []string) {
+	fields := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			fields[name] = struct{}{}
+		}
+	}
+
+	sensitiveFields.Store(&fields)
+}
+
+func isSensitive(field reflect.StructField) bool {
+	fields := *sensitiveFields.Load()
+	if _, ok := fields[strings.ToLower(field.Name)]; ok {
+		return true
+	}
+
+	_, ok := fields[strings.ToLower(jsonName(field))]
+	return ok
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// Redacted wraps a value that can't carry the redacting methods itself, such
+// as a domain model, so that it is masked like a DTO when logged.
+func Redacted(v interface{}) interface {
+	fmt.Formatter
+	zapcore.ObjectMarshaler
+} {
+	return redactedValue{v}
+}
+
+type redactedValue struct {
+	v interface{}
+}
+
+func (value redactedValue) Format(state fmt.State, verb rune) {
+	FormatRedacted(state, verb, value.v)
+}
+
+func (value redactedValue) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return MarshalRedacted(enc, value.v)
+}
+
+// FormatRedacted backs the fmt.Formatter of DTOs: it prints the struct the way
+// %v and %+v would, with sensitive fields masked.
+func FormatRedacted(state fmt.State, verb rune, v interface{}) {
+	format := "%v"
+	if state.Flag('+') {
+		format = "%+v"
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(v))
+	io.WriteString(state, "{")
+	for i, written := 0, 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if written > 0 {
+			io.WriteString(state, " ")
+		}
+
+		written++
+		if state.Flag('+') {
+			io.WriteString(state, field.Name+":")
+		}
+
+		if isSensitive(field) && !value.Field(i).IsZero() {
+			io.WriteString(state, redacted)
+			continue
+		}
+
+		fmt.Fprintf(state, format, value.Field(i).Interface())
+	}
+
+	io.WriteString(state, "}")
+}
+
+// MarshalRedacted backs the zapcore.ObjectMarshaler of DTOs. Nested DTOs are
+// marshalled through their own MarshalLogObject rather than encoding/json, so
+// redaction also applies inside pages and lists.
+func MarshalRedacted(enc zapcore.ObjectEncoder, v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := jsonName(field)
+		if !field.IsExported() || key == "-" {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if isSensitive(field) {
+			if !fieldValue.IsZero() {
+				enc.AddString(key, redacted)
+			}
+
+			continue
+		}
+
+		if err := addField(enc, key, fieldValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var objectMarshalerType = reflect.TypeOf((*zapcore.ObjectMarshaler)(nil)).Elem()
+
+func addField(enc zapcore.ObjectEncoder, key string, value reflect.Value) error {
+	switch {
+	case value.Kind() == reflect.Pointer && value.IsNil():
+		return enc.AddReflected(key, nil)
+	case value.Type().Implements(objectMarshalerType):
+		return enc.AddObject(key, value.Interface().(zapcore.ObjectMarshaler))
+	case value.Kind() == reflect.Slice && value.Type().Elem().Implements(objectMarshalerType):
+		return enc.AddArray(key, zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for i := 0; i < value.Len(); i++ {
+				if value.Index(i).Kind() == reflect.Pointer && value.Index(i).IsNil() {
+					if err := arr.AppendReflected(nil); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				if err := arr.AppendObject(value.Index(i).Interface().(zapcore.ObjectMarshaler)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}))
+	default:
+		return enc.AddReflected(key, value.Interface())
+	}
+}
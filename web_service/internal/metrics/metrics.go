@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "serviceschool"
+
+// Metrics owns the service's Prometheus registry. All methods are safe to call
+// on a nil *Metrics, which is what handlers built without metrics (tests) get.
+type Metrics struct {
+	registry            *prometheus.Registry
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	lecturesCreated     prometheus.Counter
+	enrollments         prometheus.Counter
+	drops               prometheus.Counter
+	failedLogins        prometheus.Counter
+	rateLimited         *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route template and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		lecturesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "lectures_created_total",
+			Help:      "Lectures created.",
+		}),
+		enrollments: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "enrollments_total",
+			Help:      "Students enrolled into lectures.",
+		}),
+		drops: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "drops_total",
+			Help:      "Students removed from lectures.",
+		}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Requests rejected because the caller could not be identified.",
+		}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_requests_total",
+			Help:      "Requests rejected with 429 by route group.",
+		}, []string{"group"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.lecturesCreated,
+		m.enrollments,
+		m.drops,
+		m.failedLogins,
+		m.rateLimited,
+	)
+
+	return m
+}
+
+// RegisterDBStats exposes the sql.DB pool stats (open, in use, idle, wait count
+// and duration) under the given database name.
+func (m *Metrics) RegisterDBStats(sqlDB *sql.DB, dbName string) {
+	if m == nil {
+		return
+	}
+
+	m.registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, dbName))
+}
+
+// Handler serves the registry, or 404 when metrics are disabled.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveHTTPRequest(method string, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	statusLabel := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, statusLabel).Inc()
+	m.httpRequestDuration.WithLabelValues(method, route, statusLabel).Observe(duration.Seconds())
+}
+
+func (m *Metrics) LectureCreated() {
+	if m == nil {
+		return
+	}
+
+	m.lecturesCreated.Inc()
+}
+
+func (m *Metrics) StudentEnrolled() {
+	if m == nil {
+		return
+	}
+
+	m.enrollments.Inc()
+}
+
+func (m *Metrics) StudentDropped() {
+	if m == nil {
+		return
+	}
+
+	m.drops.Inc()
+}
+
+func (m *Metrics) AuthenticationFailed() {
+	if m == nil {
+		return
+	}
+
+	m.failedLogins.Inc()
+}
+
+func (m *Metrics) RateLimited(group string) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimited.WithLabelValues(group).Inc()
+}
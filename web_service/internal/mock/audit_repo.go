@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repositories/audit_repo.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	models "web_service/internal/domain/models"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAuditRepo is a mock of AuditRepo interface.
+type MockAuditRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditRepoMockRecorder
+}
+
+// MockAuditRepoMockRecorder is the mock recorder for MockAuditRepo.
+type MockAuditRepoMockRecorder struct {
+	mock *MockAuditRepo
+}
+
+// NewMockAuditRepo creates a new mock instance.
+func NewMockAuditRepo(ctrl *gomock.Controller) *MockAuditRepo {
+	mock := &MockAuditRepo{ctrl: ctrl}
+	mock.recorder = &MockAuditRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditRepo) EXPECT() *MockAuditRepoMockRecorder {
+	return m.recorder
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockAuditRepo) GetAuditEvents(ctx context.Context, pageRequest *models.PageRequest, filter *models.AuditFilter) (*models.AuditEventsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, pageRequest, filter)
+	ret0, _ := ret[0].(*models.AuditEventsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockAuditRepoMockRecorder) GetAuditEvents(ctx, pageRequest, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockAuditRepo)(nil).GetAuditEvents), ctx, pageRequest, filter)
+}
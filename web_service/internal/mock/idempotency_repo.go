@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repositories/idempotency_repo.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	models "web_service/internal/domain/models"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIdempotencyRepo is a mock of IdempotencyRepo interface.
+type MockIdempotencyRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyRepoMockRecorder
+}
+
+// MockIdempotencyRepoMockRecorder is the mock recorder for MockIdempotencyRepo.
+type MockIdempotencyRepoMockRecorder struct {
+	mock *MockIdempotencyRepo
+}
+
+// NewMockIdempotencyRepo creates a new mock instance.
+func NewMockIdempotencyRepo(ctrl *gomock.Controller) *MockIdempotencyRepo {
+	mock := &MockIdempotencyRepo{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotencyRepo) EXPECT() *MockIdempotencyRepoMockRecorder {
+	return m.recorder
+}
+
+// Complete mocks base method.
+func (m *MockIdempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockIdempotencyRepoMockRecorder) Complete(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockIdempotencyRepo)(nil).Complete), ctx, key)
+}
+
+// DeleteExpired mocks base method.
+func (m *MockIdempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, now)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockIdempotencyRepoMockRecorder) DeleteExpired(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockIdempotencyRepo)(nil).DeleteExpired), ctx, now)
+}
+
+// Release mocks base method.
+func (m *MockIdempotencyRepo) Release(ctx context.Context, key *models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockIdempotencyRepoMockRecorder) Release(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockIdempotencyRepo)(nil).Release), ctx, key)
+}
+
+// Reserve mocks base method.
+func (m *MockIdempotencyRepo) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", ctx, key)
+	ret0, _ := ret[0].(*models.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockIdempotencyRepoMockRecorder) Reserve(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockIdempotencyRepo)(nil).Reserve), ctx, key)
+}
@@ -7,9 +7,11 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 	models "web_service/internal/domain/models"
 
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 )
 
 // MockRepoLecture is a mock of RepoLecture interface.
@@ -36,59 +38,191 @@ func (m *MockRepoLecture) EXPECT() *MockRepoLectureMockRecorder {
 }
 
 // AddUserToLecture mocks base method.
-func (m *MockRepoLecture) AddUserToLecture(ctx context.Context, lecture *models.Lecture, user *models.User) error {
+func (m *MockRepoLecture) AddUserToLecture(ctx context.Context, lecture *models.Lecture, user *models.User, events ...*models.OutboxEvent) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUserToLecture", ctx, lecture, user)
+	varargs := []interface{}{ctx, lecture, user}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddUserToLecture", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUserToLecture indicates an expected call of AddUserToLecture.
-func (mr *MockRepoLectureMockRecorder) AddUserToLecture(ctx, lecture, user interface{}) *gomock.Call {
+func (mr *MockRepoLectureMockRecorder) AddUserToLecture(ctx, lecture, user interface{}, events ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserToLecture", reflect.TypeOf((*MockRepoLecture)(nil).AddUserToLecture), ctx, lecture, user)
+	varargs := append([]interface{}{ctx, lecture, user}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserToLecture", reflect.TypeOf((*MockRepoLecture)(nil).AddUserToLecture), varargs...)
 }
 
 // CreateLecture mocks base method.
-func (m *MockRepoLecture) CreateLecture(ctx context.Context, lecture *models.Lecture) (string, error) {
+func (m *MockRepoLecture) CreateLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateLecture", ctx, lecture)
+	varargs := []interface{}{ctx, lecture}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateLecture", varargs...)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateLecture indicates an expected call of CreateLecture.
-func (mr *MockRepoLectureMockRecorder) CreateLecture(ctx, lecture interface{}) *gomock.Call {
+func (mr *MockRepoLectureMockRecorder) CreateLecture(ctx, lecture interface{}, events ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLecture", reflect.TypeOf((*MockRepoLecture)(nil).CreateLecture), ctx, lecture)
+	varargs := append([]interface{}{ctx, lecture}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLecture", reflect.TypeOf((*MockRepoLecture)(nil).CreateLecture), varargs...)
+}
+
+// DeleteLecture mocks base method.
+func (m *MockRepoLecture) DeleteLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, lecture}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteLecture", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLecture indicates an expected call of DeleteLecture.
+func (mr *MockRepoLectureMockRecorder) DeleteLecture(ctx, lecture interface{}, events ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, lecture}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLecture", reflect.TypeOf((*MockRepoLecture)(nil).DeleteLecture), varargs...)
 }
 
 // DropUserFromLecture mocks base method.
-func (m *MockRepoLecture) DropUserFromLecture(ctx context.Context, lecture *models.Lecture, user *models.User) error {
+func (m *MockRepoLecture) DropUserFromLecture(ctx context.Context, lecture *models.Lecture, user *models.User, events ...*models.OutboxEvent) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DropUserFromLecture", ctx, lecture, user)
+	varargs := []interface{}{ctx, lecture, user}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DropUserFromLecture", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DropUserFromLecture indicates an expected call of DropUserFromLecture.
-func (mr *MockRepoLectureMockRecorder) DropUserFromLecture(ctx, lecture, user interface{}) *gomock.Call {
+func (mr *MockRepoLectureMockRecorder) DropUserFromLecture(ctx, lecture, user interface{}, events ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, lecture, user}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropUserFromLecture", reflect.TypeOf((*MockRepoLecture)(nil).DropUserFromLecture), varargs...)
+}
+
+// GetLectureByID mocks base method.
+func (m *MockRepoLecture) GetLectureByID(ctx context.Context, lectureID *uuid.UUID) (*models.Lecture, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLectureByID", ctx, lectureID)
+	ret0, _ := ret[0].(*models.Lecture)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLectureByID indicates an expected call of GetLectureByID.
+func (mr *MockRepoLectureMockRecorder) GetLectureByID(ctx, lectureID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLectureByID", reflect.TypeOf((*MockRepoLecture)(nil).GetLectureByID), ctx, lectureID)
+}
+
+// GetLectureStudents mocks base method.
+func (m *MockRepoLecture) GetLectureStudents(ctx context.Context, lecture *models.Lecture) ([]*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLectureStudents", ctx, lecture)
+	ret0, _ := ret[0].([]*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLectureStudents indicates an expected call of GetLectureStudents.
+func (mr *MockRepoLectureMockRecorder) GetLectureStudents(ctx, lecture interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLectureStudents", reflect.TypeOf((*MockRepoLecture)(nil).GetLectureStudents), ctx, lecture)
+}
+
+// GetLectureStudentsPP mocks base method.
+func (m *MockRepoLecture) GetLectureStudentsPP(ctx context.Context, lecture *models.Lecture, pageRequest *models.PageRequest) (*models.UsersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLectureStudentsPP", ctx, lecture, pageRequest)
+	ret0, _ := ret[0].(*models.UsersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLectureStudentsPP indicates an expected call of GetLectureStudentsPP.
+func (mr *MockRepoLectureMockRecorder) GetLectureStudentsPP(ctx, lecture, pageRequest interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropUserFromLecture", reflect.TypeOf((*MockRepoLecture)(nil).DropUserFromLecture), ctx, lecture, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLectureStudentsPP", reflect.TypeOf((*MockRepoLecture)(nil).GetLectureStudentsPP), ctx, lecture, pageRequest)
 }
 
 // GetLecturesAndStudentsPP mocks base method.
-func (m *MockRepoLecture) GetLecturesAndStudentsPP(ctx context.Context, page, perPage int) ([]*models.Lecture, error) {
+func (m *MockRepoLecture) GetLecturesAndStudentsPP(ctx context.Context, pageRequest *models.PageRequest, filter *models.LectureFilter) (*models.LecturesPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLecturesAndStudentsPP", ctx, page, perPage)
-	ret0, _ := ret[0].([]*models.Lecture)
+	ret := m.ctrl.Call(m, "GetLecturesAndStudentsPP", ctx, pageRequest, filter)
+	ret0, _ := ret[0].(*models.LecturesPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLecturesAndStudentsPP indicates an expected call of GetLecturesAndStudentsPP.
-func (mr *MockRepoLectureMockRecorder) GetLecturesAndStudentsPP(ctx, page, perPage interface{}) *gomock.Call {
+func (mr *MockRepoLectureMockRecorder) GetLecturesAndStudentsPP(ctx, pageRequest, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLecturesAndStudentsPP", reflect.TypeOf((*MockRepoLecture)(nil).GetLecturesAndStudentsPP), ctx, pageRequest, filter)
+}
+
+// GetLecturesStartingBetween mocks base method.
+func (m *MockRepoLecture) GetLecturesStartingBetween(ctx context.Context, from, to time.Time) ([]*models.Lecture, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLecturesStartingBetween", ctx, from, to)
+	ret0, _ := ret[0].([]*models.Lecture)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLecturesStartingBetween indicates an expected call of GetLecturesStartingBetween.
+func (mr *MockRepoLectureMockRecorder) GetLecturesStartingBetween(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLecturesStartingBetween", reflect.TypeOf((*MockRepoLecture)(nil).GetLecturesStartingBetween), ctx, from, to)
+}
+
+// UpdateLecture mocks base method.
+func (m *MockRepoLecture) UpdateLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, lecture}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateLecture", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLecture indicates an expected call of UpdateLecture.
+func (mr *MockRepoLectureMockRecorder) UpdateLecture(ctx, lecture interface{}, events ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, lecture}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLecture", reflect.TypeOf((*MockRepoLecture)(nil).UpdateLecture), varargs...)
+}
+
+// UpdateLectureStatus mocks base method.
+func (m *MockRepoLecture) UpdateLectureStatus(ctx context.Context, lecture *models.Lecture, from models.LectureStatus, events ...*models.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, lecture, from}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateLectureStatus", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLectureStatus indicates an expected call of UpdateLectureStatus.
+func (mr *MockRepoLectureMockRecorder) UpdateLectureStatus(ctx, lecture, from interface{}, events ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLecturesAndStudentsPP", reflect.TypeOf((*MockRepoLecture)(nil).GetLecturesAndStudentsPP), ctx, page, perPage)
+	varargs := append([]interface{}{ctx, lecture, from}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLectureStatus", reflect.TypeOf((*MockRepoLecture)(nil).UpdateLectureStatus), varargs...)
 }
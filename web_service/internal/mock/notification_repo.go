@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repositories/notification_repo.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	models "web_service/internal/domain/models"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockNotificationRepo is a mock of NotificationRepo interface.
+type MockNotificationRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotificationRepoMockRecorder
+}
+
+// MockNotificationRepoMockRecorder is the mock recorder for MockNotificationRepo.
+type MockNotificationRepoMockRecorder struct {
+	mock *MockNotificationRepo
+}
+
+// NewMockNotificationRepo creates a new mock instance.
+func NewMockNotificationRepo(ctrl *gomock.Controller) *MockNotificationRepo {
+	mock := &MockNotificationRepo{ctrl: ctrl}
+	mock.recorder = &MockNotificationRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotificationRepo) EXPECT() *MockNotificationRepoMockRecorder {
+	return m.recorder
+}
+
+// ClaimNotifications mocks base method.
+func (m *MockNotificationRepo) ClaimNotifications(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimNotifications", ctx, now, limit, lease)
+	ret0, _ := ret[0].([]*models.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimNotifications indicates an expected call of ClaimNotifications.
+func (mr *MockNotificationRepoMockRecorder) ClaimNotifications(ctx, now, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimNotifications", reflect.TypeOf((*MockNotificationRepo)(nil).ClaimNotifications), ctx, now, limit, lease)
+}
+
+// EnqueueNotifications mocks base method.
+func (m *MockNotificationRepo) EnqueueNotifications(ctx context.Context, notifications []*models.Notification) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueNotifications", ctx, notifications)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueNotifications indicates an expected call of EnqueueNotifications.
+func (mr *MockNotificationRepoMockRecorder) EnqueueNotifications(ctx, notifications interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueNotifications", reflect.TypeOf((*MockNotificationRepo)(nil).EnqueueNotifications), ctx, notifications)
+}
+
+// UpdateNotification mocks base method.
+func (m *MockNotificationRepo) UpdateNotification(ctx context.Context, notification *models.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateNotification", ctx, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateNotification indicates an expected call of UpdateNotification.
+func (mr *MockNotificationRepoMockRecorder) UpdateNotification(ctx, notification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNotification", reflect.TypeOf((*MockNotificationRepo)(nil).UpdateNotification), ctx, notification)
+}
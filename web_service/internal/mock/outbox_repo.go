@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repositories/outbox_repo.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	models "web_service/internal/domain/models"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockOutboxRepo is a mock of OutboxRepo interface.
+type MockOutboxRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxRepoMockRecorder
+}
+
+// MockOutboxRepoMockRecorder is the mock recorder for MockOutboxRepo.
+type MockOutboxRepoMockRecorder struct {
+	mock *MockOutboxRepo
+}
+
+// NewMockOutboxRepo creates a new mock instance.
+func NewMockOutboxRepo(ctrl *gomock.Controller) *MockOutboxRepo {
+	mock := &MockOutboxRepo{ctrl: ctrl}
+	mock.recorder = &MockOutboxRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOutboxRepo) EXPECT() *MockOutboxRepoMockRecorder {
+	return m.recorder
+}
+
+// ClaimOutboxEvents mocks base method.
+func (m *MockOutboxRepo) ClaimOutboxEvents(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOutboxEvents", ctx, now, limit, lease)
+	ret0, _ := ret[0].([]*models.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOutboxEvents indicates an expected call of ClaimOutboxEvents.
+func (mr *MockOutboxRepoMockRecorder) ClaimOutboxEvents(ctx, now, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOutboxEvents", reflect.TypeOf((*MockOutboxRepo)(nil).ClaimOutboxEvents), ctx, now, limit, lease)
+}
+
+// DeletePublishedOutboxEvents mocks base method.
+func (m *MockOutboxRepo) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePublishedOutboxEvents", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePublishedOutboxEvents indicates an expected call of DeletePublishedOutboxEvents.
+func (mr *MockOutboxRepoMockRecorder) DeletePublishedOutboxEvents(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePublishedOutboxEvents", reflect.TypeOf((*MockOutboxRepo)(nil).DeletePublishedOutboxEvents), ctx, before)
+}
+
+// MarkOutboxEventFailed mocks base method.
+func (m *MockOutboxRepo) MarkOutboxEventFailed(ctx context.Context, event *models.OutboxEvent, nextAttemptAt time.Time, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventFailed", ctx, event, nextAttemptAt, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventFailed indicates an expected call of MarkOutboxEventFailed.
+func (mr *MockOutboxRepoMockRecorder) MarkOutboxEventFailed(ctx, event, nextAttemptAt, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventFailed", reflect.TypeOf((*MockOutboxRepo)(nil).MarkOutboxEventFailed), ctx, event, nextAttemptAt, reason)
+}
+
+// MarkOutboxEventPublished mocks base method.
+func (m *MockOutboxRepo) MarkOutboxEventPublished(ctx context.Context, event *models.OutboxEvent, publishedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventPublished", ctx, event, publishedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventPublished indicates an expected call of MarkOutboxEventPublished.
+func (mr *MockOutboxRepoMockRecorder) MarkOutboxEventPublished(ctx, event, publishedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventPublished", reflect.TypeOf((*MockOutboxRepo)(nil).MarkOutboxEventPublished), ctx, event, publishedAt)
+}
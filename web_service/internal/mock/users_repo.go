@@ -10,6 +10,7 @@ import (
 	models "web_service/internal/domain/models"
 
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 )
 
 // MockUserRepo is a mock of UserRepo interface.
@@ -36,16 +37,94 @@ func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
 }
 
 // CreateUser mocks base method.
-func (m *MockUserRepo) CreateUser(ctx context.Context, user *models.User) (string, error) {
+func (m *MockUserRepo) CreateUser(ctx context.Context, user *models.User, events ...*models.OutboxEvent) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	varargs := []interface{}{ctx, user}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateUser", varargs...)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockUserRepoMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) CreateUser(ctx, user interface{}, events ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserRepo)(nil).CreateUser), ctx, user)
+	varargs := append([]interface{}{ctx, user}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserRepo)(nil).CreateUser), varargs...)
+}
+
+// DeleteUser mocks base method.
+func (m *MockUserRepo) DeleteUser(ctx context.Context, user *models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserRepoMockRecorder) DeleteUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserRepo)(nil).DeleteUser), ctx, user)
+}
+
+// GetUserByID mocks base method.
+func (m *MockUserRepo) GetUserByID(ctx context.Context, userID *uuid.UUID) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, userID)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockUserRepoMockRecorder) GetUserByID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserRepo)(nil).GetUserByID), ctx, userID)
+}
+
+// GetUserLectures mocks base method.
+func (m *MockUserRepo) GetUserLectures(ctx context.Context, user *models.User, filter *models.ScheduleFilter) ([]*models.Lecture, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLectures", ctx, user, filter)
+	ret0, _ := ret[0].([]*models.Lecture)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLectures indicates an expected call of GetUserLectures.
+func (mr *MockUserRepoMockRecorder) GetUserLectures(ctx, user, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLectures", reflect.TypeOf((*MockUserRepo)(nil).GetUserLectures), ctx, user, filter)
+}
+
+// GetUsers mocks base method.
+func (m *MockUserRepo) GetUsers(ctx context.Context, pageRequest *models.PageRequest) (*models.UsersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", ctx, pageRequest)
+	ret0, _ := ret[0].(*models.UsersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockUserRepoMockRecorder) GetUsers(ctx, pageRequest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockUserRepo)(nil).GetUsers), ctx, pageRequest)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUserRepo) UpdateUser(ctx context.Context, user *models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserRepoMockRecorder) UpdateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserRepo)(nil).UpdateUser), ctx, user)
 }
@@ -0,0 +1,155 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repositories/webhook_repo.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	models "web_service/internal/domain/models"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+)
+
+// MockWebhookRepo is a mock of WebhookRepo interface.
+type MockWebhookRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepoMockRecorder
+}
+
+// MockWebhookRepoMockRecorder is the mock recorder for MockWebhookRepo.
+type MockWebhookRepoMockRecorder struct {
+	mock *MockWebhookRepo
+}
+
+// NewMockWebhookRepo creates a new mock instance.
+func NewMockWebhookRepo(ctrl *gomock.Controller) *MockWebhookRepo {
+	mock := &MockWebhookRepo{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepo) EXPECT() *MockWebhookRepoMockRecorder {
+	return m.recorder
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockWebhookRepo) ClaimWebhookDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", ctx, now, limit, lease)
+	ret0, _ := ret[0].([]*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockWebhookRepoMockRecorder) ClaimWebhookDeliveries(ctx, now, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockWebhookRepo)(nil).ClaimWebhookDeliveries), ctx, now, limit, lease)
+}
+
+// CreateWebhookSubscription mocks base method.
+func (m *MockWebhookRepo) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", ctx, subscription)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockWebhookRepoMockRecorder) CreateWebhookSubscription(ctx, subscription interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockWebhookRepo)(nil).CreateWebhookSubscription), ctx, subscription)
+}
+
+// DeleteWebhookSubscription mocks base method.
+func (m *MockWebhookRepo) DeleteWebhookSubscription(ctx context.Context, subscriptionID *uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscription", ctx, subscriptionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookSubscription indicates an expected call of DeleteWebhookSubscription.
+func (mr *MockWebhookRepoMockRecorder) DeleteWebhookSubscription(ctx, subscriptionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscription", reflect.TypeOf((*MockWebhookRepo)(nil).DeleteWebhookSubscription), ctx, subscriptionID)
+}
+
+// EnqueueWebhookDeliveries mocks base method.
+func (m *MockWebhookRepo) EnqueueWebhookDeliveries(ctx context.Context, event *models.OutboxEvent, body []byte) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueWebhookDeliveries", ctx, event, body)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueWebhookDeliveries indicates an expected call of EnqueueWebhookDeliveries.
+func (mr *MockWebhookRepoMockRecorder) EnqueueWebhookDeliveries(ctx, event, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueWebhookDeliveries", reflect.TypeOf((*MockWebhookRepo)(nil).EnqueueWebhookDeliveries), ctx, event, body)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockWebhookRepo) GetWebhookDeliveries(ctx context.Context, pageRequest *models.PageRequest, filter *models.WebhookDeliveryFilter) (*models.WebhookDeliveriesPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", ctx, pageRequest, filter)
+	ret0, _ := ret[0].(*models.WebhookDeliveriesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockWebhookRepoMockRecorder) GetWebhookDeliveries(ctx, pageRequest, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockWebhookRepo)(nil).GetWebhookDeliveries), ctx, pageRequest, filter)
+}
+
+// GetWebhookSubscriptions mocks base method.
+func (m *MockWebhookRepo) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookSubscriptions", ctx)
+	ret0, _ := ret[0].([]*models.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookSubscriptions indicates an expected call of GetWebhookSubscriptions.
+func (mr *MockWebhookRepoMockRecorder) GetWebhookSubscriptions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookSubscriptions", reflect.TypeOf((*MockWebhookRepo)(nil).GetWebhookSubscriptions), ctx)
+}
+
+// RecordWebhookDeliveryAttempt mocks base method.
+func (m *MockWebhookRepo) RecordWebhookDeliveryAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordWebhookDeliveryAttempt", ctx, delivery, attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordWebhookDeliveryAttempt indicates an expected call of RecordWebhookDeliveryAttempt.
+func (mr *MockWebhookRepoMockRecorder) RecordWebhookDeliveryAttempt(ctx, delivery, attempt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookDeliveryAttempt", reflect.TypeOf((*MockWebhookRepo)(nil).RecordWebhookDeliveryAttempt), ctx, delivery, attempt)
+}
+
+// RetryWebhookDelivery mocks base method.
+func (m *MockWebhookRepo) RetryWebhookDelivery(ctx context.Context, deliveryID *uuid.UUID, now time.Time) (*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryWebhookDelivery", ctx, deliveryID, now)
+	ret0, _ := ret[0].(*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetryWebhookDelivery indicates an expected call of RetryWebhookDelivery.
+func (mr *MockWebhookRepoMockRecorder) RetryWebhookDelivery(ctx, deliveryID, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryWebhookDelivery", reflect.TypeOf((*MockWebhookRepo)(nil).RetryWebhookDelivery), ctx, deliveryID, now)
+}
@@ -0,0 +1,94 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+	"web_service/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// Dispatcher is the outbox publisher for notifications: it queues an email
+// for every student an enrolment, a drop or a cancellation concerns. The
+// Sender sends them. A cancellation notifies the students its event lists,
+// the ones enrolled when it happened. Deleting a lecture notifies nobody: only
+// a lecture without students can be deleted. Other events are ignored.
+type Dispatcher struct {
+	repo repositories.NotificationRepo
+}
+
+func NewDispatcher(repo repositories.NotificationRepo) *Dispatcher {
+	return &Dispatcher{repo: repo}
+}
+
+func (dispatcher *Dispatcher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	var notifications []*models.Notification
+	switch event.Type {
+	case models.EventStudentEnrolled:
+		payload := models.StudentEnrolledEvent{}
+		notification, err := enrolmentNotification(models.NotificationEnrollment, event, &payload, &payload.LectureID, &payload.StudentID)
+		if err != nil {
+			return err
+		}
+
+		notifications = append(notifications, notification)
+	case models.EventStudentDropped:
+		payload := models.StudentDroppedEvent{}
+		notification, err := enrolmentNotification(models.NotificationDrop, event, &payload, &payload.LectureID, &payload.StudentID)
+		if err != nil {
+			return err
+		}
+
+		notifications = append(notifications, notification)
+	case models.EventLectureUpdated:
+		payload := models.LectureUpdatedEvent{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return apperrors.NotificationRepoErr.AppendMessage(err)
+		}
+
+		if payload.Status != string(models.LectureStatusCancelled) {
+			return nil
+		}
+
+		lectureID, err := uuid.Parse(payload.LectureID)
+		if err != nil {
+			return apperrors.NotificationRepoErr.AppendMessage(err)
+		}
+
+		for _, studentId := range payload.StudentIDs {
+			studentID, err := uuid.Parse(studentId)
+			if err != nil {
+				return apperrors.NotificationRepoErr.AppendMessage(err)
+			}
+
+			notifications = append(notifications, models.NewNotification(models.NotificationCancellation, &lectureID, &studentID, ""))
+		}
+	default:
+		return nil
+	}
+
+	_, err := dispatcher.repo.EnqueueNotifications(ctx, notifications)
+	return err
+}
+
+// enrolmentNotification decodes an enrolment event into payload and builds
+// its notification. The event ID tells apart enrolling again after a drop.
+func enrolmentNotification(kind string, event *models.OutboxEvent, payload interface{}, lectureId *string, studentId *string) (*models.Notification, error) {
+	if err := json.Unmarshal(event.Payload, payload); err != nil {
+		return nil, apperrors.NotificationRepoErr.AppendMessage(err)
+	}
+
+	lectureID, err := uuid.Parse(*lectureId)
+	if err != nil {
+		return nil, apperrors.NotificationRepoErr.AppendMessage(err)
+	}
+
+	studentID, err := uuid.Parse(*studentId)
+	if err != nil {
+		return nil, apperrors.NotificationRepoErr.AppendMessage(err)
+	}
+
+	return models.NewNotification(kind, &lectureID, &studentID, event.ID.String()), nil
+}
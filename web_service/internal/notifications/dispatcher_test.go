@@ -0,0 +1,89 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+	"web_service/internal/domain/mappers"
+	"web_service/internal/domain/models"
+	"web_service/internal/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// queueInto makes repo record every notification it is asked to enqueue.
+func queueInto(repo *mock.MockNotificationRepo, queued *[]*models.Notification) {
+	repo.EXPECT().EnqueueNotifications(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, notifications []*models.Notification) (int64, error) {
+		*queued = append(*queued, notifications...)
+		return int64(len(notifications)), nil
+	}).AnyTimes()
+}
+
+func TestDispatcher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	notificationRepoMock := mock.NewMockNotificationRepo(ctrl)
+	var queued []*models.Notification
+	queueInto(notificationRepoMock, &queued)
+
+	// Enrolments and drops notify the student; a cancellation notifies the
+	// students enrolled when it happened; other lecture updates and deletions
+	// notify nobody.
+	lecture := testLecture()
+	student, otherStudent := testStudents()
+	enrolled, err := mappers.MapEnrollmentToStudentEnrolledEvent(lecture, student)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := mappers.MapEnrollmentToStudentDroppedEvent(lecture, student)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := mappers.MapLectureToLectureUpdatedEvent(lecture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, err := mappers.MapLectureToLectureUpdatedEvent(&models.Lecture{ID: &testLectureID, Status: models.LectureStatusCancelled, CancelReason: "Speaker is ill",
+		Students: []*models.User{student, otherStudent}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := mappers.MapLectureToLectureDeletedEvent(lecture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewDispatcher(notificationRepoMock)
+	for _, event := range []*models.OutboxEvent{enrolled, dropped, updated, cancelled, deleted} {
+		assert.NoError(t, dispatcher.Publish(context.Background(), event))
+	}
+
+	if assert.Len(t, queued, 4) {
+		assert.Equal(t, models.NotificationEnrollment, queued[0].Kind)
+		assert.Equal(t, models.NotificationDrop, queued[1].Kind)
+		assert.Equal(t, models.NotificationCancellation, queued[2].Kind)
+		assert.Equal(t, models.NotificationCancellation, queued[3].Kind)
+		assert.Equal(t, testStudentID, *queued[2].UserID)
+		assert.Equal(t, testOtherStudentID, *queued[3].UserID)
+		assert.Contains(t, queued[0].DedupKey, enrolled.ID.String())
+		assert.NotEqual(t, queued[0].DedupKey, queued[1].DedupKey)
+		for _, notification := range queued {
+			assert.Equal(t, testLectureID, *notification.LectureID)
+			assert.Equal(t, models.NotificationPending, notification.Status)
+		}
+	}
+
+	// Redelivering an event queues the same dedup keys, so the repository
+	// can ignore the duplicates.
+	queued = nil
+	assert.NoError(t, dispatcher.Publish(context.Background(), enrolled))
+	assert.NoError(t, dispatcher.Publish(context.Background(), enrolled))
+	if assert.Len(t, queued, 2) {
+		assert.Equal(t, queued[0].DedupKey, queued[1].DedupKey)
+	}
+}
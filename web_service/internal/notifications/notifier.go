@@ -0,0 +1,47 @@
+package notifications
+
+import (
+	"context"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// Email is a rendered notification. ID is the notification's, so a resent
+// email keeps its Message-ID.
+type Email struct {
+	ID      string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier hands an email over for delivery.
+type Notifier interface {
+	Notify(ctx context.Context, email *Email) error
+}
+
+// NewNotifier builds the notifier named by cfg.Notifier.
+func NewNotifier(cfg *config.NotificationsConfig, logger *zap.SugaredLogger) (Notifier, error) {
+	switch cfg.Notifier {
+	case "", "log":
+		return &LogNotifier{logger: logger}, nil
+	case "smtp":
+		return NewSMTPNotifier(cfg)
+	default:
+		return nil, apperrors.NotifierErr.AppendMessage("unknown notifier", cfg.Notifier)
+	}
+}
+
+// LogNotifier sends nothing and only logs that an email would have gone out.
+// The recipient is left out of the log.
+type LogNotifier struct {
+	logger *zap.SugaredLogger
+}
+
+func (notifier *LogNotifier) Notify(ctx context.Context, email *Email) error {
+	notifier.logger.Infof("Notification %s: %s", email.ID, email.Subject)
+	return nil
+}
@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"context"
+	"strconv"
+	"time"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+	"web_service/internal/repositories"
+
+	"go.uber.org/zap"
+)
+
+// Reminders queues a reminder for every student of a published lecture that
+// starts within the reminder lead. Each lecture date is reminded of once, so
+// a lecture moved to a later date gets a fresh reminder.
+type Reminders struct {
+	repo        repositories.NotificationRepo
+	lectureRepo repositories.RepoLecture
+	logger      *zap.SugaredLogger
+	cfg         *config.NotificationsConfig
+}
+
+func NewReminders(repo repositories.NotificationRepo, lectureRepo repositories.RepoLecture, cfg *config.NotificationsConfig, logger *zap.SugaredLogger) *Reminders {
+	return &Reminders{repo: repo, lectureRepo: lectureRepo, logger: logger, cfg: cfg}
+}
+
+// Run queues reminders every reminder interval until ctx is done.
+func (reminders *Reminders) Run(ctx context.Context) {
+	tick := time.NewTicker(reminders.cfg.ReminderInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if _, err := reminders.EnqueueOnce(ctx, time.Now()); err != nil {
+				reminders.logger.Errorf("Queueing reminders: %v", err)
+			}
+		}
+	}
+}
+
+// EnqueueOnce queues the reminders due at now and returns how many were new.
+func (reminders *Reminders) EnqueueOnce(ctx context.Context, now time.Time) (int64, error) {
+	lectures, err := reminders.lectureRepo.GetLecturesStartingBetween(ctx, now, now.Add(reminders.cfg.ReminderLead))
+	if err != nil {
+		return 0, err
+	}
+
+	var notifications []*models.Notification
+	for _, lecture := range lectures {
+		for _, student := range lecture.Students {
+			notifications = append(notifications, models.NewNotification(models.NotificationReminder, lecture.ID, student.ID, strconv.FormatInt(lecture.Date.Unix(), 10)))
+		}
+	}
+
+	queued, err := reminders.repo.EnqueueNotifications(ctx, notifications)
+	if err != nil {
+		return 0, err
+	}
+
+	if queued > 0 {
+		reminders.logger.Infof("Queued %d lecture reminders", queued)
+	}
+
+	return queued, nil
+}
@@ -0,0 +1,51 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+	"web_service/internal/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestReminders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	notificationRepoMock := mock.NewMockNotificationRepo(ctrl)
+	lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+	var queued []*models.Notification
+	queueInto(notificationRepoMock, &queued)
+
+	// Reminders go to the students of lectures starting within the lead time,
+	// once per student and lecture date.
+	cfg := config.Default().Notifications
+	student, otherStudent := testStudents()
+	upcoming := testLecture()
+	upcoming.Students = []*models.User{student, otherStudent}
+	now := upcoming.Date.Add(-time.Hour)
+	lectureRepoMock.EXPECT().GetLecturesStartingBetween(gomock.Any(), now, now.Add(cfg.ReminderLead)).Return([]*models.Lecture{upcoming}, nil).Times(2)
+	reminders := NewReminders(notificationRepoMock, lectureRepoMock, cfg, zap.NewNop().Sugar())
+	reminded, err := reminders.EnqueueOnce(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), reminded)
+	if assert.Len(t, queued, 2) {
+		assert.Equal(t, models.NotificationReminder, queued[0].Kind)
+		assert.Equal(t, fmt.Sprintf("reminder:%s:%s:%d", testLectureID, testStudentID, upcoming.Date.Unix()), queued[0].DedupKey)
+		assert.Equal(t, testOtherStudentID, *queued[1].UserID)
+	}
+
+	// A second pass queues the same keys, which the repository ignores.
+	_, err = reminders.EnqueueOnce(context.Background(), now)
+	assert.NoError(t, err)
+	if assert.Len(t, queued, 4) {
+		assert.Equal(t, queued[0].DedupKey, queued[2].DedupKey)
+		assert.Equal(t, queued[1].DedupKey, queued[3].DedupKey)
+	}
+}
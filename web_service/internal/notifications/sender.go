@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"context"
+	"time"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+	"web_service/internal/outbox"
+	"web_service/internal/repositories"
+
+	"go.uber.org/zap"
+)
+
+// Sender renders queued notifications in the student's locale and hands them
+// to the notifier. A failed email is retried with exponential backoff and
+// marked failed once it has used up its attempts. One that no longer applies,
+// because the student or lecture is gone or a reminded lecture is no longer
+// published, is skipped.
+type Sender struct {
+	repo      repositories.NotificationRepo
+	notifier  Notifier
+	templates *Templates
+	logger    *zap.SugaredLogger
+	cfg       *config.NotificationsConfig
+}
+
+func NewSender(repo repositories.NotificationRepo, notifier Notifier, templates *Templates, cfg *config.NotificationsConfig, logger *zap.SugaredLogger) *Sender {
+	return &Sender{repo: repo, notifier: notifier, templates: templates, logger: logger, cfg: cfg}
+}
+
+// Run sends due notifications every poll interval until ctx is done.
+func (sender *Sender) Run(ctx context.Context) {
+	poll := time.NewTicker(sender.cfg.PollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			for ctx.Err() == nil {
+				claimed, err := sender.SendOnce(ctx)
+				if err != nil || claimed < sender.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// SendOnce claims one batch of due notifications and attempts each of them.
+// It returns how many were claimed, whether or not they went out.
+func (sender *Sender) SendOnce(ctx context.Context) (int, error) {
+	notifications, err := sender.repo.ClaimNotifications(ctx, time.Now(), sender.cfg.BatchSize, sender.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var recordErr error
+	for _, notification := range notifications {
+		if err := sender.send(ctx, notification); err != nil && recordErr == nil {
+			recordErr = err
+		}
+	}
+
+	return len(notifications), recordErr
+}
+
+// send attempts notification and records the outcome. An outcome that can't
+// be recorded leaves the notification claimed, so it is attempted again once
+// the lease runs out.
+func (sender *Sender) send(ctx context.Context, notification *models.Notification) error {
+	if reason := skipReason(notification); reason != "" {
+		notification.Status, notification.LastError = models.NotificationSkipped, reason
+		sender.logger.Infof("Notification %s skipped: %s", notification.ID, reason)
+		return sender.record(ctx, notification)
+	}
+
+	email, err := sender.templates.Render(notification.Kind, notification.User.Locale, templateData(notification))
+	if err == nil {
+		email.ID, email.To = notification.ID.String(), notification.User.Email
+		err = sender.notifier.Notify(ctx, email)
+	}
+
+	notification.Attempts++
+	switch {
+	case err == nil:
+		sentAt := time.Now()
+		notification.Status, notification.SentAt, notification.LastError = models.NotificationSent, &sentAt, ""
+	case notification.Attempts >= sender.cfg.MaxAttempts:
+		notification.Status, notification.LastError = models.NotificationFailed, err.Error()
+		sender.logger.Errorf("Notification %s (%s) failed after %d attempts: %v", notification.ID, notification.Kind, notification.Attempts, err)
+	default:
+		notification.LastError = err.Error()
+		notification.NextAttemptAt = time.Now().Add(outbox.Backoff(notification.Attempts, sender.cfg.RetryBackoff, sender.cfg.RetryBackoffMax))
+		sender.logger.Warnf("Notification %s (%s) failed, attempt %d, retrying at %v: %v", notification.ID, notification.Kind, notification.Attempts, notification.NextAttemptAt, err)
+	}
+
+	return sender.record(ctx, notification)
+}
+
+func (sender *Sender) record(ctx context.Context, notification *models.Notification) error {
+	if err := sender.repo.UpdateNotification(ctx, notification); err != nil {
+		sender.logger.Errorf("Recording notification %s as %s, it will be attempted again: %v", notification.ID, notification.Status, err)
+		return err
+	}
+
+	return nil
+}
+
+func skipReason(notification *models.Notification) string {
+	switch {
+	case notification.User == nil:
+		return "student no longer exists"
+	case notification.Lecture == nil:
+		return "lecture no longer exists"
+	case notification.Kind == models.NotificationReminder && notification.Lecture.Status != models.LectureStatusPublished:
+		return "lecture is no longer published"
+	default:
+		return ""
+	}
+}
+
+func templateData(notification *models.Notification) *TemplateData {
+	return &TemplateData{
+		FirstName:    notification.User.FirstName,
+		LastName:     notification.User.LastName,
+		LectureTitle: notification.Lecture.Title,
+		Speaker:      notification.Lecture.Speaker,
+		Location:     notification.Lecture.Location,
+		Date:         notification.Lecture.Date,
+		Duration:     notification.Lecture.Duration,
+		CancelReason: notification.Lecture.CancelReason,
+	}
+}
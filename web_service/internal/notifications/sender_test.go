@@ -0,0 +1,127 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+	"web_service/internal/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// claimedNotification is a notification the sender has claimed, with the user
+// and lecture it was loaded with.
+func claimedNotification(kind string, user *models.User, lecture *models.Lecture, attempts int) *models.Notification {
+	notification := models.NewNotification(kind, &testLectureID, &testStudentID, "")
+	notification.User, notification.Lecture, notification.Attempts = user, lecture, attempts
+	return notification
+}
+
+func TestSender(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	smtpServer := newFakeSMTPServer(t, "bounce@")
+	defer smtpServer.listener.Close()
+	cfg := smtpConfig(t, smtpServer)
+	notifier, err := NewNotifier(cfg, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sender renders each email in the student's locale and sends it over
+	// SMTP, retrying a rejected one and giving up after the last attempt.
+	lecture := testLecture()
+	student, otherStudent := testStudents()
+	bouncing := &models.User{ID: &testOtherStudentID, Email: "bounce@example.com", FirstName: "Bob"}
+	cancelledLecture := testLecture()
+	cancelledLecture.Status = models.LectureStatusCancelled
+	sent := claimedNotification(models.NotificationEnrollment, student, lecture, 0)
+	sentRu := claimedNotification(models.NotificationReminder, otherStudent, lecture, 0)
+	retried := claimedNotification(models.NotificationEnrollment, bouncing, lecture, 2)
+	failed := claimedNotification(models.NotificationEnrollment, bouncing, lecture, cfg.MaxAttempts-1)
+	lectureGone := claimedNotification(models.NotificationCancellation, student, nil, 0)
+	notPublished := claimedNotification(models.NotificationReminder, student, cancelledLecture, 0)
+	claimed := []*models.Notification{sent, sentRu, retried, failed, lectureGone, notPublished}
+	notificationRepoMock := mock.NewMockNotificationRepo(ctrl)
+	notificationRepoMock.EXPECT().ClaimNotifications(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return(claimed, nil).Times(1)
+	notificationRepoMock.EXPECT().UpdateNotification(gomock.Any(), gomock.Any()).Return(nil).Times(len(claimed))
+
+	start := time.Now()
+	count, err := NewSender(notificationRepoMock, notifier, templates, cfg, zap.NewNop().Sugar()).SendOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, len(claimed), count)
+
+	assert.Equal(t, models.NotificationSent, sent.Status)
+	assert.NotNil(t, sent.SentAt)
+	assert.Equal(t, 1, sent.Attempts)
+	assert.Equal(t, models.NotificationSent, sentRu.Status)
+	assert.Equal(t, models.NotificationPending, retried.Status)
+	assert.Equal(t, 3, retried.Attempts)
+	assert.WithinDuration(t, start.Add(4*cfg.RetryBackoff), retried.NextAttemptAt, time.Second)
+	assert.Contains(t, retried.LastError, "550")
+	assert.Equal(t, models.NotificationFailed, failed.Status)
+	assert.Equal(t, cfg.MaxAttempts, failed.Attempts)
+	assert.Equal(t, models.NotificationSkipped, lectureGone.Status)
+	assert.Equal(t, 0, lectureGone.Attempts)
+	assert.Equal(t, models.NotificationSkipped, notPublished.Status)
+
+	smtpServer.mu.Lock()
+	messages := smtpServer.messages
+	smtpServer.mu.Unlock()
+	if assert.Len(t, messages, 2) {
+		subject := decodeSubject(t, messages[0])
+		assert.Equal(t, "You are enrolled in Go <Concurrency>", subject)
+		assert.Equal(t, "<ann@example.com>", messages[0].Header.Get("To"))
+		assert.Equal(t, "<"+sent.ID.String()+"@school.example>", messages[0].Header.Get("Message-ID"))
+		alternatives := readAlternatives(t, messages[0])
+		assert.Contains(t, alternatives["text/plain"], "Hello Ann")
+		assert.Contains(t, alternatives["text/html"], "<strong>Go &lt;Concurrency&gt;</strong>")
+
+		assert.Equal(t, "Напоминание: скоро лекция «Go <Concurrency>»", decodeSubject(t, messages[1]))
+		assert.Contains(t, readAlternatives(t, messages[1])["text/plain"], "Здравствуйте, Иван!")
+	}
+}
+
+func TestSenderUnrecordedOutcome(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	templates, err := LoadTemplates("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An outcome that can't be recorded fails the batch once the rest of it
+	// has been attempted.
+	cfg := *config.Default().Notifications
+	student, _ := testStudents()
+	cancelledLecture := testLecture()
+	cancelledLecture.Status = models.LectureStatusCancelled
+	unrecorded := claimedNotification(models.NotificationCancellation, student, nil, 0)
+	skipped := claimedNotification(models.NotificationReminder, student, cancelledLecture, 0)
+	notificationRepoMock := mock.NewMockNotificationRepo(ctrl)
+	notificationRepoMock.EXPECT().ClaimNotifications(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return([]*models.Notification{unrecorded, skipped}, nil).Times(1)
+	notificationRepoMock.EXPECT().UpdateNotification(gomock.Any(), unrecorded).Return(apperrors.NotificationRepoErr.AppendMessage("connection reset")).Times(1)
+	notificationRepoMock.EXPECT().UpdateNotification(gomock.Any(), skipped).Return(nil).Times(1)
+
+	notifier, err := NewNotifier(&cfg, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := NewSender(notificationRepoMock, notifier, templates, &cfg, zap.NewNop().Sugar()).SendOnce(context.Background())
+	assert.True(t, apperrors.IsAppError(err, &apperrors.NotificationRepoErr))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, models.NotificationSkipped, skipped.Status)
+}
@@ -0,0 +1,168 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+)
+
+// SMTPNotifier sends emails through an SMTP relay, as multipart/alternative
+// messages with a plain text and an HTML part. It upgrades to TLS and
+// authenticates whenever the server offers to.
+type SMTPNotifier struct {
+	addr    string
+	host    string
+	from    *mail.Address
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+func NewSMTPNotifier(cfg *config.NotificationsConfig) (*SMTPNotifier, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, apperrors.NotifierErr.AppendMessage(err)
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	return &SMTPNotifier{
+		addr:    net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		host:    cfg.SMTPHost,
+		from:    from,
+		auth:    auth,
+		timeout: cfg.SMTPTimeout,
+	}, nil
+}
+
+func (notifier *SMTPNotifier) Notify(ctx context.Context, email *Email) error {
+	message, err := notifier.compose(email, time.Now())
+	if err != nil {
+		return apperrors.NotifierErr.AppendMessage(err)
+	}
+
+	if err := notifier.send(ctx, email.To, message); err != nil {
+		return apperrors.NotifierErr.AppendMessage(err)
+	}
+
+	return nil
+}
+
+func (notifier *SMTPNotifier) send(ctx context.Context, to string, message []byte) error {
+	dialer := net.Dialer{Timeout: notifier.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", notifier.addr)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(notifier.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, notifier.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: notifier.host}); err != nil {
+			return err
+		}
+	}
+
+	if ok, _ := client.Extension("AUTH"); ok && notifier.auth != nil {
+		if err := client.Auth(notifier.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(notifier.from.Address); err != nil {
+		return err
+	}
+
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// compose builds the message. Header values are encoded so that nothing a
+// template renders can start a new header.
+func (notifier *SMTPNotifier) compose(email *Email, now time.Time) ([]byte, error) {
+	if strings.ContainsAny(email.To, "\r\n") {
+		return nil, fmt.Errorf("invalid recipient %q", email.To)
+	}
+
+	body := &bytes.Buffer{}
+	parts := multipart.NewWriter(body)
+	for _, alternative := range []struct{ contentType, content string }{
+		{"text/plain", email.Text},
+		{"text/html", email.HTML},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(part)
+		if _, err := encoder.Write([]byte(alternative.content)); err != nil {
+			return nil, err
+		}
+
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	domain := notifier.from.Address[strings.LastIndex(notifier.from.Address, "@")+1:]
+	message := &bytes.Buffer{}
+	fmt.Fprintf(message, "From: %s\r\n", notifier.from.String())
+	fmt.Fprintf(message, "To: %s\r\n", (&mail.Address{Address: email.To}).String())
+	fmt.Fprintf(message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(message, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(message, "Message-ID: <%s@%s>\r\n", email.ID, domain)
+	fmt.Fprintf(message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
@@ -0,0 +1,247 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTPServer accepts mail on a local port, rejecting recipients that
+// contain reject, and keeps every message it is given.
+type fakeSMTPServer struct {
+	listener net.Listener
+	reject   string
+	mu       sync.Mutex
+	messages []*mail.Message
+}
+
+func newFakeSMTPServer(t *testing.T, reject string) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeSMTPServer{listener: listener, reject: reject}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.serve(t, conn)
+		}
+	}()
+
+	return server
+}
+
+func (server *fakeSMTPServer) serve(t *testing.T, conn net.Conn) {
+	text := textproto.NewConn(conn)
+	defer text.Close()
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			text.PrintfLine("250 8BITMIME")
+		case "HELO":
+			text.PrintfLine("250 localhost")
+		case "RCPT":
+			if strings.Contains(line, server.reject) {
+				text.PrintfLine("550 no such user")
+				continue
+			}
+
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			message, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Error(err)
+			}
+
+			server.mu.Lock()
+			server.messages = append(server.messages, message)
+			server.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+// decodeSubject decodes the encoded words of a message's subject.
+func decodeSubject(t *testing.T, message *mail.Message) string {
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return subject
+}
+
+// readAlternatives decodes the plain text and HTML parts of a message.
+func readAlternatives(t *testing.T, message *mail.Message) map[string]string {
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alternatives := map[string]string{}
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			return alternatives
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "quoted-printable", part.Header.Get("Content-Transfer-Encoding"))
+		content, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		alternatives[mediaType] = string(content)
+	}
+}
+
+// smtpConfig points the smtp notifier at server.
+func smtpConfig(t *testing.T, server *fakeSMTPServer) *config.NotificationsConfig {
+	cfg := *config.Default().Notifications
+	cfg.Notifier = "smtp"
+	cfg.From = "School <noreply@school.example>"
+	var err error
+	cfg.SMTPHost, cfg.SMTPPort, err = net.SplitHostPort(server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &cfg
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	smtpServer := newFakeSMTPServer(t, "bounce@")
+	defer smtpServer.listener.Close()
+	notifier, err := NewSMTPNotifier(smtpConfig(t, smtpServer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email := &Email{
+		ID:      "5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11",
+		To:      "ann@example.com",
+		Subject: "Напоминание: «Go»\r\nBcc: everyone@example.com",
+		Text:    "Hello Ann,\nsee you at the lecture.\n",
+		HTML:    "<p>Hello Ann,</p>\n",
+	}
+	assert.NoError(t, notifier.Notify(context.Background(), email))
+
+	bounced := *email
+	bounced.To = "bounce@example.com"
+	err = notifier.Notify(context.Background(), &bounced)
+	if assert.True(t, apperrors.IsAppError(err, &apperrors.NotifierErr)) {
+		assert.Contains(t, err.Error(), "550")
+	}
+
+	injected := *email
+	injected.To = "ann@example.com\r\nBcc: everyone@example.com"
+	assert.Error(t, notifier.Notify(context.Background(), &injected))
+
+	smtpServer.mu.Lock()
+	messages := smtpServer.messages
+	smtpServer.mu.Unlock()
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+
+	message := messages[0]
+	assert.Equal(t, `"School" <noreply@school.example>`, message.Header.Get("From"))
+	assert.Equal(t, "<ann@example.com>", message.Header.Get("To"))
+	assert.Equal(t, "<"+email.ID+"@school.example>", message.Header.Get("Message-ID"))
+	assert.Empty(t, message.Header.Get("Bcc"), "nothing in the subject starts a header")
+	assert.Equal(t, email.Subject, decodeSubject(t, message))
+	date, err := message.Header.Date()
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, time.Now(), date, time.Minute)
+	}
+
+	alternatives := readAlternatives(t, message)
+	assert.Equal(t, email.Text, alternatives["text/plain"])
+	assert.Equal(t, email.HTML, alternatives["text/html"])
+}
+
+func TestSMTPNotifierUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := smtpConfig(t, &fakeSMTPServer{listener: listener})
+	listener.Close()
+	notifier, err := NewSMTPNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = notifier.Notify(context.Background(), &Email{ID: "1", To: "ann@example.com"})
+	assert.True(t, apperrors.IsAppError(err, &apperrors.NotifierErr))
+}
+
+func TestNewNotifier(t *testing.T) {
+	testTable := []struct {
+		notifier string
+		valid    bool
+	}{
+		{"", true},
+		{"log", true},
+		{"smtp", true},
+		{"carrier-pigeon", false},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.notifier, func(t *testing.T) {
+			cfg := *config.Default().Notifications
+			cfg.Notifier = tc.notifier
+			_, err := NewNotifier(&cfg, nil)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.True(t, apperrors.IsAppError(err, &apperrors.NotifierErr))
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// TemplateData is what the templates see about the student and the lecture.
+type TemplateData struct {
+	FirstName    string
+	LastName     string
+	LectureTitle string
+	Speaker      string
+	Location     string
+	Date         time.Time
+	Duration     int
+	CancelReason string
+}
+
+// Templates holds, for every locale under templates/, a <kind>.txt defining
+// "subject" and "text" and a <kind>.html defining "html" for each of
+// models.NotificationKinds.
+type Templates struct {
+	text          map[string]*texttemplate.Template
+	html          map[string]*htmltemplate.Template
+	defaultLocale string
+}
+
+// LoadTemplates parses the embedded templates. Every locale must have all
+// kinds, and defaultLocale must be one of them.
+func LoadTemplates(defaultLocale string) (*Templates, error) {
+	locales, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, apperrors.NotificationTemplateErr.AppendMessage(err)
+	}
+
+	templates := &Templates{
+		text:          map[string]*texttemplate.Template{},
+		html:          map[string]*htmltemplate.Template{},
+		defaultLocale: defaultLocale,
+	}
+	for _, locale := range locales {
+		if !locale.IsDir() {
+			continue
+		}
+
+		for _, kind := range models.NotificationKinds {
+			name := path.Join("templates", locale.Name(), kind)
+			text, err := texttemplate.ParseFS(templateFS, name+".txt")
+			if err != nil {
+				return nil, apperrors.NotificationTemplateErr.AppendMessage(err)
+			}
+
+			html, err := htmltemplate.ParseFS(templateFS, name+".html")
+			if err != nil {
+				return nil, apperrors.NotificationTemplateErr.AppendMessage(err)
+			}
+
+			templates.text[templateKey(locale.Name(), kind)] = text
+			templates.html[templateKey(locale.Name(), kind)] = html
+		}
+	}
+
+	if _, ok := templates.text[templateKey(defaultLocale, models.NotificationEnrollment)]; !ok {
+		return nil, apperrors.NotificationTemplateErr.AppendMessage("no templates for default locale", defaultLocale)
+	}
+
+	return templates, nil
+}
+
+// Render renders a kind email in locale, falling back to its base language
+// (en for en-GB) and then to the default locale.
+func (templates *Templates) Render(kind string, locale string, data *TemplateData) (*Email, error) {
+	key := templateKey(templates.locale(locale), kind)
+	text, ok := templates.text[key]
+	if !ok {
+		return nil, apperrors.NotificationTemplateErr.AppendMessage("unknown notification kind", kind)
+	}
+
+	var subject, plain, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, apperrors.NotificationTemplateErr.AppendMessage(err)
+	}
+
+	if err := text.ExecuteTemplate(&plain, "text", data); err != nil {
+		return nil, apperrors.NotificationTemplateErr.AppendMessage(err)
+	}
+
+	if err := templates.html[key].ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, apperrors.NotificationTemplateErr.AppendMessage(err)
+	}
+
+	return &Email{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(plain.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()) + "\n",
+	}, nil
+}
+
+// HasLocale tells whether emails can be rendered in locale itself or in its
+// base language, without falling back to the default locale.
+func (templates *Templates) HasLocale(locale string) bool {
+	return templates.match(locale) != ""
+}
+
+func (templates *Templates) locale(locale string) string {
+	if matched := templates.match(locale); matched != "" {
+		return matched
+	}
+
+	return templates.defaultLocale
+}
+
+// match returns the loaded locale that serves locale, or "" when none does.
+func (templates *Templates) match(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if _, ok := templates.text[templateKey(locale, models.NotificationEnrollment)]; ok {
+		return locale
+	}
+
+	base, _, _ := strings.Cut(locale, "-")
+	if _, ok := templates.text[templateKey(base, models.NotificationEnrollment)]; ok {
+		return base
+	}
+
+	return ""
+}
+
+func templateKey(locale string, kind string) string {
+	return locale + "/" + kind
+}
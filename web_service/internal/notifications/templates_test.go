@@ -0,0 +1,94 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testLectureID      = uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	testStudentID      = uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	testOtherStudentID = uuid.MustParse("9e8d7c6b-5a49-4382-b1c0-d9e8f7a6b5c4")
+)
+
+// testLecture is a published lecture whose title needs escaping in HTML.
+func testLecture() *models.Lecture {
+	return &models.Lecture{ID: &testLectureID, Title: "Go <Concurrency>", Speaker: "Rob", Location: "Room 1", Duration: 90,
+		Date: time.Date(2030, 3, 14, 18, 30, 0, 0, time.UTC), Status: models.LectureStatusPublished}
+}
+
+func testStudents() (*models.User, *models.User) {
+	return &models.User{ID: &testStudentID, Email: "ann@example.com", FirstName: "Ann", Locale: "en-GB"},
+		&models.User{ID: &testOtherStudentID, Email: "ivan@example.com", FirstName: "Иван", Locale: "ru"}
+}
+
+func TestRenderEveryKindInEveryLocale(t *testing.T) {
+	templates, err := LoadTemplates("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A regional locale falls back to its language, an unknown one to the
+	// default.
+	lecture := testLecture()
+	data := &TemplateData{FirstName: "Ann", LectureTitle: lecture.Title, Speaker: lecture.Speaker, Location: lecture.Location, Date: lecture.Date, Duration: lecture.Duration, CancelReason: "Speaker is ill"}
+	for _, kind := range models.NotificationKinds {
+		for locale, greeting := range map[string]string{"en": "Hello Ann", "en-GB": "Hello Ann", "ru": "Здравствуйте, Ann", "ru_RU": "Здравствуйте, Ann", "RU-ru": "Здравствуйте, Ann", "de": "Hello Ann", "": "Hello Ann"} {
+			email, err := templates.Render(kind, locale, data)
+			if assert.NoError(t, err, kind, locale) {
+				assert.Contains(t, email.Subject, lecture.Title, kind, locale)
+				assert.Contains(t, email.Text, greeting, kind, locale)
+				assert.Contains(t, email.Text, lecture.Title, kind, locale)
+				assert.Contains(t, email.HTML, "Go &lt;Concurrency&gt;", kind, locale)
+				assert.NotContains(t, email.HTML, "<Concurrency>", kind, locale)
+				assert.NotContains(t, email.Subject, "\n", kind, locale)
+			}
+		}
+	}
+}
+
+func TestRenderLectureDetails(t *testing.T) {
+	templates, err := LoadTemplates("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lecture := testLecture()
+	data := &TemplateData{FirstName: "Ann", LectureTitle: lecture.Title, Speaker: lecture.Speaker, Location: lecture.Location, Date: lecture.Date, Duration: lecture.Duration, CancelReason: "Speaker is ill"}
+	email, err := templates.Render(models.NotificationCancellation, "en", data)
+	if assert.NoError(t, err) {
+		assert.Contains(t, email.Text, "Reason: Speaker is ill")
+		assert.Contains(t, email.Text, "Thursday, 14 March 2030, 18:30 UTC")
+	}
+
+	email, err = templates.Render(models.NotificationEnrollment, "ru", data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Вы записаны на лекцию «Go <Concurrency>»", email.Subject)
+	}
+
+	_, err = templates.Render("unknown", "en", data)
+	assert.True(t, apperrors.IsAppError(err, &apperrors.NotificationTemplateErr))
+}
+
+func TestLoadTemplates(t *testing.T) {
+	templates, err := LoadTemplates("ru")
+	if assert.NoError(t, err) {
+		assert.True(t, templates.HasLocale("en"))
+		assert.True(t, templates.HasLocale("en-GB"))
+		assert.True(t, templates.HasLocale("ru_RU"))
+		assert.False(t, templates.HasLocale("de"))
+		assert.False(t, templates.HasLocale(""))
+		email, err := templates.Render(models.NotificationReminder, "de", &TemplateData{FirstName: "Ann"})
+		if assert.NoError(t, err) {
+			assert.Contains(t, email.Text, "Здравствуйте, Ann", "falls back to the default locale")
+		}
+	}
+
+	_, err = LoadTemplates("xx")
+	assert.True(t, apperrors.IsAppError(err, &apperrors.NotificationTemplateErr))
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+)
+
+// Broker is the part of a message broker client the outbox needs. A NATS
+// client publishes to topic as its subject and may ignore key; a Kafka
+// producer uses key to keep one aggregate's events in one partition, and so
+// in order.
+type Broker interface {
+	Send(ctx context.Context, topic string, key string, value []byte, headers map[string]string) error
+}
+
+// BrokerPublisher sends each event to topicPrefix followed by its type, e.g.
+// "school.StudentEnrolled", keyed by the aggregate it belongs to.
+type BrokerPublisher struct {
+	broker      Broker
+	topicPrefix string
+}
+
+func NewBrokerPublisher(broker Broker, topicPrefix string) *BrokerPublisher {
+	return &BrokerPublisher{broker: broker, topicPrefix: topicPrefix}
+}
+
+func (publisher *BrokerPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	value, err := json.Marshal(NewMessage(event))
+	if err != nil {
+		return apperrors.OutboxPublisherErr.AppendMessage(err)
+	}
+
+	headers := map[string]string{"event-id": event.ID.String(), "event-type": event.Type}
+	if err := publisher.broker.Send(ctx, publisher.topicPrefix+event.Type, event.AggregateID, value, headers); err != nil {
+		return apperrors.OutboxPublisherErr.AppendMessage(err)
+	}
+
+	return nil
+}
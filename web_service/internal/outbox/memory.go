@@ -0,0 +1,32 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"web_service/internal/domain/models"
+)
+
+// MemoryPublisher keeps every message it is given, for tests and local
+// development. Nothing is ever dropped, so it isn't meant for production.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (publisher *MemoryPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	publisher.messages = append(publisher.messages, NewMessage(event))
+	return nil
+}
+
+// Messages returns what has been published so far, oldest first.
+func (publisher *MemoryPublisher) Messages() []Message {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	return append([]Message{}, publisher.messages...)
+}
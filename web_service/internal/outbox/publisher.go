@@ -0,0 +1,89 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	PublisherLog     = "log"
+	PublisherMemory  = "memory"
+	PublisherWebhook = "webhook"
+)
+
+// Publisher hands an event to its consumers. An error means it wasn't
+// accepted and will be retried, so consumers can see an event more than once
+// and should deduplicate on its ID.
+type Publisher interface {
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+// Message is the wire form of an event, shared by every publisher.
+type Message struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func NewMessage(event *models.OutboxEvent) Message {
+	return Message{
+		ID:            event.ID.String(),
+		Type:          event.Type,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		OccurredAt:    event.OccurredAt,
+		Payload:       event.Payload,
+	}
+}
+
+// NewPublisher builds the publisher cfg names. Broker publishers aren't
+// configurable here; they are built with NewBrokerPublisher around a client.
+func NewPublisher(cfg *config.OutboxConfig, logger *zap.SugaredLogger) (Publisher, error) {
+	switch cfg.Publisher {
+	case PublisherLog, "":
+		return &LogPublisher{logger: logger}, nil
+	case PublisherMemory:
+		return NewMemoryPublisher(), nil
+	case PublisherWebhook:
+		return NewWebhookPublisher(cfg.WebhookURL, cfg.WebhookTimeout), nil
+	default:
+		return nil, apperrors.OutboxPublisherErr.AppendMessage("unknown publisher", cfg.Publisher)
+	}
+}
+
+// Fanout publishes every event to each of publishers in turn. It fails as soon
+// as one of them does, so on a retry the ones before it see the event again.
+func Fanout(publishers ...Publisher) Publisher {
+	return fanoutPublisher(publishers)
+}
+
+type fanoutPublisher []Publisher
+
+func (publishers fanoutPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	for _, publisher := range publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LogPublisher only logs events; it is the default until consumers exist.
+type LogPublisher struct {
+	logger *zap.SugaredLogger
+}
+
+func (publisher *LogPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	publisher.logger.Infof("Domain event %s %s for %s %s", event.Type, event.ID, event.AggregateType, event.AggregateID)
+	return nil
+}
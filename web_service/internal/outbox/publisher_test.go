@@ -0,0 +1,137 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewPublisher(t *testing.T) {
+	testTable := []struct {
+		publisher string
+		expected  Publisher
+	}{
+		{publisher: "", expected: &LogPublisher{}},
+		{publisher: PublisherLog, expected: &LogPublisher{}},
+		{publisher: PublisherMemory, expected: &MemoryPublisher{}},
+		{publisher: PublisherWebhook, expected: &WebhookPublisher{}},
+	}
+
+	for _, tc := range testTable {
+		cfg := *config.Default().Outbox
+		cfg.Publisher = tc.publisher
+		publisher, err := NewPublisher(&cfg, zap.NewNop().Sugar())
+		if assert.NoError(t, err, tc.publisher) {
+			assert.IsType(t, tc.expected, publisher, tc.publisher)
+		}
+	}
+
+	cfg := *config.Default().Outbox
+	cfg.Publisher = "kafka"
+	_, err := NewPublisher(&cfg, zap.NewNop().Sugar())
+	assert.True(t, apperrors.IsAppError(err, &apperrors.OutboxPublisherErr))
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	enrolled, dropped := testEvents(t)
+	var received []Message
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := Message{}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, message.ID, r.Header.Get(EventIDHeader))
+		assert.Equal(t, message.Type, r.Header.Get(EventTypeHeader))
+		received = append(received, message)
+		if message.Type == models.EventStudentDropped {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	publisher := NewWebhookPublisher(receiver.URL, time.Second)
+	assert.NoError(t, publisher.Publish(context.Background(), enrolled))
+	err := publisher.Publish(context.Background(), dropped)
+	if assert.True(t, apperrors.IsAppError(err, &apperrors.OutboxPublisherErr)) {
+		assert.Contains(t, err.Error(), "503")
+	}
+
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, enrolled.ID.String(), received[0].ID)
+		assert.Equal(t, testLectureID.String(), received[0].AggregateID)
+		assert.JSONEq(t, `{"lecture_id": "`+testLectureID.String()+`", "student_id": "`+testStudentID.String()+`"}`, string(received[0].Payload))
+	}
+
+	receiver.Close()
+	assert.True(t, apperrors.IsAppError(publisher.Publish(context.Background(), enrolled), &apperrors.OutboxPublisherErr), "an unreachable receiver fails")
+}
+
+type recordingBroker struct {
+	topic   string
+	key     string
+	value   []byte
+	headers map[string]string
+	err     error
+}
+
+func (broker *recordingBroker) Send(_ context.Context, topic string, key string, value []byte, headers map[string]string) error {
+	broker.topic, broker.key, broker.value, broker.headers = topic, key, value, headers
+	return broker.err
+}
+
+func TestBrokerPublisher(t *testing.T) {
+	enrolled, _ := testEvents(t)
+	broker := &recordingBroker{}
+	assert.NoError(t, NewBrokerPublisher(broker, "school.").Publish(context.Background(), enrolled))
+	assert.Equal(t, "school."+models.EventStudentEnrolled, broker.topic)
+	assert.Equal(t, testLectureID.String(), broker.key)
+	assert.Equal(t, enrolled.ID.String(), broker.headers["event-id"])
+	assert.Equal(t, models.EventStudentEnrolled, broker.headers["event-type"])
+	message := Message{}
+	if assert.NoError(t, json.Unmarshal(broker.value, &message)) {
+		assert.Equal(t, enrolled.ID.String(), message.ID)
+	}
+
+	broker.err = errors.New("broker unavailable")
+	err := NewBrokerPublisher(broker, "school.").Publish(context.Background(), enrolled)
+	assert.True(t, apperrors.IsAppError(err, &apperrors.OutboxPublisherErr))
+}
+
+func TestFanout(t *testing.T) {
+	enrolled, dropped := testEvents(t)
+
+	// A publisher that fails stops the fanout, so the ones after it don't see
+	// the event until the retry.
+	first := NewMemoryPublisher()
+	failing := &failingPublisher{eventType: models.EventStudentDropped}
+	last := NewMemoryPublisher()
+	fanout := Fanout(first, failing, last)
+	assert.NoError(t, fanout.Publish(context.Background(), enrolled))
+	assert.Error(t, fanout.Publish(context.Background(), dropped))
+
+	assert.Len(t, first.Messages(), 2)
+	assert.Equal(t, []*models.OutboxEvent{enrolled}, failing.published)
+	if assert.Len(t, last.Messages(), 1) {
+		assert.Equal(t, models.EventStudentEnrolled, last.Messages()[0].Type)
+	}
+
+	// Messages is a copy the caller may change.
+	messages := first.Messages()
+	messages[0].Type = "changed"
+	assert.Equal(t, models.EventStudentEnrolled, first.Messages()[0].Type)
+}
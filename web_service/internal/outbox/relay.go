@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"time"
+	"web_service/internal/config"
+	"web_service/internal/repositories"
+
+	"go.uber.org/zap"
+)
+
+// Relay moves events from the outbox to a Publisher. Several instances may
+// run side by side: each claims its own batches. Delivery is at least once;
+// an event is only marked published after the publisher accepted it.
+type Relay struct {
+	repo      repositories.OutboxRepo
+	publisher Publisher
+	logger    *zap.SugaredLogger
+	cfg       *config.OutboxConfig
+}
+
+func NewRelay(repo repositories.OutboxRepo, publisher Publisher, cfg *config.OutboxConfig, logger *zap.SugaredLogger) *Relay {
+	return &Relay{repo: repo, publisher: publisher, logger: logger, cfg: cfg}
+}
+
+// Run relays events every poll interval, and purges old published ones, until
+// ctx is done.
+func (relay *Relay) Run(ctx context.Context) {
+	poll := time.NewTicker(relay.cfg.PollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			// Keep going while batches come back full, so a backlog drains
+			// without waiting a poll interval per batch.
+			for ctx.Err() == nil {
+				claimed, err := relay.RelayOnce(ctx)
+				if err != nil || claimed < relay.cfg.BatchSize {
+					break
+				}
+			}
+		case <-purge.C:
+			deleted, err := relay.repo.DeletePublishedOutboxEvents(ctx, time.Now().Add(-relay.cfg.Retention))
+			if err != nil {
+				relay.logger.Errorf("Purging published outbox events: %v", err)
+				continue
+			}
+
+			if deleted > 0 {
+				relay.logger.Infof("Purged %d published outbox events", deleted)
+			}
+		}
+	}
+}
+
+// RelayOnce claims one batch of due events and publishes it. It returns how
+// many events were claimed, whether or not publishing them succeeded. An
+// event whose outcome can't be recorded stays claimed until its lease runs
+// out and is then relayed again; the first such error is returned after the
+// rest of the batch was tried.
+func (relay *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := relay.repo.ClaimOutboxEvents(ctx, time.Now(), relay.cfg.BatchSize, relay.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var markErr error
+	for _, event := range events {
+		if err := relay.publisher.Publish(ctx, event); err != nil {
+			nextAttemptAt := time.Now().Add(Backoff(event.Attempts+1, relay.cfg.RetryBackoff, relay.cfg.RetryBackoffMax))
+			relay.logger.Warnf("Publishing %s event %s failed, attempt %d, retrying at %v: %v", event.Type, event.ID, event.Attempts+1, nextAttemptAt, err)
+			err = relay.repo.MarkOutboxEventFailed(ctx, event, nextAttemptAt, err.Error())
+			if err != nil {
+				relay.logger.Errorf("Recording the failure of %s event %s: %v", event.Type, event.ID, err)
+				if markErr == nil {
+					markErr = err
+				}
+			}
+
+			continue
+		}
+
+		if err := relay.repo.MarkOutboxEventPublished(ctx, event, time.Now()); err != nil {
+			relay.logger.Errorf("Marking %s event %s published, it will be relayed again: %v", event.Type, event.ID, err)
+			if markErr == nil {
+				markErr = err
+			}
+		}
+	}
+
+	return len(events), markErr
+}
+
+// Backoff is the delay before retrying after the given number of failed
+// attempts: base, doubling with every further failure, capped at max.
+func Backoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+	"web_service/internal/domain/mappers"
+	"web_service/internal/domain/models"
+	"web_service/internal/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	testLectureID = uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	testStudentID = uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+)
+
+// testEvents are an enrolment and a drop of the same student, the drop
+// having already failed three times.
+func testEvents(t *testing.T) (*models.OutboxEvent, *models.OutboxEvent) {
+	enrolled, err := mappers.MapEnrollmentToStudentEnrolledEvent(&models.Lecture{ID: &testLectureID}, &models.User{ID: &testStudentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := mappers.MapEnrollmentToStudentDroppedEvent(&models.Lecture{ID: &testLectureID}, &models.User{ID: &testStudentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped.Attempts = 3
+	return enrolled, dropped
+}
+
+// failingPublisher rejects events of one type and accepts the rest.
+type failingPublisher struct {
+	eventType string
+	published []*models.OutboxEvent
+}
+
+func (publisher *failingPublisher) Publish(_ context.Context, event *models.OutboxEvent) error {
+	if event.Type == publisher.eventType {
+		return apperrors.OutboxPublisherErr.AppendMessage("503 Service Unavailable")
+	}
+
+	publisher.published = append(publisher.published, event)
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enrolled, dropped := testEvents(t)
+	cfg := config.Default().Outbox
+	outboxRepoMock := mock.NewMockOutboxRepo(ctrl)
+	publisher := &failingPublisher{eventType: models.EventStudentDropped}
+	relay := NewRelay(outboxRepoMock, publisher, cfg, zap.NewNop().Sugar())
+
+	// An accepted event is marked published; a rejected one is retried after
+	// a backoff that grows with its attempts.
+	start := time.Now()
+	outboxRepoMock.EXPECT().ClaimOutboxEvents(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return([]*models.OutboxEvent{enrolled, dropped}, nil).Times(1)
+	outboxRepoMock.EXPECT().MarkOutboxEventPublished(gomock.Any(), enrolled, gomock.Any()).Return(nil).Times(1)
+	outboxRepoMock.EXPECT().MarkOutboxEventFailed(gomock.Any(), dropped, gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ *models.OutboxEvent, nextAttemptAt time.Time, reason string) error {
+		// The fourth failure waits three doublings of the base backoff.
+		assert.WithinDuration(t, start.Add(8*cfg.RetryBackoff), nextAttemptAt, time.Second)
+		assert.Contains(t, reason, "503")
+		return nil
+	}).Times(1)
+
+	claimed, err := relay.RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	assert.Equal(t, []*models.OutboxEvent{enrolled}, publisher.published)
+
+	// Failing to mark an event still tries the rest of the batch, and the
+	// first such error is returned.
+	publisher.published = nil
+	outboxRepoMock.EXPECT().ClaimOutboxEvents(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return([]*models.OutboxEvent{dropped, enrolled}, nil).Times(1)
+	outboxRepoMock.EXPECT().MarkOutboxEventFailed(gomock.Any(), dropped, gomock.Any(), gomock.Any()).Return(apperrors.OutboxRepoErr.AppendMessage("connection refused")).Times(1)
+	outboxRepoMock.EXPECT().MarkOutboxEventPublished(gomock.Any(), enrolled, gomock.Any()).Return(apperrors.OutboxRepoErr.AppendMessage("connection reset")).Times(1)
+
+	claimed, err = relay.RelayOnce(context.Background())
+	assert.True(t, apperrors.IsAppError(err, &apperrors.OutboxRepoErr))
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Equal(t, 2, claimed)
+	assert.Equal(t, []*models.OutboxEvent{enrolled}, publisher.published)
+
+	// A claim that fails publishes nothing.
+	publisher.published = nil
+	outboxRepoMock.EXPECT().ClaimOutboxEvents(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return(nil, errors.New("connection refused")).Times(1)
+
+	claimed, err = relay.RelayOnce(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 0, claimed)
+	assert.Empty(t, publisher.published)
+}
+
+func TestBackoff(t *testing.T) {
+	testTable := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 0, expected: time.Second},
+		{attempts: 1, expected: time.Second},
+		{attempts: 2, expected: 2 * time.Second},
+		{attempts: 4, expected: 8 * time.Second},
+		{attempts: 7, expected: time.Minute},
+		{attempts: 1000, expected: time.Minute},
+	}
+
+	for _, tc := range testTable {
+		assert.Equal(t, tc.expected, Backoff(tc.attempts, time.Second, time.Minute), tc.attempts)
+	}
+
+	assert.Equal(t, time.Minute, Backoff(1, 2*time.Minute, time.Minute), "the base is capped too")
+}
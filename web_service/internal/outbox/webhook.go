@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+)
+
+const (
+	EventIDHeader   = "X-Event-ID"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// WebhookPublisher POSTs every event as JSON to one URL. Any status outside
+// 2xx counts as a failure, and the event is sent again later.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (publisher *WebhookPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	body, err := json.Marshal(NewMessage(event))
+	if err != nil {
+		return apperrors.OutboxPublisherErr.AppendMessage(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, publisher.url, bytes.NewReader(body))
+	if err != nil {
+		return apperrors.OutboxPublisherErr.AppendMessage(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, event.ID.String())
+	req.Header.Set(EventTypeHeader, event.Type)
+	resp, err := publisher.client.Do(req)
+	if err != nil {
+		return apperrors.OutboxPublisherErr.AppendMessage(err)
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apperrors.OutboxPublisherErr.AppendMessage(publisher.url, "answered", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"web_service/internal/auth"
+)
+
+// ClientIPResolver finds the address of the client behind our own proxies.
+// X-Forwarded-For is only believed when the request came from a trusted
+// proxy, and is read from the right, skipping trusted hops, so a client can't
+// pick its own bucket by prepending fake entries.
+type ClientIPResolver struct {
+	trusted auth.Networks
+}
+
+func NewClientIPResolver(trusted auth.Networks) *ClientIPResolver {
+	return &ClientIPResolver{trusted: trusted}
+}
+
+func (resolver *ClientIPResolver) ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	if !resolver.trusted.Contains(remote) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop != "" && !resolver.trusted.Contains(hop) {
+			return hop
+		}
+	}
+
+	return remote
+}
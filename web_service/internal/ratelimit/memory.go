@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rule   Rule
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(b.rule.Burst), b.tokens+elapsed*b.rule.perSecond())
+	b.last = now
+}
+
+// MemoryStore keeps buckets in process memory. Full buckets are dropped
+// periodically, since a missing bucket behaves exactly like a full one.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func (store *MemoryStore) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	if now.Sub(store.lastSweep) > sweepInterval {
+		store.sweep(now)
+	}
+
+	b, ok := store.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), last: now, rule: rule}
+		store.buckets[key] = b
+	}
+
+	b.refill(now)
+	result := Result{Limit: rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rule.perSecond())
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsToDuration((float64(rule.Burst) - b.tokens) / rule.perSecond())
+	return result, nil
+}
+
+func (store *MemoryStore) sweep(now time.Time) {
+	for key, b := range store.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rule.Burst) {
+			delete(store.buckets, key)
+		}
+	}
+
+	store.lastSweep = now
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
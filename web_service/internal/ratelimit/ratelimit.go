@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"web_service/internal/auth"
+)
+
+// Rule is a token bucket: Burst requests at once, refilled at PerMinute.
+type Rule struct {
+	PerMinute int
+	Burst     int
+}
+
+func (rule Rule) perSecond() float64 {
+	return float64(rule.PerMinute) / 60
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when this one was.
+	RetryAfter time.Duration
+}
+
+// SetHeaders writes the RateLimit-* headers of the IETF draft and, when the
+// request was rejected, Retry-After.
+func (result Result) SetHeaders(header http.Header) {
+	if result.Limit == 0 {
+		return
+	}
+
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+}
+
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
+
+// Store keeps the buckets. MemoryStore is enough for a single instance; a
+// shared store, e.g. backed by Redis, lets all instances enforce one limit.
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// RateLimiter applies one rule per route group, with a bucket per caller.
+type RateLimiter struct {
+	store     Store
+	rules     map[string]Rule
+	clientIPs *ClientIPResolver
+}
+
+func New(store Store, rules map[string]Rule, clientIPs *ClientIPResolver) *RateLimiter {
+	return &RateLimiter{store: store, rules: rules, clientIPs: clientIPs}
+}
+
+// Allow takes a token for the caller of r from the group's bucket. Callers
+// are told apart by user ID when authenticated and by client IP otherwise.
+// Only a verified ID may be in the context: one a client could set itself
+// would let it rotate through fresh buckets, and fill the store with them.
+// Groups without a rule are unlimited.
+func (limiter *RateLimiter) Allow(r *http.Request, group string) (Result, error) {
+	rule, ok := limiter.rules[group]
+	if !ok {
+		return Result{Allowed: true}, nil
+	}
+
+	caller := "ip:" + limiter.clientIPs.ClientIP(r)
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		caller = "user:" + userID.String()
+	}
+
+	return limiter.store.Take(r.Context(), group+":"+caller, rule)
+}
@@ -0,0 +1,240 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const auditEventsSort = "-created_at"
+
+var auditEventsKeyset = keyset{column: "audit_events.created_at", id: "audit_events.id", desc: true}
+
+type AuditRepo interface {
+	GetAuditEvents(ctx context.Context, pageRequest *models.PageRequest, filter *models.AuditFilter) (*models.AuditEventsPage, error)
+}
+
+type auditRepo struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewAuditRepo(db *gorm.DB, logger *zap.SugaredLogger) AuditRepo {
+	return &auditRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (repo *auditRepo) GetAuditEvents(ctx context.Context, pageRequest *models.PageRequest, filter *models.AuditFilter) (*models.AuditEventsPage, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	after, err := decodeCursor(pageRequest.Cursor, auditEventsSort)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	query := applyAuditFilter(repo.db.WithContext(ctx).Model(&models.AuditEvent{}), filter)
+	var totalCount *int64
+	if pageRequest.WithTotal {
+		totalCount = new(int64)
+		if err := query.Session(&gorm.Session{}).Count(totalCount).Error; err != nil {
+			appErr := apperrors.AuditRepoErr.AppendMessage(err)
+			logger.Error(appErr)
+			return nil, appErr
+		}
+	}
+
+	query = auditEventsKeyset.apply(query, after)
+	if after == nil && pageRequest.Page > 1 {
+		query = query.Offset((pageRequest.Page - 1) * pageRequest.PerPage)
+	}
+
+	var events []*models.AuditEvent
+	if err := query.Limit(pageRequest.PerPage + 1).Find(&events).Error; err != nil {
+		appErr := apperrors.AuditRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	eventsPage := &models.AuditEventsPage{}
+	eventsPage.Events, eventsPage.PageInfo = paginate(events, pageRequest, after, auditEventsSort, func(event *models.AuditEvent) (string, string) {
+		return event.CreatedAt.Format(time.RFC3339Nano), event.ID.String()
+	})
+	eventsPage.TotalCount = totalCount
+	return eventsPage, nil
+}
+
+func applyAuditFilter(query *gorm.DB, filter *models.AuditFilter) *gorm.DB {
+	if filter.EntityType != "" {
+		query = query.Where("audit_events.entity_type = ?", filter.EntityType)
+	}
+
+	if filter.EntityID != "" {
+		query = query.Where("audit_events.entity_id = ?", filter.EntityID)
+	}
+
+	if filter.ActorID != nil {
+		query = query.Where("audit_events.actor_id = ?", filter.ActorID)
+	}
+
+	if filter.From != nil {
+		query = query.Where("audit_events.created_at >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		query = query.Where("audit_events.created_at <= ?", *filter.To)
+	}
+
+	return query
+}
+
+// recordAudit appends an event to the audit log through tx, so it commits or
+// rolls back together with the change it describes. The actor and request ID
+// come from ctx, where the actor is only ever a user ID the server accepted
+// from a trusted proxy; before and after are reduced to the fields that differ.
+func recordAudit(ctx context.Context, tx *gorm.DB, action string, entityType string, entityID string, before map[string]interface{}, after map[string]interface{}) error {
+	event := &models.AuditEvent{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CreatedAt:  time.Now(),
+	}
+	id := uuid.New()
+	event.ID = &id
+	if actorID, ok := auth.UserIDFromContext(ctx); ok {
+		event.ActorID = &actorID
+	}
+
+	if requestID, ok := logging.RequestIDFromContext(ctx); ok {
+		event.RequestID = requestID
+	}
+
+	var err error
+	before, after = auditDiff(before, after)
+	if event.Before, err = marshalAuditState(before); err != nil {
+		return apperrors.AuditRepoErr.AppendMessage(err)
+	}
+
+	if event.After, err = marshalAuditState(after); err != nil {
+		return apperrors.AuditRepoErr.AppendMessage(err)
+	}
+
+	if err := tx.Create(event).Error; err != nil {
+		return apperrors.AuditRepoErr.AppendMessage(err)
+	}
+
+	return nil
+}
+
+// auditDiff drops the fields before and after agree on. Either side may be
+// nil, in which case the other is kept whole.
+func auditDiff(before map[string]interface{}, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+
+	changedBefore, changedAfter := map[string]interface{}{}, map[string]interface{}{}
+	for field, value := range after {
+		old, _ := json.Marshal(before[field])
+		current, _ := json.Marshal(value)
+		if !bytes.Equal(old, current) {
+			changedBefore[field] = before[field]
+			changedAfter[field] = value
+		}
+	}
+
+	return changedBefore, changedAfter
+}
+
+func marshalAuditState(state map[string]interface{}) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(state)
+}
+
+// lectureAuditState is what the audit log tracks of a lecture.
+func lectureAuditState(lecture *models.Lecture) map[string]interface{} {
+	return map[string]interface{}{
+		"title":         lecture.Title,
+		"description":   lecture.Description,
+		"speaker":       lecture.Speaker,
+		"date":          lecture.Date.UTC().Format(time.RFC3339),
+		"location":      lecture.Location,
+		"duration":      lecture.Duration,
+		"capacity":      lecture.Capacity,
+		"status":        lecture.Status,
+		"cancel_reason": lecture.CancelReason,
+	}
+}
+
+// userAuditState is what the audit log tracks of a user. The password hash is
+// left out and the email kept only as a fingerprint: the log outlives the
+// user, so it mustn't hold their address, yet should still show it changed.
+func userAuditState(user *models.User, fingerprintKey []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"email":      emailFingerprint(user.Email, fingerprintKey),
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"role":       user.Role,
+		"locale":     user.Locale,
+	}
+}
+
+// emailFingerprint is keyed so that it can't be reversed by hashing a list of
+// likely addresses.
+// webhookAuditState is what the audit log tracks of a webhook subscription.
+// The secret is left out: whoever reads the log mustn't be able to sign
+// requests as the service.
+func webhookAuditState(subscription *models.WebhookSubscription) map[string]interface{} {
+	return map[string]interface{}{
+		"url":         subscription.URL,
+		"event_types": json.RawMessage(subscription.EventTypes),
+	}
+}
+
+// deliveryAuditState is what the audit log tracks of a webhook delivery
+// retried by hand.
+func deliveryAuditState(delivery *models.WebhookDelivery) map[string]interface{} {
+	return map[string]interface{}{
+		"subscription_id":       delivery.SubscriptionID,
+		"status":                delivery.Status,
+		"attempts_before_retry": delivery.AttemptsBeforeRetry,
+	}
+}
+
+func emailFingerprint(email string, key []byte) string {
+	if email == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// transactionErr keeps the AppError a transaction body failed with, and wraps
+// anything else, such as a failed commit, in base.
+func transactionErr(err error, base apperrors.AppError) *apperrors.AppError {
+	if appErr, ok := err.(*apperrors.AppError); ok {
+		return appErr
+	}
+
+	return base.AppendMessage(err)
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"web_service/internal/auth"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAuditDiff(t *testing.T) {
+	testTable := []struct {
+		scenario       string
+		before         map[string]interface{}
+		after          map[string]interface{}
+		expectedBefore map[string]interface{}
+		expectedAfter  map[string]interface{}
+	}{
+		{
+			scenario:      "created",
+			after:         map[string]interface{}{"title": "newYear"},
+			expectedAfter: map[string]interface{}{"title": "newYear"},
+		},
+		{
+			scenario:       "deleted",
+			before:         map[string]interface{}{"title": "newYear"},
+			expectedBefore: map[string]interface{}{"title": "newYear"},
+		},
+		{
+			scenario:       "changed fields only",
+			before:         map[string]interface{}{"title": "newYear", "capacity": 10, "location": "hall"},
+			after:          map[string]interface{}{"title": "easter", "capacity": 10, "location": "hall"},
+			expectedBefore: map[string]interface{}{"title": "newYear"},
+			expectedAfter:  map[string]interface{}{"title": "easter"},
+		},
+		{
+			scenario:       "same value of another type",
+			before:         map[string]interface{}{"capacity": 10, "status": models.LectureStatusDraft},
+			after:          map[string]interface{}{"capacity": int64(10), "status": "draft"},
+			expectedBefore: map[string]interface{}{},
+			expectedAfter:  map[string]interface{}{},
+		},
+		{
+			scenario:       "field set for the first time",
+			before:         map[string]interface{}{},
+			after:          map[string]interface{}{"cancel_reason": "ill"},
+			expectedBefore: map[string]interface{}{"cancel_reason": nil},
+			expectedAfter:  map[string]interface{}{"cancel_reason": "ill"},
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			before, after := auditDiff(tc.before, tc.after)
+			assert.Equal(t, tc.expectedBefore, before)
+			assert.Equal(t, tc.expectedAfter, after)
+		})
+	}
+}
+
+// dryRunPool stands in for the database. Dry-run statements never reach it,
+// but transactions still need something to begin, commit and roll back.
+type dryRunPool struct {
+	gorm.ConnPool
+}
+
+func (pool dryRunPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return pool, nil
+}
+
+func (dryRunPool) Commit() error {
+	return nil
+}
+
+func (dryRunPool) Rollback() error {
+	return nil
+}
+
+// auditDB builds statements without a database and hands every audit event
+// it is asked to create to recorded.
+func auditDB(t *testing.T, recorded *[]*models.AuditEvent) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: dryRunPool{}}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Callback().Create().Before("gorm:create").Register("test:record_audit", func(db *gorm.DB) {
+		if event, ok := db.Statement.Dest.(*models.AuditEvent); ok {
+			*recorded = append(*recorded, event)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestRecordAudit(t *testing.T) {
+	var recorded []*models.AuditEvent
+	db := auditDB(t, &recorded)
+	actorID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	ctx := logging.WithRequestID(auth.WithUserID(context.Background(), actorID), "req-1")
+
+	key := []byte("test-fingerprint-key")
+	before := userAuditState(&models.User{Email: "santa@example.com", FirstName: "Santa", LastName: "Claus", Role: models.RoleStudent}, key)
+	after := userAuditState(&models.User{Email: "Santa@Example.com", FirstName: "Father", LastName: "Claus", Role: models.RoleStudent}, key)
+	assert.NoError(t, recordAudit(ctx, db, models.AuditActionUserUpdated, models.EntityUser, actorID.String(), before, after))
+	assert.NoError(t, recordAudit(context.Background(), db, models.AuditActionUserCreated, models.EntityUser, actorID.String(), nil, after))
+
+	if !assert.Len(t, recorded, 2) {
+		return
+	}
+
+	updated := recorded[0]
+	assert.NotNil(t, updated.ID)
+	assert.False(t, updated.CreatedAt.IsZero())
+	assert.Equal(t, &actorID, updated.ActorID)
+	assert.Equal(t, "req-1", updated.RequestID)
+	assert.Equal(t, models.AuditActionUserUpdated, updated.Action)
+	assert.Equal(t, models.EntityUser, updated.EntityType)
+	assert.JSONEq(t, `{"first_name": "Santa"}`, string(updated.Before), "an email differing only in case isn't a change")
+	assert.JSONEq(t, `{"first_name": "Father"}`, string(updated.After))
+
+	created := recorded[1]
+	assert.Nil(t, created.ActorID, "anonymous")
+	assert.Empty(t, created.RequestID)
+	assert.Nil(t, created.Before)
+	state := map[string]interface{}{}
+	if assert.NoError(t, json.Unmarshal(created.After, &state)) {
+		assert.Equal(t, "hmac-sha256:9750ba1caab24cbd", state["email"])
+		assert.NotContains(t, string(created.After), "example.com")
+	}
+}
+
+func TestEmailFingerprintIsKeyed(t *testing.T) {
+	fingerprint := emailFingerprint("santa@example.com", []byte("test-fingerprint-key"))
+	assert.Equal(t, fingerprint, emailFingerprint(" Santa@Example.com ", []byte("test-fingerprint-key")))
+	assert.NotEqual(t, fingerprint, emailFingerprint("santa@example.com", []byte("another-fingerprint-key")))
+	assert.Empty(t, emailFingerprint("", []byte("test-fingerprint-key")))
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+type IdempotencyRepo interface {
+	Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	Complete(ctx context.Context, key *models.IdempotencyKey) error
+	Release(ctx context.Context, key *models.IdempotencyKey) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type idempotencyRepo struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewIdempotencyRepo(db *gorm.DB, logger *zap.SugaredLogger) IdempotencyRepo {
+	return &idempotencyRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Reserve claims key for a request about to be processed. It returns nil when
+// the claim succeeded, or the record of the earlier request that holds it.
+// Expired keys, and keys whose request stopped short of completing them, are
+// free to be claimed again.
+func (repo *idempotencyRepo) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	tx := repo.db.WithContext(ctx)
+	now := time.Now()
+	err := tx.Where("key = ? AND scope = ? AND (expires_at <= ? OR (status = 0 AND locked_until <= ?))", key.Key, key.Scope, now, now).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		appErr := apperrors.IdempotencyRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		appErr := apperrors.IdempotencyRepoErr.AppendMessage(result.Error)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	// Read from the primary: the competing request has only just written it.
+	existing := &models.IdempotencyKey{}
+	err = tx.Clauses(dbresolver.Write).First(existing, "key = ? AND scope = ?", key.Key, key.Scope).Error
+	if err != nil {
+		appErr := apperrors.IdempotencyRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return existing, nil
+}
+
+// Complete stores the response of a reserved key so retries can replay it.
+func (repo *idempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("key = ? AND scope = ?", key.Key, key.Scope).
+		Updates(map[string]interface{}{"status": key.Status, "content_type": key.ContentType, "body": key.Body}).Error
+	if err != nil {
+		appErr := apperrors.IdempotencyRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+// Release frees a reserved key whose request failed, so a retry runs again.
+func (repo *idempotencyRepo) Release(ctx context.Context, key *models.IdempotencyKey) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Where("key = ? AND scope = ? AND status = 0", key.Key, key.Scope).Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		appErr := apperrors.IdempotencyRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+func (repo *idempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	result := repo.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		appErr := apperrors.IdempotencyRepoErr.AppendMessage(result.Error)
+		logger.Error(appErr)
+		return 0, appErr
+	}
+
+	return result.RowsAffected, nil
+}
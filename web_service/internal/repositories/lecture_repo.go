@@ -2,18 +2,54 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"time"
 	"web_service/internal/apperrors"
 	"web_service/internal/domain/models"
+	"web_service/internal/logging"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 )
 
+const (
+	lectureSearchQuery   = "websearch_to_tsquery('simple', ?)"
+	lectureSearchRank    = "ts_rank(lectures.search_vector, " + lectureSearchQuery + ")"
+	lectureStudentsCount = "(SELECT COUNT(*) FROM lecture_students WHERE lecture_students.lecture_id = lectures.id)"
+)
+
+// studentColumns is all a lecture needs to know about its students; the rest of
+// the users row, the email and the password hash included, never leaves the
+// database.
+var studentColumns = []string{"users.id", "users.created_at", "users.first_name", "users.last_name"}
+
+var lectureSortColumns = map[string]keyset{
+	models.LectureSortDate:          {column: "lectures.date"},
+	models.LectureSortDateDesc:      {column: "lectures.date", desc: true},
+	models.LectureSortCreatedAt:     {column: "lectures.created_at"},
+	models.LectureSortCreatedAtDesc: {column: "lectures.created_at", desc: true},
+	models.LectureSortTitle:         {column: "lectures.title"},
+	models.LectureSortTitleDesc:     {column: "lectures.title", desc: true},
+}
+
+// RepoLecture writes take the domain events they emit, which are added to the
+// outbox in the same transaction.
 type RepoLecture interface {
-	CreateLecture(ctx context.Context, lecture *models.Lecture) (string, error)
-	AddUserToLecture(ctx context.Context, lecture *models.Lecture, user *models.User) error
-	DropUserFromLecture(ctx context.Context, lecture *models.Lecture, user *models.User) error
-	GetLecturesAndStudentsPP(ctx context.Context, page int, perPage int) ([]*models.Lecture, error)
+	CreateLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) (string, error)
+	AddUserToLecture(ctx context.Context, lecture *models.Lecture, user *models.User, events ...*models.OutboxEvent) error
+	DropUserFromLecture(ctx context.Context, lecture *models.Lecture, user *models.User, events ...*models.OutboxEvent) error
+	GetLecturesAndStudentsPP(ctx context.Context, pageRequest *models.PageRequest, filter *models.LectureFilter) (*models.LecturesPage, error)
+	GetLectureByID(ctx context.Context, lectureID *uuid.UUID) (*models.Lecture, error)
+	GetLectureStudents(ctx context.Context, lecture *models.Lecture) ([]*models.User, error)
+	GetLectureStudentsPP(ctx context.Context, lecture *models.Lecture, pageRequest *models.PageRequest) (*models.UsersPage, error)
+	GetLecturesStartingBetween(ctx context.Context, from time.Time, to time.Time) ([]*models.Lecture, error)
+	UpdateLectureStatus(ctx context.Context, lecture *models.Lecture, from models.LectureStatus, events ...*models.OutboxEvent) error
+	UpdateLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) error
+	DeleteLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) error
 }
 
 type repoLecture struct {
@@ -25,96 +61,521 @@ func NewRepoLecture(db *gorm.DB, log *zap.SugaredLogger) RepoLecture {
 	return &repoLecture{db: db, logger: log}
 }
 
-func (repo *repoLecture) CreateLecture(ctx context.Context, lecture *models.Lecture) (string, error) {
+func (repo *repoLecture) CreateLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) (string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
 	if lecture == nil {
 		appErr := apperrors.CreateLectureErr.AppendMessage("lecture is nil")
-		repo.logger.Error(appErr)
+		logger.Error(appErr)
 		return "", appErr
 	}
 
-	tx := repo.db.WithContext(ctx)
-	if tx.Error != nil {
-		appErr := apperrors.CreateLectureErr.AppendMessage(tx.Error)
-		repo.logger.Error(appErr)
-		return "", appErr
-	}
+	createdLecture := &models.Lecture{}
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Create(lecture)
+		if result.Error != nil {
+			return apperrors.CreateLectureErr.AppendMessage(result.Error)
+		}
 
-	result := tx.Create(lecture)
-	if result.Error != nil {
-		appErr := apperrors.CreateLectureErr.AppendMessage(result.Error)
-		repo.logger.Error(appErr)
-		return "", appErr
-	}
+		if result.RowsAffected == 0 {
+			return apperrors.CreateLectureErr.AppendMessage("no rows affected")
+		}
 
-	if result.RowsAffected == 0 {
-		appErr := apperrors.CreateLectureErr.AppendMessage("no rows affected")
-		repo.logger.Error(appErr)
-		return "", appErr
-	}
+		if err := tx.First(createdLecture, "id = ?", lecture.ID).Error; err != nil {
+			return apperrors.CreateLectureErr.AppendMessage(err)
+		}
 
-	createdLecture := &models.Lecture{}
-	if err := tx.First(createdLecture, "id = ?", lecture.ID).Error; err != nil {
-		appErr := apperrors.CreateLectureErr.AppendMessage(err)
-		repo.logger.Error(appErr)
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionLectureCreated, models.EntityLecture, createdLecture.ID.String(), nil, lectureAuditState(createdLecture))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.CreateLectureErr)
+		logger.Error(appErr)
 		return "", appErr
 	}
 
 	return createdLecture.ID.String(), nil
 }
 
-func (repo *repoLecture) AddUserToLecture(ctx context.Context, lecture *models.Lecture, user *models.User) error {
-	if err := repo.db.First(user, "id = ?", user.ID).Error; err != nil {
-		appErr := apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
-		repo.logger.Error(appErr)
+// AddUserToLecture enrolls user while the lecture row is locked, so the seat
+// count it checks against the capacity can't change before the insert.
+// Enrolling a student who already is one changes nothing, so it neither
+// publishes events nor leaves an audit entry.
+func (repo *repoLecture) AddUserToLecture(ctx context.Context, lecture *models.Lecture, user *models.User, events ...*models.OutboxEvent) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(user, "id = ?", user.ID).Error; err != nil {
+			return apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
+		}
+
+		locked, err := lockLecture(tx, lecture.ID)
+		if err != nil {
+			return apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
+		}
+
+		*lecture = *locked
+		if !lecture.IsBookable(time.Now()) {
+			return apperrors.LectureNotBookableErr.AppendMessage("status:", lecture.Status, "date:", lecture.Date)
+		}
+
+		var already int64
+		if err := tx.Table("lecture_students").Where("lecture_id = ? AND user_id = ?", lecture.ID, user.ID).Count(&already).Error; err != nil {
+			return apperrors.CountLectureStudentsErr.AppendMessage(err)
+		}
+
+		if already > 0 {
+			return nil
+		}
+
+		var enrolled int64
+		if err := tx.Table("lecture_students").Where("lecture_id = ?", lecture.ID).Count(&enrolled).Error; err != nil {
+			return apperrors.CountLectureStudentsErr.AppendMessage(err)
+		}
+
+		if !lecture.HasFreeSeats(enrolled) {
+			return apperrors.LectureNotBookableErr.AppendMessage("no free seats left, capacity:", lecture.Capacity)
+		}
+
+		if err := tx.Model(&lecture).Association("Students").Append([]*models.User{user}); err != nil {
+			return apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
+		}
+
+		if err := tx.Save(lecture).Error; err != nil {
+			return apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
+		}
+
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionStudentEnrolled, models.EntityLecture, lecture.ID.String(), nil, map[string]interface{}{"student_id": user.ID})
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.AddStudentToLectureRepoErr)
+		logger.Error(appErr)
 		return appErr
 	}
 
-	if err := repo.db.First(lecture, "id = ?", lecture.ID).Error; err != nil {
-		appErr := apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
-		repo.logger.Error(appErr)
+	return nil
+}
+
+func (repo *repoLecture) DropUserFromLecture(ctx context.Context, lecture *models.Lecture, user *models.User, events ...*models.OutboxEvent) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var enrolled int64
+		if err := tx.Table("lecture_students").Where("lecture_id = ? AND user_id = ?", lecture.ID, user.ID).Count(&enrolled).Error; err != nil {
+			return apperrors.CountLectureStudentsErr.AppendMessage(err)
+		}
+
+		// Dropping a student who isn't enrolled changes nothing, so nobody is
+		// told and nothing is audited.
+		if enrolled == 0 {
+			return nil
+		}
+
+		association := tx.Model(lecture).Association("Students")
+		if association.Error != nil {
+			return apperrors.DropUserFromLectureErr.AppendMessage(association.Error)
+		}
+
+		if err := association.Delete(user); err != nil {
+			return apperrors.DropUserFromLectureErr.AppendMessage(err)
+		}
+
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionStudentRemoved, models.EntityLecture, lecture.ID.String(), map[string]interface{}{"student_id": user.ID}, nil)
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.DropUserFromLectureErr)
+		logger.Error(appErr)
 		return appErr
 	}
 
-	if err := repo.db.Model(&lecture).Association("Students").Append([]*models.User{user}); err != nil {
-		appErr := apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
-		repo.logger.Error(appErr)
-		return appErr
+	return nil
+}
+
+func (repo *repoLecture) GetLecturesAndStudentsPP(ctx context.Context, pageRequest *models.PageRequest, filter *models.LectureFilter) (*models.LecturesPage, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	after, err := decodeCursor(pageRequest.Cursor, filter.Sort)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	query := applyLectureFilter(repo.db.WithContext(ctx).Model(&models.Lecture{}), filter)
+	var totalCount *int64
+	if pageRequest.WithTotal {
+		totalCount = new(int64)
+		if err := query.Session(&gorm.Session{}).Count(totalCount).Error; err != nil {
+			appErr := apperrors.GetLecturesStudentsPPErr.AppendMessage(err)
+			logger.Error(appErr)
+			return nil, appErr
+		}
+	}
+
+	sortKeyset := lectureKeyset(filter)
+	if filter.Sort == models.LectureSortRelevance {
+		query = query.Select("lectures.*, "+lectureStudentsCount+" AS students_count, "+lectureSearchRank+" AS search_rank", filter.Query)
+	} else {
+		query = query.Select("lectures.*, " + lectureStudentsCount + " AS students_count")
+	}
+
+	if filter.IncludeStudents {
+		query = query.Preload("Students", func(db *gorm.DB) *gorm.DB {
+			return db.Select(studentColumns)
+		})
+	}
+
+	query = sortKeyset.apply(query, after)
+	if after == nil && pageRequest.Page > 1 {
+		query = query.Offset((pageRequest.Page - 1) * pageRequest.PerPage)
+	}
+
+	var lectures []*models.Lecture
+	if err := query.Limit(pageRequest.PerPage + 1).Find(&lectures).Error; err != nil {
+		appErr := apperrors.GetLecturesStudentsPPErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	lecturesPage := &models.LecturesPage{}
+	lecturesPage.Lectures, lecturesPage.PageInfo = paginate(lectures, pageRequest, after, filter.Sort, func(lecture *models.Lecture) (string, string) {
+		return lectureSortValue(lecture, filter.Sort), lecture.ID.String()
+	})
+	lecturesPage.TotalCount = totalCount
+	return lecturesPage, nil
+}
+
+func applyLectureFilter(query *gorm.DB, filter *models.LectureFilter) *gorm.DB {
+	query = query.Where("lectures.status = ?", filter.Status)
+	if filter.From != nil {
+		query = query.Where("lectures.date >= ?", *filter.From)
 	}
 
-	if err := repo.db.Save(lecture).Error; err != nil {
-		appErr := apperrors.AddStudentToLectureRepoErr.AppendMessage(err)
-		repo.logger.Error(appErr)
+	if filter.To != nil {
+		query = query.Where("lectures.date <= ?", *filter.To)
+	}
+
+	if filter.Speaker != "" {
+		query = query.Where("lectures.speaker ILIKE ?", "%"+filter.Speaker+"%")
+	}
+
+	if filter.Location != "" {
+		query = query.Where("lectures.location ILIKE ?", "%"+filter.Location+"%")
+	}
+
+	if filter.HasFreeSeats {
+		query = query.Where("(lectures.capacity = 0 OR lectures.capacity > " + lectureStudentsCount + ")")
+	}
+
+	if filter.Query != "" {
+		query = query.Where("lectures.search_vector @@ "+lectureSearchQuery, filter.Query)
+	}
+
+	return query
+}
+
+func lectureKeyset(filter *models.LectureFilter) keyset {
+	if filter.Sort == models.LectureSortRelevance {
+		return keyset{column: lectureSearchRank, vars: []interface{}{filter.Query}, id: "lectures.id", desc: true}
+	}
+
+	sortKeyset, ok := lectureSortColumns[filter.Sort]
+	if !ok {
+		sortKeyset = lectureSortColumns[models.LectureSortCreatedAtDesc]
+	}
+
+	sortKeyset.id = "lectures.id"
+	return sortKeyset
+}
+
+func lectureSortValue(lecture *models.Lecture, sort string) string {
+	switch sort {
+	case models.LectureSortDate, models.LectureSortDateDesc:
+		return lecture.Date.Format(time.RFC3339Nano)
+	case models.LectureSortTitle, models.LectureSortTitleDesc:
+		return lecture.Title
+	case models.LectureSortRelevance:
+		return strconv.FormatFloat(float64(lecture.SearchRank), 'g', -1, 32)
+	default:
+		return lecture.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// GetLectureByID reads from the primary: enrolment and status changes start
+// with it and must not act on a row a replica hasn't caught up on.
+func (repo *repoLecture) GetLectureByID(ctx context.Context, lectureID *uuid.UUID) (*models.Lecture, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	lecture := &models.Lecture{}
+	if err := repo.db.WithContext(ctx).Clauses(dbresolver.Write).First(lecture, "id = ?", lectureID).Error; err != nil {
+		appErr := apperrors.GetLectureByIDErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return lecture, nil
+}
+
+// GetLectureStudents reads from the primary; a cancellation notifies the
+// students it returns, which must include those who enrolled moments ago.
+func (repo *repoLecture) GetLectureStudents(ctx context.Context, lecture *models.Lecture) ([]*models.User, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	var students []*models.User
+	if err := repo.db.WithContext(ctx).Clauses(dbresolver.Write).Model(lecture).Select(studentColumns).Association("Students").Find(&students); err != nil {
+		appErr := apperrors.GetLectureStudentsErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return students, nil
+}
+
+func (repo *repoLecture) GetLectureStudentsPP(ctx context.Context, lecture *models.Lecture, pageRequest *models.PageRequest) (*models.UsersPage, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	after, err := decodeCursor(pageRequest.Cursor, usersSort)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	query := repo.db.WithContext(ctx).Model(&models.User{}).
+		Joins("JOIN lecture_students ON lecture_students.user_id = users.id").
+		Where("lecture_students.lecture_id = ?", lecture.ID)
+	var totalCount *int64
+	if pageRequest.WithTotal {
+		totalCount = new(int64)
+		if err := query.Session(&gorm.Session{}).Count(totalCount).Error; err != nil {
+			appErr := apperrors.GetLectureStudentsErr.AppendMessage(err)
+			logger.Error(appErr)
+			return nil, appErr
+		}
+	}
+
+	query = usersKeyset.apply(query.Select(studentColumns), after)
+	if after == nil && pageRequest.Page > 1 {
+		query = query.Offset((pageRequest.Page - 1) * pageRequest.PerPage)
+	}
+
+	var students []*models.User
+	if err := query.Limit(pageRequest.PerPage + 1).Find(&students).Error; err != nil {
+		appErr := apperrors.GetLectureStudentsErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	studentsPage := &models.UsersPage{}
+	studentsPage.Users, studentsPage.PageInfo = paginate(students, pageRequest, after, usersSort, userSortValue)
+	studentsPage.TotalCount = totalCount
+	return studentsPage, nil
+}
+
+// GetLecturesStartingBetween returns the published lectures starting in
+// [from, to), with their students.
+func (repo *repoLecture) GetLecturesStartingBetween(ctx context.Context, from time.Time, to time.Time) ([]*models.Lecture, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	var lectures []*models.Lecture
+	err := repo.db.WithContext(ctx).
+		Where("status = ? AND date >= ? AND date < ?", models.LectureStatusPublished, from, to).
+		Preload("Students", func(db *gorm.DB) *gorm.DB {
+			return db.Select(studentColumns)
+		}).
+		Order("date ASC").Find(&lectures).Error
+	if err != nil {
+		appErr := apperrors.GetLecturesStartingBetweenErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return lectures, nil
+}
+
+// UpdateLectureStatus only applies the change while the lecture is still in the
+// from status, so two concurrent transitions can't both succeed.
+func (repo *repoLecture) UpdateLectureStatus(ctx context.Context, lecture *models.Lecture, from models.LectureStatus, events ...*models.OutboxEvent) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockLecture(tx, lecture.ID)
+		if err != nil {
+			return apperrors.UpdateLectureStatusErr.AppendMessage(err)
+		}
+
+		result := tx.Model(&models.Lecture{}).
+			Where("id = ? AND status = ?", lecture.ID, from).
+			Updates(map[string]interface{}{"status": lecture.Status, "cancel_reason": lecture.CancelReason, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return apperrors.UpdateLectureStatusErr.AppendMessage(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.UpdateLectureStatusErr.AppendMessage("lecture status has changed concurrently")
+		}
+
+		if lecture.Status == models.LectureStatusCancelled {
+			same, err := sameStudents(tx, lecture)
+			if err != nil {
+				return apperrors.UpdateLectureStatusErr.AppendMessage(err)
+			}
+
+			if !same {
+				return apperrors.UpdateLectureStatusErr.AppendMessage("lecture students have changed concurrently")
+			}
+		}
+
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionLectureStatusChanged, models.EntityLecture, lecture.ID.String(), lectureAuditState(before), lectureAuditState(lecture))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.UpdateLectureStatusErr)
+		logger.Error(appErr)
 		return appErr
 	}
 
+	lecture.Version++
 	return nil
 }
 
-func (repo *repoLecture) DropUserFromLecture(ctx context.Context, lecture *models.Lecture, user *models.User) error {
-	association := repo.db.Model(&models.Lecture{}).Association("Students")
-	if association.Error != nil {
-		appErr := apperrors.DropUserFromLectureErr.AppendMessage(association.Error)
-		repo.logger.Error(appErr)
-		return appErr
+// sameStudents tells whether the students loaded into lecture are exactly the
+// ones enrolled now. A cancellation checks it under the lecture lock, so the
+// students its event notifies are the ones it actually cancelled the lecture
+// for.
+func sameStudents(tx *gorm.DB, lecture *models.Lecture) (bool, error) {
+	var enrolled []uuid.UUID
+	if err := tx.Table("lecture_students").Where("lecture_id = ?", lecture.ID).Pluck("user_id", &enrolled).Error; err != nil {
+		return false, err
 	}
 
-	if err := association.Delete(user); err != nil {
-		appErr := apperrors.DropUserFromLectureErr.AppendMessage(err)
-		repo.logger.Error(appErr)
+	if len(enrolled) != len(lecture.Students) {
+		return false, nil
+	}
+
+	loaded := make(map[uuid.UUID]bool, len(lecture.Students))
+	for _, student := range lecture.Students {
+		loaded[*student.ID] = true
+	}
+
+	for _, id := range enrolled {
+		if !loaded[id] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// lockLecture reads the current row of a lecture about to be changed and locks
+// it until the transaction ends, so the audit log records the state the
+// change actually replaced.
+func lockLecture(tx *gorm.DB, lectureID *uuid.UUID) (*models.Lecture, error) {
+	lecture := &models.Lecture{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(lecture, "id = ?", lectureID).Error; err != nil {
+		return nil, err
+	}
+
+	return lecture, nil
+}
+
+// UpdateLecture saves the editable fields of lecture unless it was changed
+// since lecture.Version was read, and moves it to the next version.
+func (repo *repoLecture) UpdateLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockLecture(tx, lecture.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID, "was deleted")
+		}
+
+		if err != nil {
+			return apperrors.UpdateLectureErr.AppendMessage(err)
+		}
+
+		result := tx.Model(&models.Lecture{}).
+			Where("id = ? AND version = ?", lecture.ID, lecture.Version).
+			Updates(map[string]interface{}{
+				"title":       lecture.Title,
+				"description": lecture.Description,
+				"speaker":     lecture.Speaker,
+				"date":        lecture.Date,
+				"location":    lecture.Location,
+				"duration":    lecture.Duration,
+				"capacity":    lecture.Capacity,
+				"version":     gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return apperrors.UpdateLectureErr.AppendMessage(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID, "version", lecture.Version)
+		}
+
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionLectureUpdated, models.EntityLecture, lecture.ID.String(), lectureAuditState(before), lectureAuditState(lecture))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.UpdateLectureErr)
+		logger.Error(appErr)
 		return appErr
 	}
 
+	lecture.Version++
 	return nil
 }
 
-func (repo *repoLecture) GetLecturesAndStudentsPP(ctx context.Context, page int, perPage int) ([]*models.Lecture, error) {
-	var lectures []*models.Lecture
-	offset := (page - 1) * perPage
-	if err := repo.db.Preload("Students").Order("created_at DESC").Offset(offset).Limit(perPage).Find(&lectures).Error; err != nil {
-		appErr := apperrors.GetLecturesStudentsPPErr.AppendMessage(err)
-		repo.logger.Error(appErr)
-		return nil, appErr
+// DeleteLecture soft-deletes lecture unless it was changed since
+// lecture.Version was read. A lecture with enrolled students is refused: it
+// has to be cancelled so they are told.
+func (repo *repoLecture) DeleteLecture(ctx context.Context, lecture *models.Lecture, events ...*models.OutboxEvent) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockLecture(tx, lecture.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID, "was deleted")
+		}
+
+		if err != nil {
+			return apperrors.DeleteLectureErr.AppendMessage(err)
+		}
+
+		var enrolled int64
+		if err := tx.Table("lecture_students").Where("lecture_id = ?", lecture.ID).Count(&enrolled).Error; err != nil {
+			return apperrors.CountLectureStudentsErr.AppendMessage(err)
+		}
+
+		if enrolled > 0 {
+			return apperrors.LectureHasStudentsErr.AppendMessage("students:", enrolled)
+		}
+
+		result := tx.Where("id = ? AND version = ?", lecture.ID, lecture.Version).Delete(&models.Lecture{})
+		if result.Error != nil {
+			return apperrors.DeleteLectureErr.AppendMessage(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID, "version", lecture.Version)
+		}
+
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionLectureDeleted, models.EntityLecture, lecture.ID.String(), lectureAuditState(before), nil)
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.DeleteLectureErr)
+		logger.Error(appErr)
+		return appErr
 	}
 
-	return lectures, nil
+	return nil
 }
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"web_service/internal/domain/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestDropUserFromLecture(t *testing.T) {
+	lectureID := uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	testTable := []struct {
+		scenario string
+		enrolled int64
+		changed  bool
+	}{
+		{scenario: "not enrolled", enrolled: 0},
+		{scenario: "enrolled", enrolled: 1, changed: true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			var recorded []*models.AuditEvent
+			db := auditDB(t, &recorded)
+			var outboxed []*models.OutboxEvent
+			err := db.Callback().Create().Before("gorm:create").Register("test:record_outbox", func(db *gorm.DB) {
+				if events, ok := db.Statement.Dest.([]*models.OutboxEvent); ok {
+					outboxed = append(outboxed, events...)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = db.Callback().Query().After("gorm:query").Register("test:count_enrolled", func(db *gorm.DB) {
+				if count, ok := db.Statement.Dest.(*int64); ok {
+					*count = tc.enrolled
+					db.RowsAffected = 1
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			event, err := models.NewOutboxEvent(models.EventStudentDropped, models.EntityLecture, lectureID.String(), &models.StudentDroppedEvent{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repo := NewRepoLecture(db, zap.NewNop().Sugar())
+			assert.NoError(t, repo.DropUserFromLecture(context.Background(), &models.Lecture{ID: &lectureID}, &models.User{ID: &userID}, event))
+
+			if !tc.changed {
+				assert.Empty(t, outboxed, "nobody is told")
+				assert.Empty(t, recorded, "nothing is audited")
+				return
+			}
+
+			assert.Equal(t, []*models.OutboxEvent{event}, outboxed)
+			if assert.Len(t, recorded, 1) {
+				assert.Equal(t, models.AuditActionStudentRemoved, recorded[0].Action)
+			}
+		})
+	}
+}
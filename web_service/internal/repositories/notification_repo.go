@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepo interface {
+	EnqueueNotifications(ctx context.Context, notifications []*models.Notification) (int64, error)
+	ClaimNotifications(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.Notification, error)
+	UpdateNotification(ctx context.Context, notification *models.Notification) error
+}
+
+type notificationRepo struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewNotificationRepo(db *gorm.DB, logger *zap.SugaredLogger) NotificationRepo {
+	return &notificationRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// EnqueueNotifications queues notifications, skipping those whose DedupKey is
+// already queued, and returns how many were new.
+func (repo *notificationRepo) EnqueueNotifications(ctx context.Context, notifications []*models.Notification) (int64, error) {
+	if len(notifications) == 0 {
+		return 0, nil
+	}
+
+	logger := logging.FromContext(ctx, repo.logger)
+	result := repo.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&notifications)
+	if result.Error != nil {
+		appErr := apperrors.NotificationRepoErr.AppendMessage(result.Error)
+		logger.Error(appErr)
+		return 0, appErr
+	}
+
+	return result.RowsAffected, nil
+}
+
+// ClaimNotifications takes up to limit due notifications, with their student
+// and lecture, and hides them from other senders for lease, the same way
+// ClaimOutboxEvents does. A student or lecture deleted since is left nil.
+func (repo *notificationRepo) ClaimNotifications(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.Notification, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	var notifications []*models.Notification
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var claimed []*models.Notification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.NotificationPending, now).
+			Order("next_attempt_at ASC").Limit(limit).Find(&claimed).Error
+		if err != nil || len(claimed) == 0 {
+			return err
+		}
+
+		ids := make([]interface{}, 0, len(claimed))
+		for _, notification := range claimed {
+			ids = append(ids, notification.ID)
+		}
+
+		if err := tx.Model(&models.Notification{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("User").Preload("Lecture").Where("id IN ?", ids).Order("next_attempt_at ASC").Find(&notifications).Error
+	})
+	if err != nil {
+		appErr := apperrors.NotificationRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return notifications, nil
+}
+
+// UpdateNotification saves the state the sender moved notification to.
+func (repo *notificationRepo) UpdateNotification(ctx context.Context, notification *models.Notification) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Model(&models.Notification{}).Where("id = ?", notification.ID).Updates(map[string]interface{}{
+		"status":          notification.Status,
+		"attempts":        notification.Attempts,
+		"next_attempt_at": notification.NextAttemptAt,
+		"last_error":      notification.LastError,
+		"sent_at":         notification.SentAt,
+	}).Error
+	if err != nil {
+		appErr := apperrors.NotificationRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepo interface {
+	ClaimOutboxEvents(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkOutboxEventPublished(ctx context.Context, event *models.OutboxEvent, publishedAt time.Time) error
+	MarkOutboxEventFailed(ctx context.Context, event *models.OutboxEvent, nextAttemptAt time.Time, reason string) error
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxRepo struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewOutboxRepo(db *gorm.DB, logger *zap.SugaredLogger) OutboxRepo {
+	return &outboxRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ClaimOutboxEvents takes up to limit due events, oldest first, and hides them
+// from other relays for lease. Rows another relay is claiming at the same
+// moment are skipped rather than waited for. An event whose relay dies before
+// marking it is claimed again once the lease runs out, so delivery is at
+// least once.
+func (repo *outboxRepo) ClaimOutboxEvents(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	var events []*models.OutboxEvent
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND next_attempt_at <= ?", now).
+			Order("occurred_at ASC").Limit(limit).Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]interface{}, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		appErr := apperrors.OutboxRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return events, nil
+}
+
+func (repo *outboxRepo) MarkOutboxEventPublished(ctx context.Context, event *models.OutboxEvent, publishedAt time.Time) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", event.ID).
+		Updates(map[string]interface{}{"published_at": publishedAt, "attempts": gorm.Expr("attempts + 1"), "last_error": ""}).Error
+	if err != nil {
+		appErr := apperrors.OutboxRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+func (repo *outboxRepo) MarkOutboxEventFailed(ctx context.Context, event *models.OutboxEvent, nextAttemptAt time.Time, reason string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", event.ID).
+		Updates(map[string]interface{}{"next_attempt_at": nextAttemptAt, "attempts": gorm.Expr("attempts + 1"), "last_error": reason}).Error
+	if err != nil {
+		appErr := apperrors.OutboxRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+func (repo *outboxRepo) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	result := repo.db.WithContext(ctx).Where("published_at < ?", before).Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		appErr := apperrors.OutboxRepoErr.AppendMessage(result.Error)
+		logger.Error(appErr)
+		return 0, appErr
+	}
+
+	return result.RowsAffected, nil
+}
+
+// enqueueEvents adds events to the outbox through tx, the transaction of the
+// change they announce.
+func enqueueEvents(tx *gorm.DB, events []*models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := tx.Create(events).Error; err != nil {
+		return apperrors.OutboxRepoErr.AppendMessage(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cursor points at the row a page starts after. It is handed to clients as an
+// opaque base64 string, so its layout may change without notice.
+type cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       string `json:"id"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded string, sort string) (*cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, apperrors.InvalidCursorErr.AppendMessage(err)
+	}
+
+	c := &cursor{}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, apperrors.InvalidCursorErr.AppendMessage(err)
+	}
+
+	if c.Sort != sort || c.ID == "" {
+		return nil, apperrors.InvalidCursorErr.AppendMessage("cursor was issued for another sort order")
+	}
+
+	return c, nil
+}
+
+// keyset orders a query by one sort expression with the primary key as a
+// tie-breaker, and seeks past a cursor with a row comparison so deep pages cost
+// the same as the first one.
+type keyset struct {
+	column string
+	vars   []interface{}
+	id     string
+	desc   bool
+}
+
+func (k keyset) apply(query *gorm.DB, after *cursor) *gorm.DB {
+	desc := k.desc
+	if after != nil && after.Backward {
+		desc = !desc
+	}
+
+	direction, operator := "ASC", ">"
+	if desc {
+		direction, operator = "DESC", "<"
+	}
+
+	if after != nil {
+		vars := append(append([]interface{}{}, k.vars...), after.Value, after.ID)
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", k.column, k.id, operator), vars...)
+	}
+
+	return query.Clauses(clause.OrderBy{Expression: clause.Expr{
+		SQL:                fmt.Sprintf("%s %s, %s %s", k.column, direction, k.id, direction),
+		Vars:               k.vars,
+		WithoutParentheses: true,
+	}})
+}
+
+// paginate trims the extra row fetched to detect further pages, restores the
+// natural order of a backward page and builds the cursors around it.
+func paginate[T any](rows []T, pageRequest *models.PageRequest, after *cursor, sort string, keyOf func(T) (string, string)) ([]T, models.PageInfo) {
+	pageInfo := models.PageInfo{}
+	hasMore := len(rows) > pageRequest.PerPage
+	if hasMore {
+		rows = rows[:pageRequest.PerPage]
+	}
+
+	backward := after != nil && after.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, pageInfo
+	}
+
+	hasNext := hasMore || backward
+	hasPrev := (hasMore && backward) || (!backward && (after != nil || pageRequest.Page > 1))
+	if hasNext {
+		value, id := keyOf(rows[len(rows)-1])
+		pageInfo.NextCursor = encodeCursor(cursor{Sort: sort, Value: value, ID: id})
+	}
+
+	if hasPrev {
+		value, id := keyOf(rows[0])
+		pageInfo.PrevCursor = encodeCursor(cursor{Sort: sort, Value: value, ID: id, Backward: true})
+	}
+
+	return rows, pageInfo
+}
@@ -2,63 +2,256 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"web_service/internal/apperrors"
 	"web_service/internal/domain/models"
+	"web_service/internal/logging"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 )
 
+// UserRepo writes take the domain events they emit, which are added to the
+// outbox in the same transaction.
 type UserRepo interface {
-	CreateUser(ctx context.Context, user *models.User) (string, error)
+	CreateUser(ctx context.Context, user *models.User, events ...*models.OutboxEvent) (string, error)
+	GetUsers(ctx context.Context, pageRequest *models.PageRequest) (*models.UsersPage, error)
+	GetUserByID(ctx context.Context, userID *uuid.UUID) (*models.User, error)
+	GetUserLectures(ctx context.Context, user *models.User, filter *models.ScheduleFilter) ([]*models.Lecture, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, user *models.User) error
 }
 
+const usersSort = "-created_at"
+
+var usersKeyset = keyset{column: "users.created_at", id: "users.id", desc: true}
+
 type userRepo struct {
-	db     *gorm.DB
-	logger *zap.SugaredLogger
+	db             *gorm.DB
+	fingerprintKey []byte
+	logger         *zap.SugaredLogger
 }
 
-func NewUserRepo(db *gorm.DB, logger *zap.SugaredLogger) UserRepo {
+// NewUserRepo fingerprints emails in the audit log with fingerprintKey.
+func NewUserRepo(db *gorm.DB, fingerprintKey []byte, logger *zap.SugaredLogger) UserRepo {
 	return &userRepo{
-		db:     db,
-		logger: logger,
+		db:             db,
+		fingerprintKey: fingerprintKey,
+		logger:         logger,
 	}
 }
 
-func (repo *userRepo) CreateUser(ctx context.Context, user *models.User) (string, error) {
+func (repo *userRepo) CreateUser(ctx context.Context, user *models.User, events ...*models.OutboxEvent) (string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
 	if user == nil {
 		appErr := apperrors.CreateUserErr.AppendMessage("user is nil")
-		repo.logger.Error(appErr)
+		logger.Error(appErr)
 		return "", appErr
 	}
 
-	tx := repo.db.WithContext(ctx)
-	if tx.Error != nil {
-		appErr := apperrors.CreateUserErr.AppendMessage(tx.Error)
-		repo.logger.Error(appErr)
+	createdUser := &models.User{}
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Create(user)
+		if result.Error != nil {
+			return apperrors.CreateUserErr.AppendMessage(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.CreateUserErr.AppendMessage("no rows affected")
+		}
+
+		if err := tx.First(createdUser, "id = ?", user.ID).Error; err != nil {
+			return apperrors.CreateUserErr.AppendMessage(err)
+		}
+
+		if err := enqueueEvents(tx, events); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionUserCreated, models.EntityUser, createdUser.ID.String(), nil, userAuditState(createdUser, repo.fingerprintKey))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.CreateUserErr)
+		logger.Error(appErr)
 		return "", appErr
 	}
 
-	result := tx.Create(user)
-	if result.Error != nil {
-		appErr := apperrors.CreateUserErr.AppendMessage(result.Error)
-		repo.logger.Error(appErr)
-		return "", appErr
+	return createdUser.ID.String(), nil
+}
+
+func (repo *userRepo) GetUsers(ctx context.Context, pageRequest *models.PageRequest) (*models.UsersPage, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	after, err := decodeCursor(pageRequest.Cursor, usersSort)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
 	}
 
-	if result.RowsAffected == 0 {
-		appErr := apperrors.CreateUserErr.AppendMessage("no rows affected")
-		repo.logger.Error(appErr)
-		return "", appErr
+	query := repo.db.WithContext(ctx).Model(&models.User{})
+	var totalCount *int64
+	if pageRequest.WithTotal {
+		totalCount = new(int64)
+		if err := query.Session(&gorm.Session{}).Count(totalCount).Error; err != nil {
+			appErr := apperrors.GetUsersErr.AppendMessage(err)
+			logger.Error(appErr)
+			return nil, appErr
+		}
 	}
 
-	createdUser := &models.User{}
-	if err := tx.First(createdUser, "id = ?", user.ID).Error; err != nil {
-		appErr := apperrors.CreateUserErr.AppendMessage(err)
-		repo.logger.Error(appErr)
-		return "", appErr
+	query = usersKeyset.apply(query, after)
+	if after == nil && pageRequest.Page > 1 {
+		query = query.Offset((pageRequest.Page - 1) * pageRequest.PerPage)
 	}
 
-	return createdUser.ID.String(), nil
+	var users []*models.User
+	if err := query.Omit("password").Limit(pageRequest.PerPage + 1).Find(&users).Error; err != nil {
+		appErr := apperrors.GetUsersErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	usersPage := &models.UsersPage{}
+	usersPage.Users, usersPage.PageInfo = paginate(users, pageRequest, after, usersSort, userSortValue)
+	usersPage.TotalCount = totalCount
+	return usersPage, nil
+}
+
+func userSortValue(user *models.User) (string, string) {
+	return user.CreatedAt.Format(time.RFC3339Nano), user.ID.String()
+}
+
+// GetUserByID reads from the primary: conditional updates compare the version
+// it returns, which a lagging replica could report stale.
+func (repo *userRepo) GetUserByID(ctx context.Context, userID *uuid.UUID) (*models.User, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	user := &models.User{}
+	if err := repo.db.WithContext(ctx).Clauses(dbresolver.Write).Omit("password").First(user, "id = ?", userID).Error; err != nil {
+		appErr := apperrors.GetUserByIDErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return user, nil
+}
+
+func (repo *userRepo) GetUserLectures(ctx context.Context, user *models.User, filter *models.ScheduleFilter) ([]*models.Lecture, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	query := repo.db.WithContext(ctx).Model(&models.Lecture{}).
+		Joins("JOIN lecture_students ON lecture_students.lecture_id = lectures.id").
+		Where("lecture_students.user_id = ?", user.ID)
+	if filter.From != nil {
+		query = query.Where("lectures.date >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		query = query.Where("lectures.date <= ?", *filter.To)
+	}
+
+	var lectures []*models.Lecture
+	if err := query.Order("lectures.date ASC").Order("lectures.id ASC").Find(&lectures).Error; err != nil {
+		appErr := apperrors.GetUserLecturesErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return lectures, nil
+}
+
+// UpdateUser saves the editable profile fields of user unless it was changed
+// since user.Version was read, and moves it to the next version.
+func (repo *userRepo) UpdateUser(ctx context.Context, user *models.User) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockUser(tx, user.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.StaleVersionErr.AppendMessage("user", user.ID, "was deleted")
+		}
+
+		if err != nil {
+			return apperrors.UpdateUserErr.AppendMessage(err)
+		}
+
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND version = ?", user.ID, user.Version).
+			Updates(map[string]interface{}{
+				"first_name": user.FirstName,
+				"last_name":  user.LastName,
+				"role":       user.Role,
+				"locale":     user.Locale,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return apperrors.UpdateUserErr.AppendMessage(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.StaleVersionErr.AppendMessage("user", user.ID, "version", user.Version)
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionUserUpdated, models.EntityUser, user.ID.String(), userAuditState(before, repo.fingerprintKey), userAuditState(user, repo.fingerprintKey))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.UpdateUserErr)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	user.Version++
+	return nil
+}
+
+// DeleteUser soft-deletes user and drops its enrolments unless it was changed
+// since user.Version was read.
+func (repo *userRepo) DeleteUser(ctx context.Context, user *models.User) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockUser(tx, user.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.StaleVersionErr.AppendMessage("user", user.ID, "was deleted")
+		}
+
+		if err != nil {
+			return apperrors.DeleteUserErr.AppendMessage(err)
+		}
+
+		result := tx.Where("id = ? AND version = ?", user.ID, user.Version).Delete(&models.User{})
+		if result.Error != nil {
+			return apperrors.DeleteUserErr.AppendMessage(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.StaleVersionErr.AppendMessage("user", user.ID, "version", user.Version)
+		}
+
+		// The row is only soft deleted, so its enrolments would keep holding
+		// seats and listing the user as a student.
+		if err := tx.Model(&models.User{ID: user.ID}).Association("Lectures").Clear(); err != nil {
+			return apperrors.DeleteUserErr.AppendMessage(err)
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionUserDeleted, models.EntityUser, user.ID.String(), userAuditState(before, repo.fingerprintKey), nil)
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.DeleteUserErr)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+// lockUser reads and locks the current row of a user about to be changed, for
+// the audit log's before state.
+func lockUser(tx *gorm.DB, userID *uuid.UUID) (*models.User, error) {
+	user := &models.User{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("password").First(user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
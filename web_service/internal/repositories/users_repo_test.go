@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"web_service/internal/domain/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestDeleteUserClearsEnrolments(t *testing.T) {
+	var recorded []*models.AuditEvent
+	db := auditDB(t, &recorded)
+	var deletes []string
+	err := db.Callback().Delete().After("gorm:delete").Register("test:record_delete", func(db *gorm.DB) {
+		deletes = append(deletes, db.Statement.SQL.String())
+		db.RowsAffected = 1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	repo := NewUserRepo(db, []byte("test-fingerprint-key"), zap.NewNop().Sugar())
+	assert.NoError(t, repo.DeleteUser(context.Background(), &models.User{ID: &userID, Version: 1}))
+
+	if assert.Len(t, deletes, 2) {
+		assert.Contains(t, deletes[0], `UPDATE "users" SET "deleted_at"`)
+		assert.Contains(t, deletes[1], `DELETE FROM "lecture_students" WHERE "lecture_students"."user_id" = $1`)
+	}
+
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, models.AuditActionUserDeleted, recorded[0].Action)
+	}
+}
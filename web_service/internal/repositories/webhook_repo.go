@@ -0,0 +1,316 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const webhookDeliveriesSort = "-created_at"
+
+var webhookDeliveriesKeyset = keyset{column: "webhook_deliveries.created_at", id: "webhook_deliveries.id", desc: true}
+
+type WebhookRepo interface {
+	CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, subscriptionID *uuid.UUID) error
+	EnqueueWebhookDeliveries(ctx context.Context, event *models.OutboxEvent, body []byte) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	RecordWebhookDeliveryAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error
+	GetWebhookDeliveries(ctx context.Context, pageRequest *models.PageRequest, filter *models.WebhookDeliveryFilter) (*models.WebhookDeliveriesPage, error)
+	RetryWebhookDelivery(ctx context.Context, deliveryID *uuid.UUID, now time.Time) (*models.WebhookDelivery, error)
+}
+
+type webhookRepo struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewWebhookRepo(db *gorm.DB, logger *zap.SugaredLogger) WebhookRepo {
+	return &webhookRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (repo *webhookRepo) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionWebhookCreated, models.EntityWebhook, subscription.ID.String(), nil, webhookAuditState(subscription))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.WebhookRepoErr)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+func (repo *webhookRepo) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	var subscriptions []*models.WebhookSubscription
+	if err := repo.db.WithContext(ctx).Order("created_at ASC").Find(&subscriptions).Error; err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription removes a subscription together with its
+// deliveries and their attempt log.
+func (repo *webhookRepo) DeleteWebhookSubscription(ctx context.Context, subscriptionID *uuid.UUID) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		subscription := &models.WebhookSubscription{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(subscription, "id = ?", subscriptionID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.WebhookSubscriptionNotFoundErr.AppendMessage(subscriptionID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", subscriptionID).Delete(&models.WebhookSubscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return apperrors.WebhookSubscriptionNotFoundErr.AppendMessage(subscriptionID)
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionWebhookDeleted, models.EntityWebhook, subscriptionID.String(), webhookAuditState(subscription), nil)
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.WebhookRepoErr)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries queues body for every subscription to the event's
+// type. An event the outbox relays again doesn't queue a second delivery to a
+// subscription that already has one.
+func (repo *webhookRepo) EnqueueWebhookDeliveries(ctx context.Context, event *models.OutboxEvent, body []byte) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	eventType, err := json.Marshal([]string{event.Type})
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return 0, appErr
+	}
+
+	var enqueued int64
+	err = repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var subscriptions []*models.WebhookSubscription
+		if err := tx.Where("event_types @> ?::jsonb", string(eventType)).Find(&subscriptions).Error; err != nil || len(subscriptions) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		deliveries := make([]*models.WebhookDelivery, 0, len(subscriptions))
+		for _, subscription := range subscriptions {
+			id := uuid.New()
+			deliveries = append(deliveries, &models.WebhookDelivery{
+				ID:             &id,
+				SubscriptionID: subscription.ID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				Body:           body,
+				Status:         models.WebhookDeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+			})
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries)
+		enqueued = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return 0, appErr
+	}
+
+	return enqueued, nil
+}
+
+// ClaimWebhookDeliveries takes up to limit due deliveries, with their
+// subscriptions, and hides them from other deliverers for lease, the same way
+// ClaimOutboxEvents does.
+func (repo *webhookRepo) ClaimWebhookDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	var deliveries []*models.WebhookDelivery
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var claimed []*models.WebhookDelivery
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("next_attempt_at ASC").Limit(limit).Find(&claimed).Error
+		if err != nil || len(claimed) == 0 {
+			return err
+		}
+
+		ids := make([]interface{}, 0, len(claimed))
+		for _, delivery := range claimed {
+			ids = append(ids, delivery.ID)
+		}
+
+		if err := tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("Subscription").Where("id IN ?", ids).Order("next_attempt_at ASC").Find(&deliveries).Error
+	})
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookDeliveryAttempt logs attempt and saves the state the deliverer
+// moved the delivery to.
+func (repo *webhookRepo) RecordWebhookDeliveryAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	logger := logging.FromContext(ctx, repo.logger)
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_error":      delivery.LastError,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+	})
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return nil
+}
+
+func (repo *webhookRepo) GetWebhookDeliveries(ctx context.Context, pageRequest *models.PageRequest, filter *models.WebhookDeliveryFilter) (*models.WebhookDeliveriesPage, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	after, err := decodeCursor(pageRequest.Cursor, webhookDeliveriesSort)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err := repo.db.WithContext(ctx).Select("id").First(&models.WebhookSubscription{}, "id = ?", filter.SubscriptionID).Error; err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			appErr = apperrors.WebhookSubscriptionNotFoundErr.AppendMessage(filter.SubscriptionID)
+		}
+
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	query := repo.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("webhook_deliveries.subscription_id = ?", filter.SubscriptionID)
+	if filter.Status != "" {
+		query = query.Where("webhook_deliveries.status = ?", filter.Status)
+	}
+
+	var totalCount *int64
+	if pageRequest.WithTotal {
+		totalCount = new(int64)
+		if err := query.Session(&gorm.Session{}).Count(totalCount).Error; err != nil {
+			appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+			logger.Error(appErr)
+			return nil, appErr
+		}
+	}
+
+	query = webhookDeliveriesKeyset.apply(query, after)
+	if after == nil && pageRequest.Page > 1 {
+		query = query.Offset((pageRequest.Page - 1) * pageRequest.PerPage)
+	}
+
+	var deliveries []*models.WebhookDelivery
+	err = query.Preload("AttemptLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempted_at ASC")
+	}).Limit(pageRequest.PerPage + 1).Find(&deliveries).Error
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	deliveriesPage := &models.WebhookDeliveriesPage{}
+	deliveriesPage.Deliveries, deliveriesPage.PageInfo = paginate(deliveries, pageRequest, after, webhookDeliveriesSort, func(delivery *models.WebhookDelivery) (string, string) {
+		return delivery.CreatedAt.Format(time.RFC3339Nano), delivery.ID.String()
+	})
+	deliveriesPage.TotalCount = totalCount
+	return deliveriesPage, nil
+}
+
+// RetryWebhookDelivery takes a dead delivery out of the dead letters and
+// queues it again, with a fresh set of attempts. The attempt count carries
+// on, so the attempt log never repeats a number.
+func (repo *webhookRepo) RetryWebhookDelivery(ctx context.Context, deliveryID *uuid.UUID, now time.Time) (*models.WebhookDelivery, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+	delivery := &models.WebhookDelivery{}
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(delivery, "id = ?", deliveryID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.WebhookDeliveryNotFoundErr.AppendMessage(deliveryID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if delivery.Status != models.WebhookDeliveryDead {
+			return apperrors.WebhookDeliveryNotDeadErr.AppendMessage(deliveryID, "is", delivery.Status)
+		}
+
+		before := deliveryAuditState(delivery)
+		delivery.Status, delivery.AttemptsBeforeRetry, delivery.NextAttemptAt = models.WebhookDeliveryPending, delivery.Attempts, now
+		err = tx.Model(&models.WebhookDelivery{}).Where("id = ?", deliveryID).Updates(map[string]interface{}{
+			"status":                delivery.Status,
+			"attempts_before_retry": delivery.AttemptsBeforeRetry,
+			"next_attempt_at":       delivery.NextAttemptAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, models.AuditActionDeliveryRetried, models.EntityWebhookDelivery, deliveryID.String(), before, deliveryAuditState(delivery))
+	})
+	if err != nil {
+		appErr := transactionErr(err, apperrors.WebhookRepoErr)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"web_service/internal/domain/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// webhookDB is an auditDB whose reads find stored, and whose deletes and
+// updates each hit one row.
+func webhookDB(t *testing.T, recorded *[]*models.AuditEvent, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) *gorm.DB {
+	db := auditDB(t, recorded)
+	err := db.Callback().Query().After("gorm:query").Register("test:find_stored", func(db *gorm.DB) {
+		switch dest := db.Statement.Dest.(type) {
+		case *models.WebhookSubscription:
+			*dest = *subscription
+		case *models.WebhookDelivery:
+			*dest = *delivery
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hitOneRow := func(db *gorm.DB) {
+		db.RowsAffected = 1
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register("test:hit_one_row", hitOneRow); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Callback().Update().After("gorm:update").Register("test:hit_one_row", hitOneRow); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestWebhookChangesAreAudited(t *testing.T) {
+	subscriptionID := uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	deliveryID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	subscription := &models.WebhookSubscription{
+		ID:         &subscriptionID,
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []byte(`["LectureCreated"]`),
+		Secret:     "correct-horse-battery-staple",
+	}
+	delivery := &models.WebhookDelivery{
+		ID:             &deliveryID,
+		SubscriptionID: &subscriptionID,
+		Status:         models.WebhookDeliveryDead,
+		Attempts:       10,
+	}
+
+	var recorded []*models.AuditEvent
+	repo := NewWebhookRepo(webhookDB(t, &recorded, subscription, delivery), zap.NewNop().Sugar())
+	ctx := context.Background()
+	assert.NoError(t, repo.CreateWebhookSubscription(ctx, subscription))
+	assert.NoError(t, repo.DeleteWebhookSubscription(ctx, &subscriptionID))
+	_, err := repo.RetryWebhookDelivery(ctx, &deliveryID, time.Now())
+	assert.NoError(t, err)
+
+	if !assert.Len(t, recorded, 3) {
+		return
+	}
+
+	created, deleted, retried := recorded[0], recorded[1], recorded[2]
+	assert.Equal(t, models.AuditActionWebhookCreated, created.Action)
+	assert.Equal(t, models.EntityWebhook, created.EntityType)
+	assert.Equal(t, subscriptionID.String(), created.EntityID)
+	assert.JSONEq(t, `{"url": "https://partner.example.com/hooks", "event_types": ["LectureCreated"]}`, string(created.After))
+
+	assert.Equal(t, models.AuditActionWebhookDeleted, deleted.Action)
+	assert.JSONEq(t, string(created.After), string(deleted.Before))
+	assert.Nil(t, deleted.After)
+
+	assert.Equal(t, models.AuditActionDeliveryRetried, retried.Action)
+	assert.Equal(t, models.EntityWebhookDelivery, retried.EntityType)
+	assert.Equal(t, deliveryID.String(), retried.EntityID)
+	assert.JSONEq(t, `{"status": "dead", "attempts_before_retry": 0}`, string(retried.Before))
+	assert.JSONEq(t, `{"status": "pending", "attempts_before_retry": 10}`, string(retried.After))
+
+	for _, event := range recorded {
+		assert.NotContains(t, string(event.Before)+string(event.After), subscription.Secret)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"web_service/internal/config"
+)
+
+// corsPolicy answers preflights itself and tags actual responses for the
+// allowed origins. Requests from other origins get no CORS headers, which
+// leaves the browser to block them.
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	methods          string
+	headers          string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// newCORSPolicy returns nil when no origin is allowed.
+func newCORSPolicy(conf *config.CORSConfig) *corsPolicy {
+	if len(conf.AllowedOrigins) == 0 {
+		return nil
+	}
+
+	policy := &corsPolicy{
+		origins:          map[string]bool{},
+		methods:          strings.Join(conf.AllowedMethods, ", "),
+		headers:          strings.Join(conf.AllowedHeaders, ", "),
+		exposedHeaders:   strings.Join(conf.ExposedHeaders, ", "),
+		allowCredentials: conf.AllowCredentials,
+		maxAge:           strconv.Itoa(int(conf.MaxAge / time.Second)),
+	}
+	for _, origin := range conf.AllowedOrigins {
+		policy.anyOrigin = policy.anyOrigin || origin == "*"
+		policy.origins[strings.ToLower(origin)] = true
+	}
+
+	return policy
+}
+
+// handle sets the CORS headers and reports whether the request was a
+// preflight it has already answered.
+func (policy *corsPolicy) handle(w http.ResponseWriter, r *http.Request) bool {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != ""
+	if origin == "" {
+		return false
+	}
+
+	if !policy.anyOrigin && !policy.origins[strings.ToLower(origin)] {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+		}
+
+		return preflight
+	}
+
+	if policy.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if policy.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if policy.exposedHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", policy.exposedHeaders)
+		}
+
+		return false
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", policy.methods)
+	if policy.headers != "" {
+		header.Set("Access-Control-Allow-Headers", policy.headers)
+	}
+
+	header.Set("Access-Control-Max-Age", policy.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// setSecurityHeaders hardens every response. The API only serves JSON, so the
+// CSP forbids loading anything at all.
+func (srv *server) setSecurityHeaders(header http.Header) {
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	if srv.hstsMaxAge > 0 {
+		header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(srv.hstsMaxAge/time.Second))+"; includeSubDomains")
+	}
+}
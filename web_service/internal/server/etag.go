@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"web_service/internal/domain/models"
+)
+
+// entityTag is the strong ETag of a resource at version.
+func entityTag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatch reads the If-Match header into the versions a write may replace.
+// Weak and foreign tags never match under the strong comparison If-Match
+// requires, so they are dropped. ok is false when the header is missing.
+func ifMatch(r *http.Request) (*models.VersionPrecondition, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return nil, false
+	}
+
+	if header == "*" {
+		return &models.VersionPrecondition{AnyVersion: true}, true
+	}
+
+	precondition := &models.VersionPrecondition{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		if version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil {
+			precondition.Versions = append(precondition.Versions, version)
+		}
+	}
+
+	return precondition, true
+}
+
+// notModified reports whether the If-None-Match header of a GET already
+// names the current version, using the weak comparison it calls for.
+func notModified(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := entityTag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+// respondVersioned sends data with the ETag of version, or an empty 304 when
+// the client's cached copy is current.
+func (srv *server) respondVersioned(w http.ResponseWriter, r *http.Request, data interface{}, version int64, status int) {
+	w.Header().Set("ETag", entityTag(version))
+	if r.Method == http.MethodGet && notModified(r, version) {
+		srv.respond(w, nil, http.StatusNotModified)
+		return
+	}
+
+	srv.respond(w, data, status)
+}
@@ -2,105 +2,265 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+	"web_service/internal/domain/models"
 	"web_service/internal/domain/requests"
 	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
 	"web_service/internal/services"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func (srv *server) createUserHandler() http.HandlerFunc {
 	srv.logger.Info("createUserHandler has been initiated.")
 	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
 		createUserRequest := &requests.CreateUserRequest{}
-		err := srv.decode(r, createUserRequest)
+		err := srv.decode(w, r, createUserRequest)
 		if err != nil {
 			appErr := apperrors.CreateUserHandlerErr.AppendMessage("DECODE ERR: ", err)
-			srv.logger.Error(appErr)
-			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
 			return
 		}
 
-		srv.logger.Infof("createUserHandler has been invoked. Request: %+v", createUserRequest)
+		logger.Infof("createUserHandler has been invoked. Request: %+v", createUserRequest)
+		if !srv.knownLocale(createUserRequest.Locale) {
+			appErr := apperrors.CreateUserHandlerErr.AppendMessage("unknown locale:", createUserRequest.Locale)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		if createUserRequest.Role != "" && createUserRequest.Role != models.RoleStudent {
+			if appErr := srv.requireAdmin(r.Context()); appErr != nil {
+				logger.Error(appErr)
+				srv.respond(w, appErr.Message, appErr.HTTPCode)
+				return
+			}
+		}
 
 		userService := services.NewUserService(srv.repoUsers, srv.logger)
-		srv.logger.Info("services.NewUserService")
+		logger.Info("services.NewUserService")
 
 		createUserResponse, err := userService.CreateUser(r.Context(), createUserRequest)
 		if err != nil {
-			srv.logger.Error(err)
-			appErrors := err.(*apperrors.AppError)
-			srv.respond(w, appErrors.Message, http.StatusInternalServerError)
+			logger.Error(err)
+			appErr := err.(*apperrors.AppError)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
 			return
 		}
 
-		srv.logger.Infof("createUserHandler has been processed. Response: %+v", createUserResponse)
+		logger.Infof("createUserHandler has been processed. Response: %+v", createUserResponse)
 		srv.respond(w, createUserResponse, http.StatusCreated)
 	}
 }
 
+func (srv *server) getUsersHandler() http.HandlerFunc {
+	srv.logger.Info("getUsersHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		query := r.URL.Query()
+		getUsersRequest := &requests.GetUsersRequest{
+			Page:         query.Get("page"),
+			PerPage:      query.Get("per_page"),
+			Cursor:       query.Get("cursor"),
+			IncludeTotal: query.Get("include_total"),
+		}
+
+		logger.Infof("getUsersHandler has been invoked. Request: %+v", getUsersRequest)
+		userService := services.NewUserService(srv.repoUsers, srv.logger)
+		getUsersResp, err := userService.GetUsers(r.Context(), getUsersRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getUsersHandler has been processed. Users: %v", len(getUsersResp.Users))
+		srv.respond(w, getUsersResp, http.StatusOK)
+	}
+}
+
+func (srv *server) getUserLecturesHandler() http.HandlerFunc {
+	srv.logger.Info("getUserLecturesHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		userId, ok := mux.Vars(r)["user_id"]
+		if !ok {
+			appErr := apperrors.GetUserLecturesHandlerErr.AppendMessage("Vars user_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		if appErr := srv.requireSelfOrAdmin(r.Context(), userId); appErr != nil {
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		srv.serveUserSchedule(w, r, userId)
+	}
+}
+
+func (srv *server) getMyScheduleHandler() http.HandlerFunc {
+	srv.logger.Info("getMyScheduleHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		userID, ok := auth.UserIDFromContext(r.Context())
+		if !ok {
+			srv.metrics.AuthenticationFailed()
+			appErr := apperrors.UnauthenticatedErr.AppendMessage("missing", srv.userIDHeader)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		srv.serveUserSchedule(w, r, userID.String())
+	}
+}
+
+func (srv *server) serveUserSchedule(w http.ResponseWriter, r *http.Request, userId string) {
+	logger := logging.FromContext(r.Context(), srv.logger)
+	query := r.URL.Query()
+	getScheduleRequest := &requests.GetUserScheduleRequest{
+		From: query.Get("from"),
+		To:   query.Get("to"),
+	}
+
+	logger.Infof("user schedule has been requested. Request: %+v, user_id: %v", getScheduleRequest, userId)
+	userService := services.NewUserService(srv.repoUsers, srv.logger)
+	getScheduleResp, err := userService.GetUserLectures(r.Context(), userId, getScheduleRequest)
+	if err != nil {
+		appErr := err.(*apperrors.AppError)
+		logger.Error(appErr)
+		srv.respond(w, appErr.Message, appErr.HTTPCode)
+		return
+	}
+
+	logger.Infof("user schedule has been processed. Upcoming: %v, past: %v", len(getScheduleResp.Upcoming), len(getScheduleResp.Past))
+	srv.respond(w, getScheduleResp, http.StatusOK)
+}
+
 func (srv *server) getLecturesPPHandler() http.HandlerFunc {
 	srv.logger.Info("getLecturesHandler has been initiated.")
 	return func(w http.ResponseWriter, r *http.Request) {
-		getLectsPPRequest := &requests.GetLecturesPPRequest{}
-		err := srv.decode(r, getLectsPPRequest)
+		logger := logging.FromContext(r.Context(), srv.logger)
+		query := r.URL.Query()
+		getLectsPPRequest := &requests.GetLecturesPPRequest{
+			Page:         query.Get("page"),
+			PerPage:      query.Get("per_page"),
+			From:         query.Get("from"),
+			To:           query.Get("to"),
+			Speaker:      query.Get("speaker"),
+			Location:     query.Get("location"),
+			HasFreeSeats: query.Get("has_free_seats"),
+			Status:       query.Get("status"),
+			Sort:         query.Get("sort"),
+			Q:            query.Get("q"),
+			Cursor:       query.Get("cursor"),
+			IncludeTotal: query.Get("include_total"),
+			Include:      query.Get("include"),
+		}
+
+		logger.Infof("getLecturesHandler has been invoked. Request: %+v", getLectsPPRequest)
+		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
+		getLecturesAndStudentsPPResp, err := lectureService.GetLecturesAndStudentsPP(r.Context(), getLectsPPRequest)
 		if err != nil {
-			appErr := apperrors.GetLecturesPPHandlerErr.AppendMessage("Bind lecture id")
-			srv.logger.Error(appErr)
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getLecturesHandler has been processed. Response: %+v", getLecturesAndStudentsPPResp)
+		srv.respond(w, getLecturesAndStudentsPPResp, http.StatusOK)
+	}
+}
+
+func (srv *server) getLectureStudentsHandler() http.HandlerFunc {
+	srv.logger.Info("getLectureStudentsHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		lectureId, ok := mux.Vars(r)["lecture_id"]
+		if !ok {
+			appErr := apperrors.GetLectureStudentsHandlerErr.AppendMessage("Vars lecture_id")
+			logger.Error(appErr)
 			srv.respond(w, appErr.Message, http.StatusBadRequest)
 			return
 		}
 
-		srv.logger.Infof("getLecturesHandler has been invoked. Page %v, PerPage %v", getLectsPPRequest.Page, getLectsPPRequest.PerPage)
+		query := r.URL.Query()
+		getStudentsRequest := &requests.GetLectureStudentsRequest{
+			Page:         query.Get("page"),
+			PerPage:      query.Get("per_page"),
+			Cursor:       query.Get("cursor"),
+			IncludeTotal: query.Get("include_total"),
+		}
+
+		logger.Infof("getLectureStudentsHandler has been invoked. Request: %+v, lecture_id: %v", getStudentsRequest, lectureId)
 		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
-		getLecturesAndStudentsPPResp, err := lectureService.GetLecturesAndStudentsPP(r.Context(), getLectsPPRequest.Page, getLectsPPRequest.PerPage)
+		getStudentsResp, err := lectureService.GetLectureStudents(r.Context(), lectureId, getStudentsRequest)
 		if err != nil {
 			appErr := err.(*apperrors.AppError)
-			srv.logger.Error(appErr)
-			srv.respond(w, appErr.Message, http.StatusInternalServerError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
 			return
 		}
 
-		srv.logger.Infof("getLecturesHandler has been processed. Response: %+v", getLecturesAndStudentsPPResp)
-		srv.respond(w, getLecturesAndStudentsPPResp, http.StatusOK)
+		logger.Infof("getLectureStudentsHandler has been processed. Students: %v", len(getStudentsResp.Students))
+		srv.respond(w, getStudentsResp, http.StatusOK)
 	}
 }
 
 func (srv *server) addUserToLectureHandler() http.HandlerFunc {
 	srv.logger.Info("addUserToLectureHandler has been initiated.")
 	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
 		addStudentToLectureRequest := &requests.AddStudentToLectureReq{}
-		err := srv.decode(r, addStudentToLectureRequest)
+		err := srv.decode(w, r, addStudentToLectureRequest)
 		if err != nil {
-			appErr := apperrors.AddStudentToLectureHandlerErr.AppendMessage("Bind user_id")
-			srv.logger.Error(appErr)
-			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			appErr := apperrors.AddStudentToLectureHandlerErr.AppendMessage("Bind user_id", err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
 			return
 		}
 
 		lectureId, ok := mux.Vars(r)["lecture_id"]
 		if !ok {
 			appErr := apperrors.AddStudentToLectureHandlerErr.AppendMessage("Vars lecture_id")
-			srv.logger.Error(appErr)
+			logger.Error(appErr)
 			srv.respond(w, appErr.Message, http.StatusBadRequest)
 			return
 		}
 
-		srv.logger.Infof("addUserToLectureHandler has been invoked. Response: %+v, and lecture_id:", addStudentToLectureRequest, lectureId)
+		logger.Infof("addUserToLectureHandler has been invoked. Request: %+v, lecture_id: %v", addStudentToLectureRequest, lectureId)
+		if appErr := srv.requireSelfOrAdmin(r.Context(), addStudentToLectureRequest.UserId); appErr != nil {
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
 
 		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
 		addUserToLectureResp, err := lectureService.AddUserToLecture(r.Context(), lectureId, addStudentToLectureRequest.UserId)
 		if err != nil {
-			srv.logger.Error(err)
+			logger.Error(err)
 			appErr := err.(*apperrors.AppError)
-			srv.respond(w, appErr.Message, http.StatusInternalServerError)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
 			return
 		}
 
-		srv.logger.Infof("addUserToLectureHandler has been processed. Response: %+v", addUserToLectureResp)
+		srv.metrics.StudentEnrolled()
+		logger.Infof("addUserToLectureHandler has been processed. Response: %+v", addUserToLectureResp)
 		srv.respond(w, addUserToLectureResp, http.StatusOK)
 	}
 }
@@ -109,36 +269,43 @@ func (srv *server) deleteUserFromLectureHandler() http.HandlerFunc {
 	srv.logger.Info("deleteUserHandler has been initiated.")
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
 		deleteStudentFromLectureRequest := &requests.DeleteStudentFromLectureRequest{}
-		err := srv.decode(r, deleteStudentFromLectureRequest)
+		err := srv.decode(w, r, deleteStudentFromLectureRequest)
 		if err != nil {
 			appErr := apperrors.DeleteUserFromLectureHandlerERR.AppendMessage(err)
-			srv.logger.Error(appErr)
-			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
 			return
 		}
 
 		lectureId, ok := mux.Vars(r)["lecture_id"]
 		if !ok {
 			appErr := apperrors.DeleteUserFromLectureHandlerERR.AppendMessage("Bind lecture id")
-			srv.logger.Error(appErr)
+			logger.Error(appErr)
 			srv.respond(w, appErr.Message, http.StatusBadRequest)
 			return
 		}
 
-		srv.logger.Infof("deleteUserFromLectureHandler has been invoked. Response: %+v, and lecture_id:", deleteStudentFromLectureRequest, lectureId)
+		logger.Infof("deleteUserFromLectureHandler has been invoked. Request: %+v, lecture_id: %v", deleteStudentFromLectureRequest, lectureId)
+		if appErr := srv.requireSelfOrAdmin(r.Context(), deleteStudentFromLectureRequest.UserId); appErr != nil {
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
 
 		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
 		err = lectureService.DeleteUserFromLecture(r.Context(), lectureId, deleteStudentFromLectureRequest.UserId)
 		if err != nil {
-			srv.logger.Error(err)
+			logger.Error(err)
 			appErr := err.(*apperrors.AppError)
-			srv.respond(w, appErr.Message, http.StatusInternalServerError)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
 			return
 		}
 
+		srv.metrics.StudentDropped()
 		DeleteStudentFromLectureResp := &responses.DeleteStudentFromLectureResponse{Result: "Success"}
-		srv.logger.Infof("deleteStudentFromLectureHandler has been processed. Response: %+v", DeleteStudentFromLectureResp)
+		logger.Infof("deleteStudentFromLectureHandler has been processed. Response: %+v", DeleteStudentFromLectureResp)
 		srv.respond(w, DeleteStudentFromLectureResp, http.StatusOK)
 	}
 }
@@ -147,43 +314,531 @@ func (srv *server) createLectureHandler() http.HandlerFunc {
 	srv.logger.Info("createLectureHandler has been initiated.")
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
 		createLectureRequest := &requests.CreateLectureRequest{}
-		err := srv.decode(r, createLectureRequest)
+		err := srv.decode(w, r, createLectureRequest)
 		if err != nil {
 			appErr := apperrors.CreateLectureHandlerErr.AppendMessage(err)
-			srv.logger.Error(appErr)
-			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
 			return
 		}
 
-		srv.logger.Infof("createLectureHandler has been invoked. Request: %+v", createLectureRequest)
+		logger.Infof("createLectureHandler has been invoked. Request: %+v", createLectureRequest)
 
 		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
 		createLectResp, err := lectureService.CreateLecture(r.Context(), createLectureRequest)
 		if err != nil {
 			appErr := err.(*apperrors.AppError)
-			srv.logger.Error(appErr)
-			srv.respond(w, appErr.Message, http.StatusInternalServerError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
 			return
 		}
 
-		srv.logger.Infof("createLectureHandler has been processed. Response: %+v", createLectResp)
+		srv.metrics.LectureCreated()
+		logger.Infof("createLectureHandler has been processed. Response: %+v", createLectResp)
 		srv.respond(w, createLectResp, http.StatusCreated)
 	}
 }
 
-func (srv *server) decode(r *http.Request, v interface{}) error {
-	return json.NewDecoder(r.Body).Decode(v)
+func (srv *server) changeLectureStatusHandler() http.HandlerFunc {
+	srv.logger.Info("changeLectureStatusHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		changeStatusRequest := &requests.ChangeLectureStatusRequest{}
+		err := srv.decode(w, r, changeStatusRequest)
+		if err != nil {
+			appErr := apperrors.ChangeLectureStatusHandlerErr.AppendMessage(err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
+			return
+		}
+
+		lectureId, ok := mux.Vars(r)["lecture_id"]
+		if !ok {
+			appErr := apperrors.ChangeLectureStatusHandlerErr.AppendMessage("Vars lecture_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("changeLectureStatusHandler has been invoked. Request: %+v, lecture_id: %v", changeStatusRequest, lectureId)
+
+		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
+		changeStatusResp, err := lectureService.ChangeLectureStatus(r.Context(), lectureId, changeStatusRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("changeLectureStatusHandler has been processed. Response: %+v", changeStatusResp)
+		srv.respond(w, changeStatusResp, http.StatusOK)
+	}
+}
+
+func (srv *server) getUserHandler() http.HandlerFunc {
+	srv.logger.Info("getUserHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		userId, ok := mux.Vars(r)["user_id"]
+		if !ok {
+			appErr := apperrors.GetUserHandlerErr.AppendMessage("Vars user_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		if appErr := srv.requireSelfOrAdmin(r.Context(), userId); appErr != nil {
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getUserHandler has been invoked. user_id: %v", userId)
+
+		userService := services.NewUserService(srv.repoUsers, srv.logger)
+		userResp, err := userService.GetUser(r.Context(), userId)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getUserHandler has been processed. Response: %+v", userResp)
+		srv.respondVersioned(w, r, userResp, userResp.Version, http.StatusOK)
+	}
+}
+
+func (srv *server) updateUserHandler() http.HandlerFunc {
+	srv.logger.Info("updateUserHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		if appErr := srv.requireSelfOrAdmin(r.Context(), mux.Vars(r)["user_id"]); appErr != nil {
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		precondition, ok := ifMatch(r)
+		if !ok {
+			appErr := apperrors.PreconditionRequiredErr.AppendMessage("If-Match")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		updateUserRequest := &requests.UpdateUserRequest{}
+		err := srv.decode(w, r, updateUserRequest)
+		if err != nil {
+			appErr := apperrors.UpdateUserHandlerErr.AppendMessage(err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
+			return
+		}
+
+		userId, ok := mux.Vars(r)["user_id"]
+		if !ok {
+			appErr := apperrors.UpdateUserHandlerErr.AppendMessage("Vars user_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("updateUserHandler has been invoked. Request: %+v, user_id: %v", updateUserRequest, userId)
+		if updateUserRequest.Locale != nil && !srv.knownLocale(*updateUserRequest.Locale) {
+			appErr := apperrors.UpdateUserHandlerErr.AppendMessage("unknown locale:", *updateUserRequest.Locale)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		if updateUserRequest.Role != nil {
+			if appErr := srv.requireAdmin(r.Context()); appErr != nil {
+				logger.Error(appErr)
+				srv.respond(w, appErr.Message, appErr.HTTPCode)
+				return
+			}
+		}
+
+		userService := services.NewUserService(srv.repoUsers, srv.logger)
+		userResp, err := userService.UpdateUser(r.Context(), userId, precondition, updateUserRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("updateUserHandler has been processed. Response: %+v", userResp)
+		srv.respondVersioned(w, r, userResp, userResp.Version, http.StatusOK)
+	}
+}
+
+func (srv *server) deleteUserHandler() http.HandlerFunc {
+	srv.logger.Info("deleteUserHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		precondition, ok := ifMatch(r)
+		if !ok {
+			appErr := apperrors.PreconditionRequiredErr.AppendMessage("If-Match")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		userId, ok := mux.Vars(r)["user_id"]
+		if !ok {
+			appErr := apperrors.DeleteUserHandlerErr.AppendMessage("Vars user_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("deleteUserHandler has been invoked. user_id: %v", userId)
+
+		userService := services.NewUserService(srv.repoUsers, srv.logger)
+		err := userService.DeleteUser(r.Context(), userId, precondition)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("deleteUserHandler has been processed. user_id: %v", userId)
+		srv.respond(w, nil, http.StatusNoContent)
+	}
+}
+
+func (srv *server) getLectureHandler() http.HandlerFunc {
+	srv.logger.Info("getLectureHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		lectureId, ok := mux.Vars(r)["lecture_id"]
+		if !ok {
+			appErr := apperrors.GetLectureHandlerErr.AppendMessage("Vars lecture_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("getLectureHandler has been invoked. lecture_id: %v", lectureId)
+
+		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
+		lectureResp, err := lectureService.GetLecture(r.Context(), lectureId)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getLectureHandler has been processed. Response: %+v", lectureResp)
+		srv.respondVersioned(w, r, lectureResp, lectureResp.Version, http.StatusOK)
+	}
+}
+
+func (srv *server) updateLectureHandler() http.HandlerFunc {
+	srv.logger.Info("updateLectureHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		precondition, ok := ifMatch(r)
+		if !ok {
+			appErr := apperrors.PreconditionRequiredErr.AppendMessage("If-Match")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		updateLectureRequest := &requests.UpdateLectureRequest{}
+		err := srv.decode(w, r, updateLectureRequest)
+		if err != nil {
+			appErr := apperrors.UpdateLectureHandlerErr.AppendMessage(err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
+			return
+		}
+
+		lectureId, ok := mux.Vars(r)["lecture_id"]
+		if !ok {
+			appErr := apperrors.UpdateLectureHandlerErr.AppendMessage("Vars lecture_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("updateLectureHandler has been invoked. Request: %+v, lecture_id: %v", updateLectureRequest, lectureId)
+
+		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
+		lectureResp, err := lectureService.UpdateLecture(r.Context(), lectureId, precondition, updateLectureRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("updateLectureHandler has been processed. Response: %+v", lectureResp)
+		srv.respondVersioned(w, r, lectureResp, lectureResp.Version, http.StatusOK)
+	}
+}
+
+func (srv *server) deleteLectureHandler() http.HandlerFunc {
+	srv.logger.Info("deleteLectureHandler has been initiated.")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		precondition, ok := ifMatch(r)
+		if !ok {
+			appErr := apperrors.PreconditionRequiredErr.AppendMessage("If-Match")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		lectureId, ok := mux.Vars(r)["lecture_id"]
+		if !ok {
+			appErr := apperrors.DeleteLectureHandlerErr.AppendMessage("Vars lecture_id")
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("deleteLectureHandler has been invoked. lecture_id: %v", lectureId)
+
+		lectureService := services.NewLectureService(srv.repoLects, srv.logger)
+		err := lectureService.DeleteLecture(r.Context(), lectureId, precondition)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("deleteLectureHandler has been processed. lecture_id: %v", lectureId)
+		srv.respond(w, nil, http.StatusNoContent)
+	}
+}
+
+func (srv *server) getAuditEventsHandler() http.HandlerFunc {
+	srv.logger.Info("getAuditEventsHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		query := r.URL.Query()
+		getAuditEventsRequest := &requests.GetAuditEventsRequest{
+			Entity:       query.Get("entity"),
+			EntityId:     query.Get("entity_id"),
+			Actor:        query.Get("actor"),
+			From:         query.Get("from"),
+			To:           query.Get("to"),
+			Page:         query.Get("page"),
+			PerPage:      query.Get("per_page"),
+			Cursor:       query.Get("cursor"),
+			IncludeTotal: query.Get("include_total"),
+		}
+
+		logger.Infof("getAuditEventsHandler has been invoked. Request: %+v", getAuditEventsRequest)
+		auditService := services.NewAuditService(srv.repoAudit, srv.logger)
+		getAuditEventsResp, err := auditService.GetAuditEvents(r.Context(), getAuditEventsRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getAuditEventsHandler has been processed. Events: %v", len(getAuditEventsResp.Events))
+		srv.respond(w, getAuditEventsResp, http.StatusOK)
+	}
+}
+
+func (srv *server) createWebhookHandler() http.HandlerFunc {
+	srv.logger.Info("createWebhookHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		createWebhookRequest := &requests.CreateWebhookRequest{}
+		err := srv.decode(w, r, createWebhookRequest)
+		if err != nil {
+			appErr := apperrors.CreateWebhookHandlerErr.AppendMessage(err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
+			return
+		}
+
+		logger.Infof("createWebhookHandler has been invoked. Request: %+v", createWebhookRequest)
+		webhookService := services.NewWebhookService(srv.repoWebhooks, srv.webhookAddresses, srv.logger)
+		webhookResp, err := webhookService.CreateWebhook(r.Context(), createWebhookRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("createWebhookHandler has been processed. Response: %+v", webhookResp)
+		srv.respond(w, webhookResp, http.StatusCreated)
+	}
+}
+
+func (srv *server) getWebhooksHandler() http.HandlerFunc {
+	srv.logger.Info("getWebhooksHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		logger.Info("getWebhooksHandler has been invoked.")
+		webhookService := services.NewWebhookService(srv.repoWebhooks, srv.webhookAddresses, srv.logger)
+		getWebhooksResp, err := webhookService.GetWebhooks(r.Context())
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getWebhooksHandler has been processed. Webhooks: %v", len(getWebhooksResp.Webhooks))
+		srv.respond(w, getWebhooksResp, http.StatusOK)
+	}
+}
+
+func (srv *server) deleteWebhookHandler() http.HandlerFunc {
+	srv.logger.Info("deleteWebhookHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		webhookId := mux.Vars(r)["webhook_id"]
+		logger.Infof("deleteWebhookHandler has been invoked. webhook_id: %v", webhookId)
+		webhookService := services.NewWebhookService(srv.repoWebhooks, srv.webhookAddresses, srv.logger)
+		if err := webhookService.DeleteWebhook(r.Context(), webhookId); err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("deleteWebhookHandler has been processed. webhook_id: %v", webhookId)
+		srv.respond(w, nil, http.StatusNoContent)
+	}
+}
+
+func (srv *server) getWebhookDeliveriesHandler() http.HandlerFunc {
+	srv.logger.Info("getWebhookDeliveriesHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		webhookId := mux.Vars(r)["webhook_id"]
+		query := r.URL.Query()
+		getWebhookDeliveriesRequest := &requests.GetWebhookDeliveriesRequest{
+			Status:       query.Get("status"),
+			Page:         query.Get("page"),
+			PerPage:      query.Get("per_page"),
+			Cursor:       query.Get("cursor"),
+			IncludeTotal: query.Get("include_total"),
+		}
+
+		logger.Infof("getWebhookDeliveriesHandler has been invoked. webhook_id: %v Request: %+v", webhookId, getWebhookDeliveriesRequest)
+		webhookService := services.NewWebhookService(srv.repoWebhooks, srv.webhookAddresses, srv.logger)
+		getWebhookDeliveriesResp, err := webhookService.GetWebhookDeliveries(r.Context(), webhookId, getWebhookDeliveriesRequest)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("getWebhookDeliveriesHandler has been processed. Deliveries: %v", len(getWebhookDeliveriesResp.Deliveries))
+		srv.respond(w, getWebhookDeliveriesResp, http.StatusOK)
+	}
+}
+
+func (srv *server) retryWebhookDeliveryHandler() http.HandlerFunc {
+	srv.logger.Info("retryWebhookDeliveryHandler has been initiated.")
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		deliveryId := mux.Vars(r)["delivery_id"]
+		logger.Infof("retryWebhookDeliveryHandler has been invoked. delivery_id: %v", deliveryId)
+		webhookService := services.NewWebhookService(srv.repoWebhooks, srv.webhookAddresses, srv.logger)
+		deliveryResp, err := webhookService.RetryWebhookDelivery(r.Context(), deliveryId)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		logger.Infof("retryWebhookDeliveryHandler has been processed. delivery_id: %v", deliveryId)
+		srv.respond(w, deliveryResp, http.StatusOK)
+	}
+}
+
+const defaultMaxBodyBytes = 1 << 20
+
+// decode reads at most maxBodyBytes of JSON into v and rejects fields v
+// doesn't have, so typos in a request don't pass silently. Anything after the
+// first JSON value is rejected too.
+func (srv *server) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	_, span := tracer.Start(r.Context(), "decode request body")
+	defer span.End()
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, srv.bodyLimit()))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil {
+		if err = decoder.Decode(&json.RawMessage{}); err == nil {
+			err = errors.New("request body must hold a single JSON value")
+		} else if err == io.EOF {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid request body")
+	}
+
+	return err
+}
+
+func (srv *server) bodyLimit() int64 {
+	if srv.maxBodyBytes <= 0 {
+		return defaultMaxBodyBytes
+	}
+
+	return srv.maxBodyBytes
+}
+
+// decodeErrStatus tells a body over the size limit apart from a malformed one.
+func decodeErrStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
 }
 
 func (srv *server) respond(w http.ResponseWriter, data interface{}, status int) {
-	w.WriteHeader(status)
 	if data == nil {
+		w.WriteHeader(status)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
 	err := json.NewEncoder(w).Encode(data)
 	if err != nil {
 		srv.logger.Error(err)
 	}
 }
+
+// knownLocale tells whether a user's emails can be written in locale. An empty
+// one means the default locale.
+func (srv *server) knownLocale(locale string) bool {
+	return locale == "" || srv.templates != nil && srv.templates.HasLocale(locale)
+}
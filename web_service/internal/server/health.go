@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"web_service/internal/domain/responses"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+	readinessCheckTimeout   = 2 * time.Second
+)
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (srv *server) addReadinessCheck(name string, check func(ctx context.Context) error) {
+	srv.readinessChecks = append(srv.readinessChecks, readinessCheck{name: name, check: check})
+}
+
+// passesOnce remembers the first success of check, for things that stay true
+// once they hold, such as the schema being migrated, so probes don't keep
+// querying for them.
+func passesOnce(check func(ctx context.Context) error) func(ctx context.Context) error {
+	var passed atomic.Bool
+	return func(ctx context.Context) error {
+		if passed.Load() {
+			return nil
+		}
+
+		if err := check(ctx); err != nil {
+			return err
+		}
+
+		passed.Store(true)
+		return nil
+	}
+}
+
+// markShuttingDown makes /readyz fail so the orchestrator stops routing new
+// traffic while in-flight requests drain.
+func (srv *server) markShuttingDown() {
+	srv.shuttingDown.Store(true)
+}
+
+func (srv *server) livenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		srv.respond(w, &responses.HealthResponse{Status: healthStatusOK}, http.StatusOK)
+	}
+}
+
+func (srv *server) readinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		healthResp := &responses.HealthResponse{Status: healthStatusOK, Components: map[string]*responses.ComponentHealth{}}
+		if srv.shuttingDown.Load() {
+			healthResp.Status = healthStatusUnavailable
+			healthResp.Components["server"] = &responses.ComponentHealth{Status: healthStatusUnavailable, Error: "shutting down"}
+		}
+
+		for _, readiness := range srv.readinessChecks {
+			componentHealth := &responses.ComponentHealth{Status: healthStatusOK}
+			if err := readiness.check(ctx); err != nil {
+				componentHealth.Status = healthStatusUnavailable
+				componentHealth.Error = err.Error()
+				healthResp.Status = healthStatusUnavailable
+			}
+
+			healthResp.Components[readiness.name] = componentHealth
+		}
+
+		if healthResp.Status != healthStatusOK {
+			srv.logger.Errorf("readiness check failed: %+v", healthResp.Components)
+			srv.respond(w, healthResp, http.StatusServiceUnavailable)
+			return
+		}
+
+		srv.respond(w, healthResp, http.StatusOK)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	defaultIdempotencyTTL    = 24 * time.Hour
+	defaultIdempotencyLock   = time.Minute
+	anonymousIdempotency     = "anonymous"
+	idempotencyStoreTimeout  = 5 * time.Second
+)
+
+// idempotent lets clients retry a request safely by sending the same
+// Idempotency-Key: the first response is stored per key and user and replayed
+// to retries until it expires. Server errors aren't stored, so a retry after
+// one runs again. Keys are scoped to the authenticated user. Anonymous
+// requests, such as sign-ups, share one scope; a retry only replays a
+// response when its body is byte for byte the one that produced it.
+func (srv *server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || srv.repoIdempotency == nil {
+			h(w, r)
+			return
+		}
+
+		scope := anonymousIdempotency
+		if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+			scope = userID.String()
+		}
+
+		logger := logging.FromContext(r.Context(), srv.logger)
+		if len(key) > maxIdempotencyKeyLength {
+			appErr := apperrors.IdempotencyKeyInvalidErr.AppendMessage("longer than", maxIdempotencyKeyLength)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, srv.bodyLimit()))
+		if err != nil {
+			appErr := apperrors.IdempotencyKeyInvalidErr.AppendMessage("read body", err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, decodeErrStatus(err))
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		ttl, lock := srv.idempotencyTTL, srv.idempotencyLock
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTTL
+		}
+
+		if lock <= 0 {
+			lock = defaultIdempotencyLock
+		}
+
+		now := time.Now()
+		record := &models.IdempotencyKey{
+			Key:         key,
+			Scope:       scope,
+			RequestHash: requestHash(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+			LockedUntil: now.Add(lock),
+		}
+		existing, err := srv.repoIdempotency.Reserve(r.Context(), record)
+		if err != nil {
+			appErr := err.(*apperrors.AppError)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		if existing != nil {
+			srv.replay(w, r, existing, record.RequestHash)
+			return
+		}
+
+		// Unless the response gets stored, whether the handler failed,
+		// panicked or storing it did, the key is freed for a retry to run.
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+
+			ctx, cancel := idempotencyStoreContext(logger)
+			defer cancel()
+			if err := srv.repoIdempotency.Release(ctx, record); err != nil {
+				logger.Errorf("Idempotency-Key %s stays locked until %v: %v", record.Key, record.LockedUntil, err)
+			}
+		}()
+
+		recorder := newBodyRecorder(w)
+		h(recorder, r)
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		record.Status = recorder.status
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+		ctx, cancel := idempotencyStoreContext(logger)
+		defer cancel()
+		if err := srv.repoIdempotency.Complete(ctx, record); err != nil {
+			logger.Errorf("Response to Idempotency-Key %s not stored, a retry will run again: %v", record.Key, err)
+			return
+		}
+
+		completed = true
+	}
+}
+
+// idempotencyStoreContext outlives the request, which may have been cancelled
+// by the time its outcome has to be recorded.
+func idempotencyStoreContext(logger *zap.SugaredLogger) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(logging.WithLogger(context.Background(), logger), idempotencyStoreTimeout)
+}
+
+func (srv *server) replay(w http.ResponseWriter, r *http.Request, existing *models.IdempotencyKey, requestHash string) {
+	logger := logging.FromContext(r.Context(), srv.logger)
+	if existing.RequestHash != requestHash {
+		appErr := apperrors.IdempotencyKeyReusedErr.AppendMessage(existing.Key)
+		logger.Error(appErr)
+		srv.respond(w, appErr.Message, appErr.HTTPCode)
+		return
+	}
+
+	if !existing.IsCompleted() {
+		appErr := apperrors.IdempotencyKeyInProgressErr.AppendMessage(existing.Key)
+		logger.Warn(appErr)
+		srv.respond(w, appErr.Message, appErr.HTTPCode)
+		return
+	}
+
+	logger.Infof("Replaying the stored response for Idempotency-Key %s", existing.Key)
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(existing.Status)
+	if _, err := w.Write(existing.Body); err != nil {
+		logger.Error(err)
+	}
+}
+
+// requestHash fingerprints what the key was first used for. The path is
+// taken without the version prefix, so a retry through the deprecated alias
+// of a route still counts as the same request.
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + strings.TrimPrefix(r.URL.Path, apiV1Prefix) + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// purgeIdempotencyKeys deletes expired keys every interval until ctx is done.
+func (srv *server) purgeIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := srv.repoIdempotency.DeleteExpired(ctx, time.Now())
+			if err == nil && deleted > 0 {
+				srv.logger.Infof("Purged %d expired idempotency keys", deleted)
+			}
+		}
+	}
+}
@@ -3,9 +3,21 @@ package server
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+	"web_service/internal/ratelimit"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const maxRequestIDLength = 128
+
 func (srv *server) contextExpire(h http.HandlerFunc) http.HandlerFunc {
 	srv.logger.Info("contextExpire")
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -16,3 +28,204 @@ func (srv *server) contextExpire(h http.HandlerFunc) http.HandlerFunc {
 		h(w, r)
 	}
 }
+
+// logRequests assigns the request ID, puts a logger tagged with it into the
+// context and writes one access log line once the response is done.
+func (srv *server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(logging.RequestIDHeader, requestID)
+		logger := srv.logger.With("request_id", requestID)
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+			logger = logger.With("trace_id", spanContext.TraceID().String())
+		}
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		ctx = logging.WithLogger(ctx, logger)
+		recorder := newStatusRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		logger.Infow("access",
+			"method", r.Method,
+			"route", routeTemplate(r),
+			"status", recorder.status,
+			"latency", time.Since(start),
+			"user_id", recorder.userID,
+		)
+	})
+}
+
+// identifyUser puts the caller's ID from the gateway header into the request
+// context. The header is only believed from the trusted proxies; from anyone
+// else it is dropped and the request goes on as anonymous. Anonymous requests
+// pass through untouched.
+func (srv *server) identifyUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(srv.userIDHeader)
+		if header != "" && !srv.trustedProxies.Contains(r.RemoteAddr) {
+			logging.FromContext(r.Context(), srv.logger).Warnf("Ignoring %s from untrusted address %s", srv.userIDHeader, r.RemoteAddr)
+			r.Header.Del(srv.userIDHeader)
+			header = ""
+		}
+
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := uuid.Parse(header)
+		logger := logging.FromContext(r.Context(), srv.logger)
+		if err != nil {
+			srv.metrics.AuthenticationFailed()
+			appErr := apperrors.UnauthenticatedErr.AppendMessage(srv.userIDHeader, err)
+			logger.Error(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		if recorder, ok := w.(*statusRecorder); ok {
+			recorder.userID = userID.String()
+		}
+
+		ctx := auth.WithUserID(r.Context(), userID)
+		ctx = logging.WithLogger(ctx, logger.With("user_id", userID.String()))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// adminOnly lets through only callers whose user has the admin role.
+func (srv *server) adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := srv.requireAdmin(r.Context()); err != nil {
+			logging.FromContext(r.Context(), srv.logger).Error(err)
+			srv.respond(w, err.Message, err.HTTPCode)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// requireSelfOrAdmin lets callers act on their own user only, and admins on
+// anyone's.
+func (srv *server) requireSelfOrAdmin(ctx context.Context, userId string) *apperrors.AppError {
+	callerID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		srv.metrics.AuthenticationFailed()
+		return apperrors.UnauthenticatedErr.AppendMessage("missing", srv.userIDHeader)
+	}
+
+	if target, err := uuid.Parse(userId); err == nil && target == callerID {
+		return nil
+	}
+
+	return srv.requireAdmin(ctx)
+}
+
+// requireAdmin fails with 401 for anonymous or unknown callers and with 403
+// for callers who aren't admins. The role is read from the caller's user, not
+// from anything the request says.
+func (srv *server) requireAdmin(ctx context.Context) *apperrors.AppError {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		srv.metrics.AuthenticationFailed()
+		return apperrors.UnauthenticatedErr.AppendMessage("missing", srv.userIDHeader)
+	}
+
+	user, err := srv.repoUsers.GetUserByID(ctx, &userID)
+	if err != nil {
+		appErr := err.(*apperrors.AppError)
+		if appErr.HTTPCode != http.StatusNotFound {
+			return appErr
+		}
+
+		srv.metrics.AuthenticationFailed()
+		return apperrors.UnauthenticatedErr.AppendMessage("unknown user", userID)
+	}
+
+	if user.Role != models.RoleAdmin {
+		return apperrors.ForbiddenErr.AppendMessage("admin role required")
+	}
+
+	return nil
+}
+
+const (
+	rateLimitDefault    = "default"
+	rateLimitSignup     = "signup"
+	rateLimitEnrollment = "enrollment"
+)
+
+// rateLimit rejects callers that ran out of tokens in the group's bucket with
+// 429. If the store fails the request is let through: an outage of a shared
+// store shouldn't take the API down with it.
+func (srv *server) rateLimit(group string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if srv.rateLimiter == nil {
+			h(w, r)
+			return
+		}
+
+		logger := logging.FromContext(r.Context(), srv.logger)
+		result, err := srv.rateLimiter.Allow(r, group)
+		if err != nil {
+			logger.Error(apperrors.RateLimitStoreErr.AppendMessage(group, err))
+			h(w, r)
+			return
+		}
+
+		result.SetHeaders(w.Header())
+		if !result.Allowed {
+			srv.metrics.RateLimited(group)
+			appErr := apperrors.RateLimitedErr.AppendMessage(group)
+			logger.Warn(appErr)
+			srv.respond(w, appErr.Message, appErr.HTTPCode)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// newRateLimiter trusts X-Forwarded-For from the same proxies the user ID
+// header is accepted from, so both agree on who the client is.
+func newRateLimiter(cfg *config.RateLimitConfig, trustedProxies auth.Networks) *ratelimit.RateLimiter {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	clientIPs := ratelimit.NewClientIPResolver(trustedProxies)
+
+	rules := map[string]ratelimit.Rule{
+		rateLimitDefault:    {PerMinute: cfg.DefaultPerMinute, Burst: cfg.DefaultBurst},
+		rateLimitSignup:     {PerMinute: cfg.SignupPerMinute, Burst: cfg.SignupBurst},
+		rateLimitEnrollment: {PerMinute: cfg.EnrollmentPerMinute, Burst: cfg.EnrollmentBurst},
+	}
+
+	return ratelimit.New(ratelimit.NewMemoryStore(), rules, clientIPs)
+}
+
+const apiV1Prefix = "/api/v1"
+
+// The unversioned routes are aliases of /api/v1 kept for existing clients
+// until legacyRoutesSunset.
+var (
+	legacyRoutesDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacyRoutesSunset       = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// deprecated marks responses of a legacy route with the Deprecation (RFC 9745)
+// and Sunset (RFC 8594) headers and links the /api/v1 route replacing it.
+func (srv *server) deprecated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(legacyRoutesDeprecatedAt.Unix(), 10))
+		w.Header().Set("Sunset", legacyRoutesSunset.Format(http.TimeFormat))
+		w.Header().Set("Link", "<"+apiV1Prefix+r.URL.EscapedPath()+`>; rel="successor-version"`)
+		h(w, r)
+	}
+}
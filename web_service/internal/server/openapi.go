@@ -0,0 +1,433 @@
+package server
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"web_service/internal/domain/requests"
+	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
+
+	"github.com/gorilla/mux"
+)
+
+const openAPIVersion = "3.0.3"
+
+// apiOperation documents one route. Request and response bodies and query
+// parameters are described by the same domain types the handlers use, so the
+// spec follows the code instead of drifting from it.
+type apiOperation struct {
+	method      string
+	path        string
+	summary     string
+	query       interface{}
+	request     interface{}
+	response    interface{}
+	contentType string
+	status      int
+	errors      []int
+	auth        bool
+	admin       bool
+	idempotent  bool
+	versioned   bool
+}
+
+// logLevelBody is the JSON zap's level handler reads and writes.
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+var apiErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError}
+
+var apiOperations = []apiOperation{
+	{method: http.MethodGet, path: "/healthz", summary: "Liveness probe", response: responses.HealthResponse{}, status: http.StatusOK},
+	{method: http.MethodGet, path: "/readyz", summary: "Readiness probe, checking the database and migrations", response: responses.HealthResponse{}, status: http.StatusOK, errors: []int{http.StatusServiceUnavailable}},
+	{method: http.MethodGet, path: "/metrics", summary: "Prometheus metrics", contentType: "text/plain", status: http.StatusOK},
+	{method: http.MethodGet, path: "/openapi.json", summary: "This document", contentType: "application/json", status: http.StatusOK},
+	{method: http.MethodGet, path: "/docs", summary: "Swagger UI", contentType: "text/html", status: http.StatusOK},
+	{method: http.MethodGet, path: "/docs/{asset}", summary: "Swagger UI stylesheet and script", contentType: "text/plain", status: http.StatusOK, errors: []int{http.StatusNotFound}},
+	{method: http.MethodGet, path: "/admin/log-level", summary: "Current log level", response: logLevelBody{}, status: http.StatusOK, admin: true},
+	{method: http.MethodPut, path: "/admin/log-level", summary: "Change the log level", request: logLevelBody{}, response: logLevelBody{}, status: http.StatusOK, errors: []int{http.StatusBadRequest}, admin: true},
+	{method: http.MethodGet, path: "/admin/audit", summary: "Audit log of changes to lectures, users, enrolments and webhooks, newest first", query: requests.GetAuditEventsRequest{}, response: responses.GetAuditEventsPageResponse{}, status: http.StatusOK, errors: []int{http.StatusBadRequest, http.StatusInternalServerError}, admin: true},
+	{method: http.MethodPost, path: "/admin/webhooks", summary: "Subscribe a URL to domain events", request: requests.CreateWebhookRequest{}, response: responses.WebhookResp{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusInternalServerError}, admin: true},
+	{method: http.MethodGet, path: "/admin/webhooks", summary: "List webhook subscriptions", response: responses.GetWebhooksResponse{}, status: http.StatusOK, errors: []int{http.StatusInternalServerError}, admin: true},
+	{method: http.MethodDelete, path: "/admin/webhooks/{webhook_id}", summary: "Delete a webhook subscription and its deliveries", status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError}, admin: true},
+	{method: http.MethodGet, path: "/admin/webhooks/{webhook_id}/deliveries", summary: "A subscription's deliveries with their attempt log, newest first", query: requests.GetWebhookDeliveriesRequest{}, response: responses.GetWebhookDeliveriesPageResponse{}, status: http.StatusOK, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError}, admin: true},
+	{method: http.MethodPost, path: "/admin/webhook-deliveries/{delivery_id}/retry", summary: "Requeue a dead webhook delivery", response: responses.WebhookDeliveryResp{}, status: http.StatusOK, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError}, admin: true},
+	{method: http.MethodPost, path: apiV1Prefix + "/users", summary: "Create a user; only admins may give a role other than student", request: requests.CreateUserRequest{}, response: responses.CreateUserResponse{}, status: http.StatusCreated, errors: []int{http.StatusConflict, http.StatusForbidden, http.StatusRequestEntityTooLarge}, idempotent: true},
+	{method: http.MethodGet, path: apiV1Prefix + "/users", summary: "List users, without their emails; admins only", query: requests.GetUsersRequest{}, response: responses.GetUsersPageResponse{}, status: http.StatusOK, admin: true},
+	{method: http.MethodGet, path: apiV1Prefix + "/users/{user_id}", summary: "Get a user; only the user and admins may read it", response: responses.UserResp{}, status: http.StatusOK, errors: []int{http.StatusForbidden, http.StatusNotFound}, auth: true, versioned: true},
+	{method: http.MethodPatch, path: apiV1Prefix + "/users/{user_id}", summary: "Update the caller's own user; admins may update anyone and change roles", request: requests.UpdateUserRequest{}, response: responses.UserResp{}, status: http.StatusOK, errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge}, auth: true, versioned: true},
+	{method: http.MethodDelete, path: apiV1Prefix + "/users/{user_id}", summary: "Delete a user; admins only", status: http.StatusNoContent, errors: []int{http.StatusNotFound}, admin: true, versioned: true},
+	{method: http.MethodGet, path: apiV1Prefix + "/users/{user_id}/lectures", summary: "A user's lectures; only the user and admins may read them", query: requests.GetUserScheduleRequest{}, response: responses.GetUserScheduleResponse{}, status: http.StatusOK, errors: []int{http.StatusForbidden, http.StatusNotFound}, auth: true},
+	{method: http.MethodGet, path: apiV1Prefix + "/me/schedule", summary: "The caller's lectures", query: requests.GetUserScheduleRequest{}, response: responses.GetUserScheduleResponse{}, status: http.StatusOK, errors: []int{http.StatusNotFound}, auth: true},
+	{method: http.MethodPost, path: apiV1Prefix + "/lectures", summary: "Create a lecture; admins only", request: requests.CreateLectureRequest{}, response: responses.CreateLectureResponse{}, status: http.StatusCreated, errors: []int{http.StatusConflict, http.StatusRequestEntityTooLarge}, admin: true, idempotent: true},
+	{method: http.MethodGet, path: apiV1Prefix + "/lectures", summary: "List lectures", query: requests.GetLecturesPPRequest{}, response: responses.GetLecturesPageResponse{}, status: http.StatusOK},
+	{method: http.MethodGet, path: apiV1Prefix + "/lectures/{lecture_id}", summary: "Get a lecture", response: responses.LectureResp{}, status: http.StatusOK, errors: []int{http.StatusNotFound}, versioned: true},
+	{method: http.MethodPatch, path: apiV1Prefix + "/lectures/{lecture_id}", summary: "Update a lecture; admins only", request: requests.UpdateLectureRequest{}, response: responses.LectureResp{}, status: http.StatusOK, errors: []int{http.StatusNotFound, http.StatusRequestEntityTooLarge}, admin: true, versioned: true},
+	{method: http.MethodDelete, path: apiV1Prefix + "/lectures/{lecture_id}", summary: "Delete a lecture without enrolled students; admins only", status: http.StatusNoContent, errors: []int{http.StatusNotFound, http.StatusConflict}, admin: true, versioned: true},
+	{method: http.MethodPut, path: apiV1Prefix + "/lectures/{lecture_id}/add-student", summary: "Enroll the caller; admins may enroll anyone", request: requests.AddStudentToLectureReq{}, response: responses.AddUserToLecture{}, status: http.StatusOK, errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusRequestEntityTooLarge}, auth: true, idempotent: true},
+	{method: http.MethodDelete, path: apiV1Prefix + "/lectures/{lecture_id}/remove-student", summary: "Unenroll the caller; admins may unenroll anyone", request: requests.DeleteStudentFromLectureRequest{}, response: responses.DeleteStudentFromLectureResponse{}, status: http.StatusOK, errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge}, auth: true},
+	{method: http.MethodPut, path: apiV1Prefix + "/lectures/{lecture_id}/status", summary: "Change a lecture's status; admins only", request: requests.ChangeLectureStatusRequest{}, response: responses.ChangeLectureStatusResponse{}, status: http.StatusOK, errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusRequestEntityTooLarge}, admin: true, idempotent: true},
+	{method: http.MethodGet, path: apiV1Prefix + "/lectures/{lecture_id}/students", summary: "A lecture's students", query: requests.GetLectureStudentsRequest{}, response: responses.GetLectureStudentsPageResponse{}, status: http.StatusOK, errors: []int{http.StatusNotFound}},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPIDocument builds the OpenAPI 3 document for apiOperations. API routes
+// are rate limited and may reject a malformed caller ID, so they share those
+// error responses, and each is also listed at its deprecated unversioned path.
+func (srv *server) openAPIDocument() map[string]interface{} {
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{"type": "string", "description": "Human readable error message"},
+	}
+	paths := map[string]interface{}{}
+	for _, operation := range apiOperations {
+		parameters := []interface{}{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(operation.path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string", "format": "uuid"},
+			})
+		}
+
+		if operation.query != nil {
+			for _, field := range jsonFields(reflect.TypeOf(operation.query)) {
+				parameters = append(parameters, map[string]interface{}{
+					"name": field.name, "in": "query",
+					"schema": schemaFor(field.typ, schemas, false),
+				})
+			}
+		}
+
+		if operation.idempotent {
+			parameters = append(parameters, map[string]interface{}{
+				"name": idempotencyKeyHeader, "in": "header",
+				"description": "Retries with the same key replay the first response instead of repeating the request",
+				"schema":      map[string]interface{}{"type": "string", "maxLength": maxIdempotencyKeyLength},
+			})
+		}
+
+		errorCodes := operation.errors
+		if operation.versioned {
+			errorCodes, parameters = versionedOperation(operation, errorCodes, parameters)
+		}
+
+		opResponses := map[string]interface{}{}
+		success := map[string]interface{}{"description": http.StatusText(operation.status)}
+		if operation.versioned && operation.method != http.MethodDelete {
+			success["headers"] = map[string]interface{}{
+				"ETag": map[string]interface{}{"description": "Current version of the resource", "schema": map[string]interface{}{"type": "string"}},
+			}
+		}
+
+		if operation.response != nil {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(operation.response), schemas, true)},
+			}
+		} else if operation.contentType != "" {
+			success["content"] = map[string]interface{}{
+				operation.contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		}
+
+		opResponses[fmt.Sprint(operation.status)] = success
+		if operation.idempotent {
+			errorCodes = append(append([]int{}, errorCodes...), http.StatusConflict, http.StatusUnprocessableEntity)
+		}
+
+		if operation.admin {
+			errorCodes = append(append([]int{}, errorCodes...), http.StatusUnauthorized, http.StatusForbidden)
+		}
+
+		if isAPIRoute(operation.path) {
+			errorCodes = append(append([]int{}, apiErrors...), errorCodes...)
+		}
+
+		for _, code := range errorCodes {
+			if code == http.StatusNotModified {
+				opResponses[fmt.Sprint(code)] = map[string]interface{}{"description": http.StatusText(code)}
+				continue
+			}
+
+			opResponses[fmt.Sprint(code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+				},
+			}
+		}
+
+		document := map[string]interface{}{
+			"summary":     operation.summary,
+			"operationId": operationID(operation),
+			"responses":   opResponses,
+		}
+		if len(parameters) > 0 {
+			document["parameters"] = parameters
+		}
+
+		if operation.request != nil {
+			document["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(operation.request), schemas, false)},
+				},
+			}
+		}
+
+		if operation.auth || operation.admin {
+			document["security"] = []interface{}{map[string]interface{}{"userID": []string{}}}
+		}
+
+		addOperation(paths, operation.path, operation.method, document)
+		if legacyPath := strings.TrimPrefix(operation.path, apiV1Prefix); legacyPath != operation.path {
+			legacy := map[string]interface{}{"deprecated": true}
+			for key, value := range document {
+				legacy[key] = value
+			}
+
+			legacy["operationId"] = operationID(apiOperation{method: operation.method, path: legacyPath}) + "Legacy"
+			addOperation(paths, legacyPath, operation.method, legacy)
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info":    map[string]interface{}{"title": "serviceschool", "version": "1.0.0"},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"userID": map[string]interface{}{
+					"type": "apiKey", "in": "header", "name": srv.userIDHeader,
+					"description": "ID of the authenticated user, set by the gateway",
+				},
+			},
+		},
+	}
+}
+
+// versionedOperation adds the conditional request headers of a resource
+// carrying an ETag: reads may be revalidated with If-None-Match, and writes
+// must name the version they replace in If-Match.
+func versionedOperation(operation apiOperation, errorCodes []int, parameters []interface{}) ([]int, []interface{}) {
+	errorCodes = append([]int{}, errorCodes...)
+	tag := map[string]interface{}{"type": "string"}
+	if operation.method == http.MethodGet {
+		parameters = append(parameters, map[string]interface{}{
+			"name": "If-None-Match", "in": "header", "schema": tag,
+			"description": "ETag of a cached copy; answered with 304 while it is current",
+		})
+		return append(errorCodes, http.StatusNotModified), parameters
+	}
+
+	parameters = append(parameters, map[string]interface{}{
+		"name": "If-Match", "in": "header", "required": true, "schema": tag,
+		"description": "ETag of the version being replaced, or * for any version",
+	})
+	return append(errorCodes, http.StatusPreconditionFailed, http.StatusPreconditionRequired), parameters
+}
+
+func addOperation(paths map[string]interface{}, path string, method string, document map[string]interface{}) {
+	pathItem, ok := paths[path].(map[string]interface{})
+	if !ok {
+		pathItem = map[string]interface{}{}
+		paths[path] = pathItem
+	}
+
+	pathItem[strings.ToLower(method)] = document
+}
+
+func isAPIRoute(path string) bool {
+	return strings.HasPrefix(path, apiV1Prefix+"/")
+}
+
+// operationID turns "PUT /lectures/{lecture_id}/add-student" into
+// "putLecturesLectureIdAddStudent".
+func operationID(operation apiOperation) string {
+	id := strings.ToLower(operation.method)
+	for _, word := range strings.FieldsFunc(operation.path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_' || r == '.'
+	}) {
+		id += strings.ToUpper(word[:1]) + word[1:]
+	}
+
+	return id
+}
+
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+func jsonFields(typ reflect.Type) []jsonField {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	fields := []jsonField{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, jsonField{name: name, typ: field.Type, omitEmpty: strings.Contains(options, "omitempty")})
+	}
+
+	return fields
+}
+
+// schemaFor describes typ as encoding/json would marshal it. Named structs
+// are added to schemas once and referenced from then on. Only response
+// schemas list required fields: a field without omitempty is always sent,
+// whereas the handlers validate request fields themselves.
+func schemaFor(typ reflect.Type, schemas map[string]interface{}, response bool) map[string]interface{} {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return schemaFor(typ.Elem(), schemas, response)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(typ.Elem(), schemas, response)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(typ.Elem(), schemas, response)}
+	case reflect.Struct:
+		name := strings.ToUpper(typ.Name()[:1]) + typ.Name()[1:]
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+
+		// Registered before recursing so self-referencing types terminate.
+		schema := map[string]interface{}{"type": "object"}
+		schemas[name] = schema
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, field := range jsonFields(typ) {
+			properties[field.name] = schemaFor(field.typ, schemas, response)
+			if response && !field.omitEmpty && field.typ.Kind() != reflect.Pointer {
+				required = append(required, field.name)
+			}
+		}
+
+		schema["properties"] = properties
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+
+		return ref
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (srv *server) openAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		srv.respond(w, srv.openAPIDocument(), http.StatusOK)
+	}
+}
+
+// swaggerUIFiles holds the Swagger UI assets; see swagger-ui/README.md.
+//
+//go:embed swagger-ui
+var swaggerUIFiles embed.FS
+
+// swaggerUIAssets are the files /docs/{asset} serves, with their content type.
+var swaggerUIAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+const swaggerUIScript = `window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});`
+
+var swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>serviceschool API</title>
+  <link rel="stylesheet" href="/docs/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/swagger-ui-bundle.js"></script>
+  <script>` + swaggerUIScript + `</script>
+</body>
+</html>
+`
+
+// swaggerUIPolicy relaxes the API's CSP just enough for the docs page: the
+// UI's assets from this origin, its one inline script pinned by hash, and
+// fetching the spec.
+var swaggerUIPolicy = func() string {
+	scriptHash := sha256.Sum256([]byte(swaggerUIScript))
+	return "default-src 'none'; " +
+		"script-src 'self' 'sha256-" + base64.StdEncoding.EncodeToString(scriptHash[:]) + "'; " +
+		"style-src 'self'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+}()
+
+func (srv *server) swaggerUIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", swaggerUIPolicy)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(swaggerUIPage)); err != nil {
+			srv.logger.Error(err)
+		}
+	}
+}
+
+func (srv *server) swaggerUIAssetHandler() http.HandlerFunc {
+	files := srv.swaggerUI
+	if files == nil {
+		files, _ = fs.Sub(swaggerUIFiles, "swagger-ui")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), srv.logger)
+		asset := mux.Vars(r)["asset"]
+		contentType, ok := swaggerUIAssets[asset]
+		if !ok {
+			srv.respond(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		content, err := fs.ReadFile(files, asset)
+		if err != nil {
+			logger.Errorf("Swagger UI asset %s is not embedded, see internal/server/swagger-ui/README.md: %v", asset, err)
+			srv.respond(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(content); err != nil {
+			logger.Error(err)
+		}
+	}
+}
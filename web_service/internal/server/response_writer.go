@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// statusRecorder remembers the status code a handler wrote, for middlewares
+// that report on the response after the fact. identifyUser notes the caller
+// it accepted in userID, for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	userID string
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// bodyRecorder also keeps a copy of the body, for responses that are stored
+// to be replayed later.
+type bodyRecorder struct {
+	*statusRecorder
+	body bytes.Buffer
+}
+
+func newBodyRecorder(w http.ResponseWriter) *bodyRecorder {
+	return &bodyRecorder{statusRecorder: newStatusRecorder(w)}
+}
+
+func (recorder *bodyRecorder) Write(data []byte) (int, error) {
+	recorder.body.Write(data)
+	return recorder.statusRecorder.Write(data)
+}
@@ -2,20 +2,39 @@ package server
 
 import (
 	"net/http"
+	"time"
+	"web_service/internal/metrics"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("web_service/internal/server")
+
 type Router interface {
 	ServeHttp(w http.ResponseWriter, r *http.Request)
 	Get(string, http.HandlerFunc)
 	Post(string, http.HandlerFunc)
 	Put(string, http.HandlerFunc)
+	Patch(string, http.HandlerFunc)
 	Delete(string, http.HandlerFunc)
+	Use(...mux.MiddlewareFunc)
+	Subrouter(prefix string) Router
 }
 
 type router struct {
-	mux *mux.Router
+	mux     *mux.Router
+	metrics *metrics.Metrics
+}
+
+func newRouter(metrics *metrics.Metrics) *router {
+	router := &router{mux: mux.NewRouter(), metrics: metrics}
+	router.mux.Use(router.instrument, router.trace)
+	return router
 }
 
 func (router *router) ServeHttp(w http.ResponseWriter, r *http.Request) {
@@ -34,6 +53,68 @@ func (router *router) Put(path string, handlerFunc http.HandlerFunc) {
 	router.mux.HandleFunc(path, handlerFunc).Methods(http.MethodPut)
 }
 
+func (router *router) Patch(path string, handlerFunc http.HandlerFunc) {
+	router.mux.HandleFunc(path, handlerFunc).Methods(http.MethodPatch)
+}
+
 func (router *router) Delete(path string, handlerFunc http.HandlerFunc) {
 	router.mux.HandleFunc(path, handlerFunc).Methods(http.MethodDelete)
 }
+
+func (router *router) Use(middlewares ...mux.MiddlewareFunc) {
+	router.mux.Use(middlewares...)
+}
+
+// Subrouter mounts routes under prefix. The parent's middlewares, including
+// instrument and trace, still run for them, and their route templates carry
+// the prefix.
+func (router *router) Subrouter(prefix string) Router {
+	subrouter := *router
+	subrouter.mux = router.mux.PathPrefix(prefix).Subrouter()
+	return &subrouter
+}
+
+// instrument is the outermost middleware of every matched route. It labels
+// requests with the route template, e.g. /lectures/{lecture_id}/students, so
+// IDs in the path don't explode the metric cardinality.
+func (router *router) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := newStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		router.metrics.ObserveHTTPRequest(r.Method, routeTemplate(r), recorder.status, time.Since(start))
+	})
+}
+
+// trace starts the server span of a request, continuing the caller's trace
+// when a W3C traceparent header is present.
+func (router *router) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethod(r.Method), semconv.HTTPRoute(route)),
+		)
+		defer span.End()
+
+		recorder := newStatusRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+		if template, err := currentRoute.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+
+	return r.URL.Path
+}
@@ -3,79 +3,295 @@ package server
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"web_service/internal/auth"
 	"web_service/internal/config"
 	"web_service/internal/database"
-	"web_service/internal/domain/models"
+	"web_service/internal/logging"
+	"web_service/internal/metrics"
+	"web_service/internal/notifications"
+	"web_service/internal/outbox"
+	"web_service/internal/ratelimit"
 	"web_service/internal/repositories"
+	"web_service/internal/tracing"
+	"web_service/internal/webhooks"
 
-	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
 type server struct {
-	repoLects repositories.RepoLecture
-	repoUsers repositories.UserRepo
-	router    Router
-	logger    *zap.SugaredLogger
+	repoLects        repositories.RepoLecture
+	repoUsers        repositories.UserRepo
+	repoIdempotency  repositories.IdempotencyRepo
+	repoAudit        repositories.AuditRepo
+	repoWebhooks     repositories.WebhookRepo
+	webhookAddresses *webhooks.Addresses
+	templates        *notifications.Templates
+	router           Router
+	logger           *zap.SugaredLogger
+	userIDHeader     string
+	trustedProxies   auth.Networks
+	logLevel         *zap.AtomicLevel
+	metrics          *metrics.Metrics
+	rateLimiter      *ratelimit.RateLimiter
+	cors             *corsPolicy
+	maxBodyBytes     int64
+	swaggerUI        fs.FS
+	hstsMaxAge       time.Duration
+	idempotencyTTL   time.Duration
+	idempotencyLock  time.Duration
+	readinessChecks  []readinessCheck
+	shuttingDown     atomic.Bool
 }
 
-func NewServer(repoLects repositories.RepoLecture, repoUsers repositories.UserRepo, logger *zap.SugaredLogger) *server {
-	return &server{repoLects: repoLects, repoUsers: repoUsers, router: &router{mux: mux.NewRouter()}, logger: logger}
+func NewServer(repoLects repositories.RepoLecture, repoUsers repositories.UserRepo, logger *zap.SugaredLogger, metrics *metrics.Metrics) *server {
+	return &server{repoLects: repoLects, repoUsers: repoUsers, webhookAddresses: &webhooks.Addresses{}, router: newRouter(metrics), logger: logger, userIDHeader: auth.UserIDHeader, metrics: metrics}
 }
 
+// ServeHTTP answers CORS preflights before routing, since the router would
+// reject OPTIONS with 405 before any middleware runs.
 func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.setSecurityHeaders(w.Header())
+	if srv.cors != nil && srv.cors.handle(w, r) {
+		return
+	}
+
 	srv.router.ServeHttp(w, r)
 }
 
 func (srv *server) initializeRoutes() {
 	srv.logger.Info("server INIT")
-	srv.router.Post("/users", srv.contextExpire(srv.createUserHandler()))
-	srv.router.Post("/lectures", srv.contextExpire(srv.createLectureHandler()))
-	srv.router.Put("/lectures/{lecture_id}/add-student", srv.contextExpire(srv.addUserToLectureHandler()))
-	srv.router.Delete("/lectures/{lecture_id}/remove-student", srv.contextExpire(srv.deleteUserFromLectureHandler()))
-	srv.router.Get("/lectures", srv.contextExpire(srv.getLecturesPPHandler()))
+	srv.router.Use(srv.logRequests, srv.identifyUser)
+	srv.router.Get("/healthz", srv.livenessHandler())
+	srv.router.Get("/readyz", srv.readinessHandler())
+	srv.router.Get("/metrics", srv.metrics.Handler().ServeHTTP)
+	srv.router.Get("/openapi.json", srv.openAPIHandler())
+	srv.router.Get("/docs", srv.swaggerUIHandler())
+	srv.router.Get("/docs/{asset}", srv.swaggerUIAssetHandler())
+	// Like /metrics, admin routes are expected to be kept internal by the gateway.
+	srv.router.Get("/admin/audit", srv.adminOnly(srv.contextExpire(srv.getAuditEventsHandler())))
+	srv.router.Post("/admin/webhooks", srv.adminOnly(srv.contextExpire(srv.createWebhookHandler())))
+	srv.router.Get("/admin/webhooks", srv.adminOnly(srv.contextExpire(srv.getWebhooksHandler())))
+	srv.router.Delete("/admin/webhooks/{webhook_id}", srv.adminOnly(srv.contextExpire(srv.deleteWebhookHandler())))
+	srv.router.Get("/admin/webhooks/{webhook_id}/deliveries", srv.adminOnly(srv.contextExpire(srv.getWebhookDeliveriesHandler())))
+	srv.router.Post("/admin/webhook-deliveries/{delivery_id}/retry", srv.adminOnly(srv.contextExpire(srv.retryWebhookDeliveryHandler())))
+	if srv.logLevel != nil {
+		srv.router.Get("/admin/log-level", srv.adminOnly(srv.logLevel.ServeHTTP))
+		srv.router.Put("/admin/log-level", srv.adminOnly(srv.logLevel.ServeHTTP))
+	}
+
+	srv.registerAPIRoutes(srv.router.Subrouter(apiV1Prefix), func(h http.HandlerFunc) http.HandlerFunc { return h })
+	srv.registerAPIRoutes(srv.router, srv.deprecated)
+}
+
+// registerAPIRoutes adds the versioned API to r, passing every handler through
+// wrap first.
+func (srv *server) registerAPIRoutes(r Router, wrap func(http.HandlerFunc) http.HandlerFunc) {
+	r.Post("/users", wrap(srv.rateLimit(rateLimitSignup, srv.idempotent(srv.contextExpire(srv.createUserHandler())))))
+	r.Get("/users", wrap(srv.rateLimit(rateLimitDefault, srv.adminOnly(srv.contextExpire(srv.getUsersHandler())))))
+	r.Get("/users/{user_id}", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.getUserHandler()))))
+	r.Patch("/users/{user_id}", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.updateUserHandler()))))
+	r.Delete("/users/{user_id}", wrap(srv.rateLimit(rateLimitDefault, srv.adminOnly(srv.contextExpire(srv.deleteUserHandler())))))
+	r.Get("/users/{user_id}/lectures", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.getUserLecturesHandler()))))
+	r.Get("/me/schedule", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.getMyScheduleHandler()))))
+	r.Post("/lectures", wrap(srv.rateLimit(rateLimitDefault, srv.adminOnly(srv.idempotent(srv.contextExpire(srv.createLectureHandler()))))))
+	r.Get("/lectures/{lecture_id}", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.getLectureHandler()))))
+	r.Patch("/lectures/{lecture_id}", wrap(srv.rateLimit(rateLimitDefault, srv.adminOnly(srv.contextExpire(srv.updateLectureHandler())))))
+	r.Delete("/lectures/{lecture_id}", wrap(srv.rateLimit(rateLimitDefault, srv.adminOnly(srv.contextExpire(srv.deleteLectureHandler())))))
+	r.Put("/lectures/{lecture_id}/add-student", wrap(srv.rateLimit(rateLimitEnrollment, srv.idempotent(srv.contextExpire(srv.addUserToLectureHandler())))))
+	r.Delete("/lectures/{lecture_id}/remove-student", wrap(srv.rateLimit(rateLimitEnrollment, srv.contextExpire(srv.deleteUserFromLectureHandler()))))
+	r.Put("/lectures/{lecture_id}/status", wrap(srv.rateLimit(rateLimitDefault, srv.adminOnly(srv.idempotent(srv.contextExpire(srv.changeLectureStatusHandler()))))))
+	r.Get("/lectures", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.getLecturesPPHandler()))))
+	r.Get("/lectures/{lecture_id}/students", wrap(srv.rateLimit(rateLimitDefault, srv.contextExpire(srv.getLectureStudentsHandler()))))
 }
 
 func Run() {
-	logger, err := zap.NewDevelopment()
+	bootstrapLogger, err := zap.NewDevelopment()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	sources, err := config.ParseFlags(os.Args[1:])
+	if err != nil {
+		bootstrapLogger.Sugar().Fatal(err)
+	}
+
+	cfg, err := config.NewConfig(bootstrapLogger, sources)
+	if err != nil {
+		bootstrapLogger.Sugar().Fatal(err)
+	}
+
+	logger, logLevel, err := logging.New(cfg.Logging)
+	if err != nil {
+		bootstrapLogger.Sugar().Fatal(err)
+	}
+
 	defer logger.Sync()
 
-	cfg, err := config.NewConfig(logger)
+	logging.SetSensitiveFields(cfg.Logging.RedactFields)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
 	if err != nil {
 		logger.Sugar().Fatal(err)
 	}
 
-	ctx := context.Background()
+	defer flushTraces(shutdownTracing, logger.Sugar())
+
 	psglDB := database.NewPostgresDB()
 	db, err := psglDB.SetupDatabase(ctx, cfg, logger)
 	if err != nil {
 		logger.Sugar().Fatal(err)
 	}
 
-	if !db.Migrator().HasTable(&models.Lecture{}) {
-		err = db.AutoMigrate(&models.Lecture{})
-		if err != nil {
-			logger.Sugar().Error(err)
-			return
-		}
+	defer closeDatabase(psglDB, logger.Sugar())
 
-		logger.Sugar().Info("Migration success")
+	err = database.Migrate(db, logger)
+	if err != nil {
+		return
 	}
 
 	repoLect := repositories.NewRepoLecture(db, logger.Sugar())
-	repoUser := repositories.NewUserRepo(db, logger.Sugar())
-	srv := NewServer(repoLect, repoUser, logger.Sugar())
+	repoUser := repositories.NewUserRepo(db, []byte(cfg.Audit.FingerprintKey), logger.Sugar())
+	appMetrics := metrics.New()
+	srv := NewServer(repoLect, repoUser, logger.Sugar(), appMetrics)
+	srv.logLevel = &logLevel
+	srv.userIDHeader = cfg.Auth.UserIDHeader
+	srv.trustedProxies, err = auth.ParseNetworks(cfg.Auth.TrustedProxies)
+	if err != nil {
+		logger.Sugar().Fatal(err)
+	}
 
-	srv.initializeRoutes()
-	logger.Sugar().Infof("Listening HTTP service on %s port", cfg.AppPort)
-	err = http.ListenAndServe(fmt.Sprintf(":%s", cfg.AppPort), srv)
+	srv.rateLimiter = newRateLimiter(cfg.RateLimit, srv.trustedProxies)
+	srv.cors = newCORSPolicy(cfg.CORS)
+	srv.maxBodyBytes = cfg.HTTP.MaxBodyBytes
+	srv.hstsMaxAge = cfg.HTTP.HSTSMaxAge
+	srv.repoIdempotency = repositories.NewIdempotencyRepo(db, logger.Sugar())
+	srv.repoAudit = repositories.NewAuditRepo(db, logger.Sugar())
+	srv.repoWebhooks = repositories.NewWebhookRepo(db, logger.Sugar())
+	srv.webhookAddresses = &webhooks.Addresses{AllowPrivate: cfg.Webhooks.AllowPrivateNetworks}
+	srv.idempotencyTTL = cfg.Idempotency.TTL
+	srv.idempotencyLock = cfg.Idempotency.LockTimeout
+
+	// The workers are stopped and waited for after the HTTP server drained,
+	// and before the deferred closeDatabase takes their connections away.
+	var workers sync.WaitGroup
+	defer func() {
+		stop()
+		workers.Wait()
+		logger.Info("Background workers have stopped")
+	}()
+
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	runWorker(func(ctx context.Context) { srv.purgeIdempotencyKeys(ctx, cfg.Idempotency.CleanupInterval) })
+
+	publisher, err := outbox.NewPublisher(cfg.Outbox, logger.Sugar())
+	if err != nil {
+		logger.Sugar().Fatal(err)
+	}
+
+	notifier, err := notifications.NewNotifier(cfg.Notifications, logger.Sugar())
+	if err != nil {
+		logger.Sugar().Fatal(err)
+	}
+
+	srv.templates, err = notifications.LoadTemplates(cfg.Notifications.DefaultLocale)
 	if err != nil {
 		logger.Sugar().Fatal(err)
 	}
+
+	repoNotifications := repositories.NewNotificationRepo(db, logger.Sugar())
+	publisher = outbox.Fanout(publisher, webhooks.NewDispatcher(srv.repoWebhooks), notifications.NewDispatcher(repoNotifications))
+	runWorker(outbox.NewRelay(repositories.NewOutboxRepo(db, logger.Sugar()), publisher, cfg.Outbox, logger.Sugar()).Run)
+	runWorker(webhooks.NewDeliverer(srv.repoWebhooks, cfg.Webhooks, logger.Sugar()).Run)
+	runWorker(notifications.NewSender(repoNotifications, notifier, srv.templates, cfg.Notifications, logger.Sugar()).Run)
+	runWorker(notifications.NewReminders(repoNotifications, repoLect, cfg.Notifications, logger.Sugar()).Run)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Sugar().Fatal(err)
+	}
+
+	appMetrics.RegisterDBStats(sqlDB, cfg.DB.Name)
+	srv.addReadinessCheck("postgres", sqlDB.PingContext)
+	srv.addReadinessCheck("migrations", passesOnce(func(ctx context.Context) error {
+		return database.CheckMigrations(db.WithContext(ctx))
+	}))
+
+	srv.initializeRoutes()
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.HTTP.Port),
+		Handler:           srv,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Sugar().Infof("Listening HTTP service on %s port", cfg.HTTP.Port)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		// Not Fatal: returning lets the deferred pool close and trace flush run.
+		logger.Sugar().Errorf("HTTP server: %v", err)
+		return
+	case <-ctx.Done():
+		stop()
+	}
+
+	srv.markShuttingDown()
+	logger.Sugar().Infof("Shutting down, failing readiness for %v before closing the listener", cfg.HTTP.ShutdownDelay)
+	time.Sleep(cfg.HTTP.ShutdownDelay)
+	logger.Sugar().Infof("Draining in-flight requests for up to %v", cfg.HTTP.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Sugar().Errorf("HTTP server shutdown: %v", err)
+		return
+	}
+
+	logger.Info("HTTP server has been stopped")
+}
+
+// closeDatabase releases the pools only after the HTTP server has drained, so
+// requests finishing during shutdown still have their connections.
+func closeDatabase(psglDB database.PostgresDB, logger *zap.SugaredLogger) {
+	if err := psglDB.Close(); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	logger.Info("DB Postgres connection pools have been closed")
+}
+
+// flushTraces exports the spans still buffered by the batcher before exit.
+func flushTraces(shutdown func(context.Context) error, logger *zap.SugaredLogger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdown(ctx); err != nil {
+		logger.Error(err)
+	}
 }
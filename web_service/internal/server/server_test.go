@@ -4,27 +4,70 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 	"web_service/internal/apperrors"
+	"web_service/internal/auth"
+	"web_service/internal/config"
 	"web_service/internal/domain/mappers"
 	"web_service/internal/domain/models"
 	"web_service/internal/domain/requests"
 	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
+	"web_service/internal/metrics"
 	"web_service/internal/mock"
+	"web_service/internal/notifications"
+	"web_service/internal/outbox"
+	"web_service/internal/webhooks"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
+// testGateway trusts the address httptest gives every request, as if it came
+// through the gateway.
+var testGateway = auth.Networks{netip.MustParsePrefix("192.0.2.1/32")}
+
+// outboxEventMatcher matches the domain event a repository write is given to
+// store in the outbox.
+type outboxEventMatcher struct {
+	eventType string
+}
+
+func outboxEvent(eventType string) gomock.Matcher {
+	return outboxEventMatcher{eventType: eventType}
+}
+
+func (m outboxEventMatcher) Matches(x interface{}) bool {
+	event, ok := x.(*models.OutboxEvent)
+	return ok && event.Type == m.eventType && json.Valid(event.Payload)
+}
+
+func (m outboxEventMatcher) String() string {
+	return "is a " + m.eventType + " outbox event"
+}
+
 func TestCreateUser(t *testing.T) {
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -38,7 +81,8 @@ func TestCreateUser(t *testing.T) {
 		FirstName: "Third",
 		LastName:  "last name",
 		Password:  "BoBEEEEEEER3",
-		Role:      "student3",
+		Role:      models.RoleStudent,
+		Locale:    "en-GB",
 	}
 
 	requestBody, err := json.Marshal(createUserRequest)
@@ -46,6 +90,21 @@ func TestCreateUser(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	unknownLocaleRequestBody, err := json.Marshal(&requests.CreateUserRequest{Email: "har@name.one", Password: "BoBEEEEEEER3", Locale: "xx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := notifications.LoadTemplates("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminRequestBody, err := json.Marshal(&requests.CreateUserRequest{Email: "root@name.one", Password: "BoBEEEEEEER3", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	user := mappers.MapCreateUserRequestToUser(createUserRequest)
 	userUUID, err := uuid.Parse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
 	if err != nil {
@@ -95,6 +154,24 @@ func TestCreateUser(t *testing.T) {
 			&apperrors.CreateUserServiceErr,
 			apperrors.CreateUserServiceErr.HTTPCode,
 		},
+		{
+			"create_user_admin_by_anonymous",
+			adminRequestBody,
+			user,
+			"json",
+			nil,
+			&apperrors.UnauthenticatedErr,
+			apperrors.UnauthenticatedErr.HTTPCode,
+		},
+		{
+			"create_user_unknown_locale",
+			unknownLocaleRequestBody,
+			user,
+			"json",
+			nil,
+			&apperrors.CreateUserHandlerErr,
+			apperrors.CreateUserHandlerErr.HTTPCode,
+		},
 	}
 
 	ctrl := gomock.NewController(t)
@@ -104,11 +181,10 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tc := range testTable {
 		t.Run(tc.scenario, func(t *testing.T) {
-			ctx := context.Background()
 
 			usersRepoMock := mock.NewMockUserRepo(ctrl)
 			logger.Info("mocks inited")
-			srv := &server{repoUsers: usersRepoMock, logger: logger.Sugar()}
+			srv := &server{repoUsers: usersRepoMock, templates: templates, logger: logger.Sugar()}
 			logger.Info("server inited")
 
 			logger.Info("reqBody inited")
@@ -127,7 +203,7 @@ func TestCreateUser(t *testing.T) {
 			logger.Info("httptest.NewRequest inited")
 			rec := httptest.NewRecorder()
 
-			usersRepoMock.EXPECT().CreateUser(ctx, gomock.Any()).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
+			usersRepoMock.EXPECT().CreateUser(gomock.Any(), gomock.Any(), outboxEvent(models.EventUserCreated)).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
 			logger.Info("mock.EXPECT inited")
 
 			createUser := srv.createUserHandler()
@@ -237,7 +313,6 @@ func TestCreateLecture(t *testing.T) {
 
 	for _, tc := range testTable {
 		t.Run(tc.scenario, func(t *testing.T) {
-			ctx := context.Background()
 
 			lectureRepoMock := mock.NewMockRepoLecture(ctrl)
 			logger.Info("mocks inited")
@@ -260,7 +335,7 @@ func TestCreateLecture(t *testing.T) {
 			logger.Info("httptest.NewRequest inited")
 			rec := httptest.NewRecorder()
 
-			lectureRepoMock.EXPECT().CreateLecture(ctx, gomock.Any()).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
+			lectureRepoMock.EXPECT().CreateLecture(gomock.Any(), gomock.Any(), outboxEvent(models.EventLectureCreated)).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
 			logger.Info("mock.EXPECT inited")
 
 			createLect := srv.createLectureHandler()
@@ -294,26 +369,6 @@ func TestGetLecturesPPHandler(t *testing.T) {
 
 	defer logger.Sync()
 	logger.Info("logger inited")
-	getLectsPPRequest := &requests.GetLecturesPPRequest{
-		Page:    "hi",
-		PerPage: "10",
-	}
-
-	requestBody, err := json.Marshal(getLectsPPRequest)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	getLectsPPRequestPos := &requests.GetLecturesPPRequest{
-		Page:    "1",
-		PerPage: "10",
-	}
-
-	requestBodyPos, err := json.Marshal(getLectsPPRequestPos)
-	if err != nil {
-		t.Fatal(err)
-	}
-
 	lectID, _ := uuid.Parse("318f38ad-76dc-41d9-8ce5-7900559264dd")
 	userID, _ := uuid.Parse("9ead1870-0962-4f24-ac0b-c1901af0899b")
 	user := &models.User{ID: &userID, Email: "har@name.one4"}
@@ -326,41 +381,89 @@ func TestGetLecturesPPHandler(t *testing.T) {
 
 	lectures := []*models.Lecture{lecture}
 
+	lecturesPage := &models.LecturesPage{Lectures: lectures, PageInfo: models.PageInfo{NextCursor: "next"}}
+
 	getLectsPPResp, _ := mappers.MapGetAllLecturesAndStudentsToGetLecturesAndStudentsPPRespResponse(lectures)
+	getLectsPageResp := &responses.GetLecturesPageResponse{Lectures: getLectsPPResp, PerPage: 10, NextCursor: "next"}
+	getLectsMaxPageResp := &responses.GetLecturesPageResponse{Lectures: getLectsPPResp, PerPage: models.MaxPerPage, NextCursor: "next"}
 
 	testTable := []struct {
 		scenario              string
-		inputGetLectReq       []byte
-		contentType           string
-		response              []*responses.GetLecturesAndStudentsPPResponse
-		expectedSetOfLectures []*models.Lecture
+		inputQuery            string
+		response              *responses.GetLecturesPageResponse
+		expectedSetOfLectures *models.LecturesPage
 		expectedErr           error
 		httpCode              int
 	}{
 		{
-			"get_lecture_decode_err",
-			[]byte("invalid json"),
-			"json",
+			"get_lecture_invalid_query",
+			"?page=1&per_page=10&has_free_seats=maybe",
+			nil,
+			lecturesPage,
+			apperrors.InvalidLecturesQueryErr.AppendMessage("has_free_seats"),
+			apperrors.InvalidLecturesQueryErr.HTTPCode,
+		},
+		{
+			"get_lecture_unknown_include",
+			"?include=speaker",
+			nil,
+			lecturesPage,
+			apperrors.InvalidLecturesQueryErr.AppendMessage("include"),
+			apperrors.InvalidLecturesQueryErr.HTTPCode,
+		},
+		{
+			"get_lecture_unknown_sort",
+			"?sort=speaker",
 			nil,
-			lectures,
-			apperrors.GetLecturesPPHandlerErr.AppendMessage("Bind lecture id"),
-			apperrors.GetLecturesPPHandlerErr.HTTPCode,
+			lecturesPage,
+			apperrors.InvalidLecturesQueryErr.AppendMessage("sort"),
+			apperrors.InvalidLecturesQueryErr.HTTPCode,
 		},
 		{
 			"get_lecture_service_err",
-			requestBody,
-			"json",
+			"?page=hi&per_page=10",
+			nil,
+			lecturesPage,
+			apperrors.InvalidPageRequestErr.AppendMessage("page must be a positive integer: hi"),
+			apperrors.InvalidPageRequestErr.HTTPCode,
+		},
+		{
+			"get_lecture_zero_page",
+			"?page=0",
+			nil,
+			lecturesPage,
+			apperrors.InvalidPageRequestErr.AppendMessage("page must be a positive integer: 0"),
+			apperrors.InvalidPageRequestErr.HTTPCode,
+		},
+		{
+			"get_lecture_negative_per_page",
+			"?per_page=-5",
+			nil,
+			lecturesPage,
+			apperrors.InvalidPageRequestErr.AppendMessage("per_page must be a positive integer: -5"),
+			apperrors.InvalidPageRequestErr.HTTPCode,
+		},
+		{
+			"get_lecture_invalid_cursor",
+			"?cursor=broken",
+			nil,
 			nil,
-			lectures,
-			apperrors.GetLecturesPPServiceErr.AppendMessage("strconv err: hi"),
-			apperrors.GetLecturesPPServiceErr.HTTPCode,
+			apperrors.InvalidCursorErr.AppendMessage("broken"),
+			apperrors.InvalidCursorErr.HTTPCode,
 		},
 		{
 			"get_lecture_service_POSITIVE",
-			requestBodyPos,
-			"json",
-			getLectsPPResp,
-			lectures,
+			"?page=1&per_page=10&speaker=Santa&from=2024-01-01T00:00:00Z&has_free_seats=true&q=new+year&sort=-date&include=students",
+			getLectsPageResp,
+			lecturesPage,
+			nil,
+			http.StatusOK,
+		},
+		{
+			"get_lecture_per_page_capped",
+			"?per_page=1000",
+			getLectsMaxPageResp,
+			lecturesPage,
 			nil,
 			http.StatusOK,
 		},
@@ -373,30 +476,17 @@ func TestGetLecturesPPHandler(t *testing.T) {
 
 	for _, tc := range testTable {
 		t.Run(tc.scenario, func(t *testing.T) {
-			ctx := context.Background()
 
 			lectureRepoMock := mock.NewMockRepoLecture(ctrl)
 			logger.Info("mocks inited")
 			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar()}
 			logger.Info("server inited")
 
-			logger.Info("reqBody inited")
-			var reqCreateLectureArr io.Reader
-			if tc.scenario != "get_lecture_decode_err" {
-				reqCreateLectureArr = bytes.NewReader(tc.inputGetLectReq)
-			}
-
-			if tc.scenario == "get_lecture_decode_err" {
-				reqCreateLectureArr = strings.NewReader("invalid json")
-			}
-
-			req := httptest.NewRequest(http.MethodGet, "/lectures", reqCreateLectureArr)
-
-			req.Header.Set("Content-Type", tc.contentType)
+			req := httptest.NewRequest(http.MethodGet, "/lectures"+tc.inputQuery, nil)
 			logger.Info("httptest.NewRequest inited")
 			rec := httptest.NewRecorder()
 
-			lectureRepoMock.EXPECT().GetLecturesAndStudentsPP(ctx, gomock.Any(), gomock.Any()).Return(tc.expectedSetOfLectures, tc.expectedErr).AnyTimes()
+			lectureRepoMock.EXPECT().GetLecturesAndStudentsPP(gomock.Any(), gomock.Any(), gomock.Any()).Return(tc.expectedSetOfLectures, tc.expectedErr).AnyTimes()
 			logger.Info("mock.EXPECT inited")
 
 			getLectsPP := srv.getLecturesPPHandler()
@@ -442,6 +532,13 @@ func TestAddUserToLectureHandler(t *testing.T) {
 
 	lectIdFail := "22"
 	lectureID := "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf"
+	lectureUUID := uuid.MustParse(lectureID)
+	studentUUID := uuid.MustParse(addUserToLectReq.UserId)
+	bookableLecture := &models.Lecture{
+		ID:     &lectureUUID,
+		Status: models.LectureStatusPublished,
+		Date:   time.Now().Add(24 * time.Hour),
+	}
 
 	createLectResp := &responses.CreateLectureResponse{
 		LectureId: lectureID,
@@ -487,6 +584,16 @@ func TestAddUserToLectureHandler(t *testing.T) {
 			&apperrors.AddStudentToLectureServiceErr,
 			apperrors.AddStudentToLectureServiceErr.HTTPCode,
 		},
+		{
+			"add_user_to_lecture_no_free_seats",
+			requestBody,
+			lectureID,
+			"json",
+			nil,
+			"Lecture is not open for enrollment : [no free seats left, capacity: 1]",
+			apperrors.LectureNotBookableErr.AppendMessage("no free seats left, capacity:", 1),
+			apperrors.LectureNotBookableErr.HTTPCode,
+		},
 		{
 			"add_user_to_lecture_POSITIVE",
 			requestBody,
@@ -508,7 +615,7 @@ func TestAddUserToLectureHandler(t *testing.T) {
 		t.Run(tc.scenario, func(t *testing.T) {
 			lectureRepoMock := mock.NewMockRepoLecture(ctrl)
 			logger.Info("mocks inited")
-			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar()}
+			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar(), userIDHeader: auth.UserIDHeader, trustedProxies: testGateway}
 			logger.Info("server inited")
 
 			logger.Info("reqBody inited")
@@ -527,15 +634,18 @@ func TestAddUserToLectureHandler(t *testing.T) {
 			}
 
 			req.Header.Set("Content-Type", tc.contentType)
+			// Students enroll and unenroll themselves.
+			req.Header.Set(auth.UserIDHeader, "9ead1870-0962-4f24-ac0b-c1901af0899b")
 			logger.Info("httptest.NewRequest inited")
 			rec := httptest.NewRecorder()
 
-			lectureRepoMock.EXPECT().AddUserToLecture(req.Context(), gomock.Any(), gomock.Any()).Return(tc.expectedErr).AnyTimes() //CreateLecture(ctx, gomock.Any()).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
+			lectureRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(bookableLecture, nil).AnyTimes()
+			lectureRepoMock.EXPECT().AddUserToLecture(gomock.Any(), bookableLecture, &models.User{ID: &studentUUID}, outboxEvent(models.EventStudentEnrolled)).Return(tc.expectedErr).AnyTimes() //CreateLecture(ctx, gomock.Any()).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
 			logger.Info("mock.EXPECT inited")
 
-			addUserToLect := srv.addUserToLectureHandler()
+			addUserToLect := srv.identifyUser(srv.addUserToLectureHandler())
 			logger.Info("addUserToLect")
-			addUserToLect(rec, req)
+			addUserToLect.ServeHTTP(rec, req)
 			logger.Info("addUserToLect(rec, req)")
 
 			if rec.Code != http.StatusOK {
@@ -617,6 +727,16 @@ func TestDeleteUserFromLectureHandler(t *testing.T) {
 			&apperrors.AddStudentToLectureServiceErr,
 			apperrors.AddStudentToLectureServiceErr.HTTPCode,
 		},
+		{
+			"drop_user_from_lecture_NOT_FOUND",
+			requestBody,
+			lectureID,
+			"json",
+			nil,
+			"",
+			apperrors.DropUserFromLectureErr.AppendMessage("record not found"),
+			apperrors.DropUserFromLectureErr.HTTPCode,
+		},
 		{
 			"add_user_to_lecture_POSITIVE",
 			requestBody,
@@ -638,7 +758,7 @@ func TestDeleteUserFromLectureHandler(t *testing.T) {
 		t.Run(tc.scenario, func(t *testing.T) {
 			lectureRepoMock := mock.NewMockRepoLecture(ctrl)
 			logger.Info("mocks inited")
-			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar()}
+			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar(), userIDHeader: auth.UserIDHeader, trustedProxies: testGateway}
 			logger.Info("server inited")
 
 			logger.Info("reqBody inited")
@@ -657,15 +777,17 @@ func TestDeleteUserFromLectureHandler(t *testing.T) {
 			}
 
 			req.Header.Set("Content-Type", tc.contentType)
+			// Students enroll and unenroll themselves.
+			req.Header.Set(auth.UserIDHeader, "9ead1870-0962-4f24-ac0b-c1901af0899b")
 			logger.Info("httptest.NewRequest inited")
 			rec := httptest.NewRecorder()
 
-			lectureRepoMock.EXPECT().DropUserFromLecture(req.Context(), gomock.Any(), gomock.Any()).Return(tc.expectedErr).AnyTimes() //CreateLecture(ctx, gomock.Any()).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
+			lectureRepoMock.EXPECT().DropUserFromLecture(gomock.Any(), gomock.Any(), gomock.Any(), outboxEvent(models.EventStudentDropped)).Return(tc.expectedErr).AnyTimes() //CreateLecture(ctx, gomock.Any()).Return(tc.user.ID.String(), tc.expectedErr).AnyTimes()
 			logger.Info("mock.EXPECT inited")
 
-			removeUserFromLect := srv.deleteUserFromLectureHandler()
+			removeUserFromLect := srv.identifyUser(srv.deleteUserFromLectureHandler())
 			logger.Info("addUserToLect")
-			removeUserFromLect(rec, req)
+			removeUserFromLect.ServeHTTP(rec, req)
 			logger.Info("addUserToLect(rec, req)")
 
 			if rec.Code != http.StatusOK {
@@ -682,3 +804,2336 @@ func TestDeleteUserFromLectureHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestEnrollmentRequiresSelfOrAdmin(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lecturesRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lecturesRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.initializeRoutes()
+
+	lectureUUID := uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	studentID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	otherUserID := uuid.MustParse("0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b21")
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id *uuid.UUID) (*models.User, error) {
+		if *id == adminID {
+			return &models.User{ID: id, Role: models.RoleAdmin}, nil
+		}
+
+		return &models.User{ID: id, Role: models.RoleStudent}, nil
+	}).AnyTimes()
+	lecturesRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(&models.Lecture{
+		ID:     &lectureUUID,
+		Status: models.LectureStatusPublished,
+		Date:   time.Now().Add(24 * time.Hour),
+	}, nil).AnyTimes()
+	lecturesRepoMock.EXPECT().AddUserToLecture(gomock.Any(), gomock.Any(), &models.User{ID: &studentID}, gomock.Any()).Return(nil).Times(2)
+	lecturesRepoMock.EXPECT().DropUserFromLecture(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	// Students enroll and unenroll only themselves; admins manage anyone's
+	// enrolments.
+	testTable := []struct {
+		scenario string
+		method   string
+		path     string
+		caller   string
+		httpCode int
+	}{
+		{"enroll_anonymous", http.MethodPut, "/add-student", "", http.StatusUnauthorized},
+		{"enroll_another_user", http.MethodPut, "/add-student", otherUserID.String(), http.StatusForbidden},
+		{"enroll_self", http.MethodPut, "/add-student", studentID.String(), http.StatusOK},
+		{"enroll_as_admin", http.MethodPut, "/add-student", adminID.String(), http.StatusOK},
+		{"unenroll_anonymous", http.MethodDelete, "/remove-student", "", http.StatusUnauthorized},
+		{"unenroll_another_user", http.MethodDelete, "/remove-student", otherUserID.String(), http.StatusForbidden},
+		{"unenroll_self", http.MethodDelete, "/remove-student", studentID.String(), http.StatusOK},
+		{"unenroll_as_admin", http.MethodDelete, "/remove-student", adminID.String(), http.StatusOK},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			body := `{"user_id": "` + studentID.String() + `"}`
+			req := httptest.NewRequest(tc.method, apiV1Prefix+"/lectures/"+lectureUUID.String()+tc.path, strings.NewReader(body))
+			if tc.caller != "" {
+				req.Header.Set(auth.UserIDHeader, tc.caller)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+		})
+	}
+}
+
+func TestChangeLectureStatusHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	lectureID := "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf"
+	lectureUUID := uuid.MustParse(lectureID)
+
+	publishRequest, err := json.Marshal(requests.ChangeLectureStatusRequest{Status: "published"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelWithoutReasonRequest, err := json.Marshal(requests.ChangeLectureStatusRequest{Status: "cancelled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelRequest, err := json.Marshal(requests.ChangeLectureStatusRequest{Status: "cancelled", Reason: "Speaker is ill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	studentID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	testTable := []struct {
+		scenario      string
+		inputBody     []byte
+		currentStatus models.LectureStatus
+		response      *responses.ChangeLectureStatusResponse
+		httpCode      int
+	}{
+		{
+			"change_status_decode_err",
+			[]byte("invalid json"),
+			models.LectureStatusDraft,
+			nil,
+			apperrors.ChangeLectureStatusHandlerErr.HTTPCode,
+		},
+		{
+			"change_status_cancel_without_reason",
+			cancelWithoutReasonRequest,
+			models.LectureStatusPublished,
+			nil,
+			apperrors.ChangeLectureStatusServiceErr.HTTPCode,
+		},
+		{
+			"change_status_not_allowed_transition",
+			publishRequest,
+			models.LectureStatusCancelled,
+			nil,
+			apperrors.LectureStatusTransitionErr.HTTPCode,
+		},
+		{
+			"change_status_POSITIVE",
+			publishRequest,
+			models.LectureStatusDraft,
+			&responses.ChangeLectureStatusResponse{LectureId: lectureID, Status: "published"},
+			http.StatusOK,
+		},
+		{
+			"change_status_cancel_POSITIVE",
+			cancelRequest,
+			models.LectureStatusPublished,
+			&responses.ChangeLectureStatusResponse{LectureId: lectureID, Status: "cancelled"},
+			http.StatusOK,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar()}
+
+			req := httptest.NewRequest(http.MethodPut, "/lectures/{lecture_id}/status", bytes.NewReader(tc.inputBody))
+			req = mux.SetURLVars(req, map[string]string{"lecture_id": lectureID})
+			rec := httptest.NewRecorder()
+
+			lecture := &models.Lecture{ID: &lectureUUID, Status: tc.currentStatus}
+			lectureRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(lecture, nil).AnyTimes()
+			lectureRepoMock.EXPECT().GetLectureStudents(gomock.Any(), lecture).Return([]*models.User{{ID: &studentID}}, nil).AnyTimes()
+			var updated *models.OutboxEvent
+			lectureRepoMock.EXPECT().UpdateLectureStatus(gomock.Any(), gomock.Any(), tc.currentStatus, outboxEvent(models.EventLectureUpdated)).
+				DoAndReturn(func(_ context.Context, _ *models.Lecture, _ models.LectureStatus, events ...*models.OutboxEvent) error {
+					updated = events[0]
+					return nil
+				}).AnyTimes()
+
+			changeStatus := srv.changeLectureStatusHandler()
+			changeStatus(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			// A cancellation lists the students it notifies, as enrolled when
+			// it happened.
+			payload := models.LectureUpdatedEvent{}
+			if assert.NoError(t, json.Unmarshal(updated.Payload, &payload)) {
+				if tc.response.Status == "cancelled" {
+					assert.Equal(t, []string{studentID.String()}, payload.StudentIDs)
+				} else {
+					assert.Empty(t, payload.StudentIDs)
+				}
+			}
+
+			marshalledResponse, err := json.Marshal(tc.response)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(marshalledResponse), strings.TrimSuffix(rec.Body.String(), "\n"))
+			}
+		})
+	}
+}
+
+func TestGetUsersHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	callers := map[string]*models.User{
+		"student": {ID: &userID, Role: models.RoleStudent},
+		"admin":   {ID: &adminID, Role: models.RoleAdmin},
+	}
+	totalCount := int64(1)
+	usersPage := &models.UsersPage{
+		Users:    []*models.User{{ID: &userID, Email: "har@name.one", FirstName: "First", LastName: "Last", Role: "student", Password: "hash"}},
+		PageInfo: models.PageInfo{PrevCursor: "prev", TotalCount: &totalCount},
+	}
+
+	getUsersResp := &responses.GetUsersPageResponse{
+		Users:      mappers.MapUsersToUserResponses(usersPage.Users),
+		PerPage:    20,
+		PrevCursor: "prev",
+		TotalCount: &totalCount,
+	}
+
+	testTable := []struct {
+		scenario    string
+		caller      string
+		inputQuery  string
+		usersPage   *models.UsersPage
+		expectedErr error
+		response    *responses.GetUsersPageResponse
+		httpCode    int
+	}{
+		{
+			"get_users_anonymous",
+			"",
+			"",
+			usersPage,
+			nil,
+			nil,
+			apperrors.UnauthenticatedErr.HTTPCode,
+		},
+		{
+			"get_users_not_admin",
+			"student",
+			"",
+			usersPage,
+			nil,
+			nil,
+			apperrors.ForbiddenErr.HTTPCode,
+		},
+		{
+			"get_users_invalid_include_total",
+			"admin",
+			"?include_total=sometimes",
+			usersPage,
+			nil,
+			nil,
+			apperrors.InvalidPageRequestErr.HTTPCode,
+		},
+		{
+			"get_users_invalid_cursor",
+			"admin",
+			"?cursor=broken",
+			nil,
+			apperrors.InvalidCursorErr.AppendMessage("broken"),
+			nil,
+			apperrors.InvalidCursorErr.HTTPCode,
+		},
+		{
+			"get_users_POSITIVE",
+			"admin",
+			"?per_page=20&cursor=abc&include_total=true",
+			usersPage,
+			nil,
+			getUsersResp,
+			http.StatusOK,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			usersRepoMock := mock.NewMockUserRepo(ctrl)
+			srv := &server{repoUsers: usersRepoMock, logger: logger.Sugar(), userIDHeader: auth.UserIDHeader, trustedProxies: testGateway}
+
+			req := httptest.NewRequest(http.MethodGet, "/users"+tc.inputQuery, nil)
+			if caller, ok := callers[tc.caller]; ok {
+				req.Header.Set(auth.UserIDHeader, caller.ID.String())
+				usersRepoMock.EXPECT().GetUserByID(gomock.Any(), caller.ID).Return(caller, nil).Times(1)
+			}
+
+			rec := httptest.NewRecorder()
+
+			usersRepoMock.EXPECT().GetUsers(gomock.Any(), gomock.Any()).Return(tc.usersPage, tc.expectedErr).AnyTimes()
+
+			srv.identifyUser(srv.adminOnly(srv.getUsersHandler())).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			assert.NotContains(t, rec.Body.String(), "hash")
+			assert.NotContains(t, rec.Body.String(), "har@name.one")
+			marshalledResponse, err := json.Marshal(tc.response)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(marshalledResponse), strings.TrimSuffix(rec.Body.String(), "\n"))
+			}
+		})
+	}
+}
+
+func TestGetLectureStudentsHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	lectureID := "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf"
+	lectureUUID := uuid.MustParse(lectureID)
+	lecture := &models.Lecture{ID: &lectureUUID, Status: models.LectureStatusPublished}
+
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	studentsPage := &models.UsersPage{
+		Users:    []*models.User{{ID: &userID, Email: "har@name.one", FirstName: "First", LastName: "Last"}},
+		PageInfo: models.PageInfo{NextCursor: "next"},
+	}
+
+	getStudentsResp := &responses.GetLectureStudentsPageResponse{
+		LectureId:  lectureID,
+		Students:   mappers.MapUsersToStudentResponses(studentsPage.Users),
+		PerPage:    models.DefaultPerPage,
+		NextCursor: "next",
+	}
+
+	testTable := []struct {
+		scenario       string
+		inputLectureID string
+		inputQuery     string
+		response       *responses.GetLectureStudentsPageResponse
+		httpCode       int
+	}{
+		{
+			"get_lecture_students_invalid_lecture_id",
+			"22",
+			"",
+			nil,
+			apperrors.GetLectureStudentsServiceErr.HTTPCode,
+		},
+		{
+			"get_lecture_students_invalid_per_page",
+			lectureID,
+			"?per_page=0",
+			nil,
+			apperrors.InvalidPageRequestErr.HTTPCode,
+		},
+		{
+			"get_lecture_students_POSITIVE",
+			lectureID,
+			"",
+			getStudentsResp,
+			http.StatusOK,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+			srv := &server{repoLects: lectureRepoMock, logger: logger.Sugar()}
+
+			req := httptest.NewRequest(http.MethodGet, "/lectures/{lecture_id}/students"+tc.inputQuery, nil)
+			req = mux.SetURLVars(req, map[string]string{"lecture_id": tc.inputLectureID})
+			rec := httptest.NewRecorder()
+
+			lectureRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(lecture, nil).AnyTimes()
+			lectureRepoMock.EXPECT().GetLectureStudentsPP(gomock.Any(), lecture, gomock.Any()).Return(studentsPage, nil).AnyTimes()
+
+			getStudents := srv.getLectureStudentsHandler()
+			getStudents(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			marshalledResponse, err := json.Marshal(tc.response)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(marshalledResponse), strings.TrimSuffix(rec.Body.String(), "\n"))
+			}
+
+			// The students of a lecture are public, their emails aren't.
+			assert.NotContains(t, rec.Body.String(), "har@name.one")
+		})
+	}
+}
+
+func TestGetUserLecturesHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.initializeRoutes()
+
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	otherUserID := uuid.MustParse("0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b21")
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id *uuid.UUID) (*models.User, error) {
+		if *id == adminID {
+			return &models.User{ID: id, Role: models.RoleAdmin}, nil
+		}
+
+		return &models.User{ID: id, Role: models.RoleStudent}, nil
+	}).AnyTimes()
+	usersRepoMock.EXPECT().GetUserLectures(gomock.Any(), gomock.Any(), gomock.Any()).Return([]*models.Lecture{}, nil).Times(2)
+
+	// A schedule is as private as /me/schedule: only its user and admins
+	// may read it.
+	testTable := []struct {
+		scenario string
+		caller   string
+		httpCode int
+	}{
+		{"user_lectures_anonymous", "", http.StatusUnauthorized},
+		{"user_lectures_of_another_user", otherUserID.String(), http.StatusForbidden},
+		{"user_lectures_own", userID.String(), http.StatusOK},
+		{"user_lectures_as_admin", adminID.String(), http.StatusOK},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, apiV1Prefix+"/users/"+userID.String()+"/lectures", nil)
+			if tc.caller != "" {
+				req.Header.Set(auth.UserIDHeader, tc.caller)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+		})
+	}
+}
+
+func TestGetMyScheduleHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	user := &models.User{ID: &userID}
+	pastID := uuid.MustParse("318f38ad-76dc-41d9-8ce5-7900559264dd")
+	upcomingID := uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	lectures := []*models.Lecture{
+		{ID: &pastID, Title: "past", Date: time.Now().Add(-24 * time.Hour), Status: models.LectureStatusCompleted},
+		{ID: &upcomingID, Title: "upcoming", Date: time.Now().Add(24 * time.Hour), Status: models.LectureStatusPublished},
+	}
+
+	testTable := []struct {
+		scenario     string
+		userHeader   string
+		inputQuery   string
+		httpCode     int
+		upcomingSize int
+		pastSize     int
+	}{
+		{"my_schedule_anonymous", "", "", http.StatusUnauthorized, 0, 0},
+		{"my_schedule_invalid_user_header", "42", "", http.StatusUnauthorized, 0, 0},
+		{"my_schedule_invalid_from", userID.String(), "?from=yesterday", apperrors.GetUserLecturesServiceErr.HTTPCode, 0, 0},
+		{"my_schedule_POSITIVE", userID.String(), "?from=2020-01-01T00:00:00Z", http.StatusOK, 1, 1},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			usersRepoMock := mock.NewMockUserRepo(ctrl)
+			srv := &server{repoUsers: usersRepoMock, logger: logger.Sugar(), userIDHeader: auth.UserIDHeader, trustedProxies: testGateway}
+
+			req := httptest.NewRequest(http.MethodGet, "/me/schedule"+tc.inputQuery, nil)
+			if tc.userHeader != "" {
+				req.Header.Set(auth.UserIDHeader, tc.userHeader)
+			}
+
+			rec := httptest.NewRecorder()
+
+			usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &userID).Return(user, nil).AnyTimes()
+			usersRepoMock.EXPECT().GetUserLectures(gomock.Any(), user, gomock.Any()).Return(lectures, nil).AnyTimes()
+
+			srv.identifyUser(srv.getMyScheduleHandler()).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			scheduleResp := &responses.GetUserScheduleResponse{}
+			if assert.NoError(t, json.NewDecoder(rec.Body).Decode(scheduleResp)) {
+				assert.Equal(t, userID.String(), scheduleResp.UserId)
+				assert.Len(t, scheduleResp.Upcoming, tc.upcomingSize)
+				assert.Len(t, scheduleResp.Past, tc.pastSize)
+				assert.Equal(t, "upcoming", scheduleResp.Upcoming[0].Title)
+			}
+		})
+	}
+}
+
+func TestUserIDHeaderOnlyTrustedFromGateway(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	user := &models.User{ID: &userID}
+
+	testTable := []struct {
+		scenario   string
+		remoteAddr string
+		forwarded  string
+		httpCode   int
+	}{
+		{"from_gateway", "192.0.2.1:1234", "", http.StatusOK},
+		{"spoofed_by_client", "203.0.113.7:4000", "", http.StatusUnauthorized},
+		{"spoofed_with_forwarded_for", "203.0.113.7:4000", "192.0.2.1", http.StatusUnauthorized},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			usersRepoMock := mock.NewMockUserRepo(ctrl)
+			srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+			srv.trustedProxies = testGateway
+			srv.initializeRoutes()
+
+			if tc.httpCode == http.StatusOK {
+				usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &userID).Return(user, nil).Times(1)
+				usersRepoMock.EXPECT().GetUserLectures(gomock.Any(), user, gomock.Any()).Return(nil, nil).Times(1)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me/schedule", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set(auth.UserIDHeader, userID.String())
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if tc.httpCode != http.StatusOK {
+				assert.Empty(t, req.Header.Get(auth.UserIDHeader))
+			}
+		})
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	testTable := []struct {
+		scenario     string
+		checkErr     error
+		shuttingDown bool
+		httpCode     int
+	}{
+		{"readyz_POSITIVE", nil, false, http.StatusOK},
+		{"readyz_postgres_down", fmt.Errorf("connection refused"), false, http.StatusServiceUnavailable},
+		{"readyz_shutting_down", nil, true, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			srv := &server{logger: logger.Sugar()}
+			srv.addReadinessCheck("postgres", func(ctx context.Context) error { return tc.checkErr })
+			if tc.shuttingDown {
+				srv.markShuttingDown()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rec := httptest.NewRecorder()
+			srv.readinessHandler()(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			healthResp := &responses.HealthResponse{}
+			if assert.NoError(t, json.NewDecoder(rec.Body).Decode(healthResp)) {
+				assert.Equal(t, tc.checkErr == nil, healthResp.Components["postgres"].Status == "ok")
+			}
+
+			rec = httptest.NewRecorder()
+			srv.livenessHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+}
+
+func TestReadinessCheckPassesOnce(t *testing.T) {
+	calls := 0
+	results := []error{fmt.Errorf("migrations pending"), nil, fmt.Errorf("connection refused")}
+	check := passesOnce(func(ctx context.Context) error {
+		calls++
+		return results[calls-1]
+	})
+
+	assert.Error(t, check(context.Background()))
+	assert.NoError(t, check(context.Background()))
+	assert.NoError(t, check(context.Background()))
+	assert.Equal(t, 2, calls)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lectureRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.initializeRoutes()
+
+	lectureRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(nil, apperrors.GetLectureByIDErr.AppendMessage("record not found")).Times(1)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/lectures/c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf/students", nil))
+	assert.Equal(t, apperrors.GetLectureByIDErr.HTTPCode, rec.Code)
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `serviceschool_http_requests_total{method="GET",route="/api/v1/lectures/{lecture_id}/students",status="404"} 1`)
+	assert.NotContains(t, rec.Body.String(), "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	assert.Contains(t, rec.Body.String(), "serviceschool_failed_logins_total 0")
+
+	var disabled *metrics.Metrics
+	rec = httptest.NewRecorder()
+	disabled.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTracePropagation(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	spanRecorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lectureRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.initializeRoutes()
+
+	lectureRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(nil, apperrors.GetLectureByIDErr.AppendMessage("record not found")).Times(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/lectures/c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf/students", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, apperrors.GetLectureByIDErr.HTTPCode, rec.Code)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spanRecorder.Ended() {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		spans[span.Name()] = span
+	}
+
+	serverSpan, ok := spans["GET /api/v1/lectures/{lecture_id}/students"]
+	if assert.True(t, ok) {
+		assert.Equal(t, "00f067aa0ba902b7", serverSpan.Parent().SpanID().String())
+	}
+
+	serviceSpan, ok := spans["LectureService.GetLectureStudents"]
+	if assert.True(t, ok) && serverSpan != nil {
+		assert.Equal(t, serverSpan.SpanContext().SpanID(), serviceSpan.Parent().SpanID())
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lectureRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.initializeRoutes()
+
+	lectureRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).Return(nil, apperrors.GetLectureByIDErr.AppendMessage("record not found")).AnyTimes()
+
+	userID := "5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11"
+	testTable := []struct {
+		scenario   string
+		requestID  string
+		userHeader string
+		loggedUser string
+		httpCode   int
+	}{
+		{scenario: "request id propagated", requestID: "gateway-request-1", userHeader: userID, loggedUser: userID, httpCode: apperrors.GetLectureByIDErr.HTTPCode},
+		{scenario: "request id generated", requestID: "", userHeader: userID, loggedUser: userID, httpCode: apperrors.GetLectureByIDErr.HTTPCode},
+		{scenario: "malformed user id not logged", requestID: "", userHeader: "forged\" admin=\"true", loggedUser: "", httpCode: apperrors.UnauthenticatedErr.HTTPCode},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			logs.TakeAll()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/lectures/c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf/students", nil)
+			req.Header.Set(auth.UserIDHeader, tc.userHeader)
+			if tc.requestID != "" {
+				req.Header.Set(logging.RequestIDHeader, tc.requestID)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			requestID := rec.Header().Get(logging.RequestIDHeader)
+			assert.NotEmpty(t, requestID)
+			if tc.requestID != "" {
+				assert.Equal(t, tc.requestID, requestID)
+			}
+
+			for _, entry := range logs.AllUntimed() {
+				assert.Equal(t, requestID, entry.ContextMap()["request_id"], entry.Message)
+			}
+
+			accessLogs := logs.FilterMessage("access").AllUntimed()
+			if assert.Len(t, accessLogs, 1) {
+				fields := accessLogs[0].ContextMap()
+				assert.Equal(t, http.MethodGet, fields["method"])
+				assert.Equal(t, "/api/v1/lectures/{lecture_id}/students", fields["route"])
+				assert.Equal(t, int64(tc.httpCode), fields["status"])
+				assert.Equal(t, tc.loggedUser, fields["user_id"])
+				assert.Contains(t, fields, "latency")
+			}
+		})
+	}
+}
+
+func TestPasswordsNeverLogged(t *testing.T) {
+	logOutput := &bytes.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(logOutput), zap.DebugLevel)
+	logger := zap.New(core)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	password := "BoBEEEEEEER3"
+	email := "har@name.one"
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	users := []*models.User{{ID: &userID, Email: email, FirstName: "First", LastName: "Last", Role: "student", Password: password}}
+
+	lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lectureRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.initializeRoutes()
+
+	usersRepoMock.EXPECT().CreateUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(userID.String(), nil).Times(1)
+	usersRepoMock.EXPECT().GetUsers(gomock.Any(), gomock.Any()).Return(&models.UsersPage{Users: users}, nil).Times(1)
+
+	createUserRequest := &requests.CreateUserRequest{Email: email, FirstName: "First", LastName: "Last", Password: password, Role: "student"}
+	requestBody, err := json.Marshal(createUserRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(requestBody)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &adminID).Return(&models.User{ID: &adminID, Role: models.RoleAdmin}, nil).Times(1)
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(auth.UserIDHeader, adminID.String())
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	logger.Sugar().Infof("request: %+v, user: %v", createUserRequest, logging.Redacted(users[0]))
+	logger.Info("structured",
+		zap.Any("request", createUserRequest),
+		zap.Any("user", logging.Redacted(users[0])),
+		zap.Any("page", &responses.GetUsersPageResponse{Users: mappers.MapUsersToUserResponses(users)}),
+	)
+
+	assert.Contains(t, logOutput.String(), "[REDACTED]")
+	assert.Contains(t, logOutput.String(), "First")
+	assert.NotContains(t, logOutput.String(), password)
+	assert.NotContains(t, logOutput.String(), email)
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	logger, logLevel, err := logging.New(&config.LoggingConfig{Level: "info", Encoding: logging.EncodingJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.logLevel = &logLevel
+	srv.initializeRoutes()
+
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	studentID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &adminID).Return(&models.User{ID: &adminID, Role: models.RoleAdmin}, nil).AnyTimes()
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &studentID).Return(&models.User{ID: &studentID, Role: models.RoleStudent}, nil).AnyTimes()
+
+	testTable := []struct {
+		scenario      string
+		caller        *uuid.UUID
+		body          string
+		httpCode      int
+		expectedLevel zapcore.Level
+	}{
+		{scenario: "anonymous", caller: nil, body: `{"level":"debug"}`, httpCode: http.StatusUnauthorized, expectedLevel: zap.InfoLevel},
+		{scenario: "student", caller: &studentID, body: `{"level":"debug"}`, httpCode: http.StatusForbidden, expectedLevel: zap.InfoLevel},
+		{scenario: "set debug", caller: &adminID, body: `{"level":"debug"}`, httpCode: http.StatusOK, expectedLevel: zap.DebugLevel},
+		{scenario: "unknown level", caller: &adminID, body: `{"level":"loud"}`, httpCode: http.StatusBadRequest, expectedLevel: zap.DebugLevel},
+		{scenario: "set warn", caller: &adminID, body: `{"level":"warn"}`, httpCode: http.StatusOK, expectedLevel: zap.WarnLevel},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(tc.body))
+			if tc.caller != nil {
+				req.Header.Set(auth.UserIDHeader, tc.caller.String())
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, tc.expectedLevel, logLevel.Level())
+			assert.Equal(t, tc.expectedLevel == zap.DebugLevel, logger.Core().Enabled(zap.DebugLevel))
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+	req.Header.Set(auth.UserIDHeader, adminID.String())
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"warn"}`, rec.Body.String())
+}
+
+func TestAdminRoutesRejectOthers(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	logLevel := zap.NewAtomicLevel()
+	srv.logLevel = &logLevel
+	srv.initializeRoutes()
+
+	studentID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &studentID).Return(&models.User{ID: &studentID, Role: models.RoleStudent}, nil).AnyTimes()
+	pathParam := regexp.MustCompile(`{[^}]+}`)
+
+	for _, operation := range apiOperations {
+		if !operation.admin {
+			continue
+		}
+
+		path := pathParam.ReplaceAllString(operation.path, "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+		t.Run(operation.method+" "+operation.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(operation.method, path, strings.NewReader("{}")))
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, "anonymous")
+
+			req := httptest.NewRequest(operation.method, path, strings.NewReader("{}"))
+			req.Header.Set(auth.UserIDHeader, studentID.String())
+			rec = httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusForbidden, rec.Code, "student")
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = auth.Networks{netip.MustParsePrefix("10.0.0.0/8")}
+	srv.rateLimiter = newRateLimiter(&config.RateLimitConfig{
+		Enabled:             true,
+		DefaultPerMinute:    60,
+		DefaultBurst:        10,
+		SignupPerMinute:     1,
+		SignupBurst:         2,
+		EnrollmentPerMinute: 1,
+		EnrollmentBurst:     1,
+	}, srv.trustedProxies)
+
+	srv.initializeRoutes()
+	usersRepoMock.EXPECT().CreateUser(gomock.Any(), gomock.Any(), gomock.Any()).Return("9ead1870-0962-4f24-ac0b-c1901af0899b", nil).AnyTimes()
+
+	testTable := []struct {
+		scenario      string
+		remoteAddr    string
+		forwardedFor  string
+		userID        string
+		httpCode      int
+		remaining     string
+		hasRetryAfter bool
+	}{
+		{scenario: "first signup", remoteAddr: "192.0.2.1:1234", httpCode: http.StatusCreated, remaining: "1"},
+		{scenario: "second signup", remoteAddr: "192.0.2.1:1234", httpCode: http.StatusCreated, remaining: "0"},
+		{scenario: "burst exhausted", remoteAddr: "192.0.2.1:1234", httpCode: http.StatusTooManyRequests, remaining: "0", hasRetryAfter: true},
+		{scenario: "other client", remoteAddr: "192.0.2.2:1234", httpCode: http.StatusCreated, remaining: "1"},
+		{scenario: "behind trusted proxy", remoteAddr: "10.0.0.1:80", forwardedFor: "198.51.100.7", httpCode: http.StatusCreated, remaining: "1"},
+		{scenario: "spoofed hop is ignored", remoteAddr: "10.0.0.1:80", forwardedFor: "192.0.2.9, 198.51.100.7, 10.0.0.2", httpCode: http.StatusCreated, remaining: "0"},
+		{scenario: "forwarded for untrusted peer is ignored", remoteAddr: "192.0.2.1:1234", forwardedFor: "198.51.100.8", httpCode: http.StatusTooManyRequests, remaining: "0", hasRetryAfter: true},
+		{scenario: "authenticated user has own bucket", remoteAddr: "10.0.0.1:80", userID: "5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11", httpCode: http.StatusCreated, remaining: "1"},
+		{scenario: "spoofed user id from client", remoteAddr: "192.0.2.3:1234", userID: "0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b21", httpCode: http.StatusCreated, remaining: "1"},
+		{scenario: "rotated spoofed user id shares ip bucket", remoteAddr: "192.0.2.3:1234", userID: "0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b22", httpCode: http.StatusCreated, remaining: "0"},
+		{scenario: "rotated spoofed user id is limited", remoteAddr: "192.0.2.3:1234", userID: "0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b23", httpCode: http.StatusTooManyRequests, remaining: "0", hasRetryAfter: true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			requestBody, err := json.Marshal(&requests.CreateUserRequest{Email: "har@name.one", Password: "BoBEEEEEEER3"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(requestBody))
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			if tc.userID != "" {
+				req.Header.Set(auth.UserIDHeader, tc.userID)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, tc.remaining, rec.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, tc.hasRetryAfter, rec.Header().Get("Retry-After") != "")
+			if tc.hasRetryAfter {
+				retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+				assert.NoError(t, err)
+				assert.True(t, retryAfter > 0 && retryAfter <= 60, "Retry-After %d", retryAfter)
+			}
+		})
+	}
+}
+
+func TestCORSAndSecurityHeaders(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), mock.NewMockUserRepo(ctrl), logger.Sugar(), metrics.New())
+	srv.hstsMaxAge = time.Hour
+	srv.cors = newCORSPolicy(&config.CORSConfig{
+		AllowedOrigins:   []string{"https://school.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Idempotency-Key"},
+		ExposedHeaders:   []string{logging.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	srv.initializeRoutes()
+
+	testTable := []struct {
+		scenario      string
+		method        string
+		origin        string
+		preflight     bool
+		httpCode      int
+		allowOrigin   string
+		allowMethods  string
+		exposeHeaders string
+	}{
+		{scenario: "preflight from allowed origin", method: http.MethodOptions, origin: "https://school.example.com", preflight: true, httpCode: http.StatusNoContent, allowOrigin: "https://school.example.com", allowMethods: "GET, POST"},
+		{scenario: "preflight from other origin", method: http.MethodOptions, origin: "https://evil.example.com", preflight: true, httpCode: http.StatusForbidden},
+		{scenario: "request from allowed origin", method: http.MethodGet, origin: "https://school.example.com", httpCode: http.StatusOK, allowOrigin: "https://school.example.com", exposeHeaders: logging.RequestIDHeader},
+		{scenario: "request from other origin", method: http.MethodGet, origin: "https://evil.example.com", httpCode: http.StatusOK},
+		{scenario: "same-origin request", method: http.MethodGet, httpCode: http.StatusOK},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/healthz", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, tc.allowOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tc.allowMethods, rec.Header().Get("Access-Control-Allow-Methods"))
+			assert.Equal(t, tc.exposeHeaders, rec.Header().Get("Access-Control-Expose-Headers"))
+			if tc.allowOrigin != "" {
+				assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+			}
+
+			assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+			assert.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"))
+			assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", rec.Header().Get("Content-Security-Policy"))
+			assert.Equal(t, "max-age=3600; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), mock.NewMockUserRepo(ctrl), logger.Sugar(), metrics.New())
+	srv.maxBodyBytes = 64
+	srv.initializeRoutes()
+
+	testTable := []struct {
+		scenario string
+		body     string
+		httpCode int
+	}{
+		{scenario: "body over the limit", body: `{"email": "` + strings.Repeat("a", 100) + `@name.one", "password": "BoBEEEEEEER3"}`, httpCode: http.StatusRequestEntityTooLarge},
+		{scenario: "unknown field", body: `{"email": "har@name.one", "is_admin": true}`, httpCode: http.StatusBadRequest},
+		{scenario: "malformed json", body: `{"email": `, httpCode: http.StatusBadRequest},
+		{scenario: "second json value", body: `{"email": "har@name.one"}{"role": "admin"}`, httpCode: http.StatusBadRequest},
+		{scenario: "trailing garbage", body: `{"email": "har@name.one"} x`, httpCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), mock.NewMockUserRepo(ctrl), logger.Sugar(), metrics.New())
+	logLevel := zap.NewAtomicLevel()
+	srv.logLevel = &logLevel
+	srv.initializeRoutes()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	spec := struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}{}
+	if err := json.NewDecoder(rec.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, openAPIVersion, spec.OpenAPI)
+
+	registered := map[string]bool{}
+	err = srv.router.(*router).mux.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			// The prefix a subrouter is mounted on.
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+
+		for _, method := range methods {
+			registered[method+" "+path] = true
+			_, documented := spec.Paths[path][strings.ToLower(method)]
+			assert.True(t, documented, "%s %s is missing from the OpenAPI spec", method, path)
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			assert.True(t, registered[strings.ToUpper(method)+" "+path], "%s %s is documented but not routed", strings.ToUpper(method), path)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/openapi.json")
+	assert.Equal(t, swaggerUIPolicy, rec.Header().Get("Content-Security-Policy"))
+}
+
+func TestOpenAPISchemasMatchHandlers(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lectureRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lectureRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	logLevel := zap.NewAtomicLevel()
+	srv.logLevel = &logLevel
+	srv.initializeRoutes()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	spec := struct {
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.NewDecoder(rec.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	userID := uuid.MustParse("9ead1870-0962-4f24-ac0b-c1901af0899b")
+	lectureID := uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	student := &models.User{ID: &userID, Email: "har@name.one", FirstName: "First", LastName: "Last", Role: models.RoleStudent, Locale: "en", Version: 3}
+	lecture := &models.Lecture{ID: &lectureID, Title: "newYear", Speaker: "Santa Claus", Date: time.Now().Add(time.Hour), Duration: 60, Capacity: 10, Status: models.LectureStatusPublished, Version: 1, StudentsCount: 1}
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &adminID).Return(&models.User{ID: &adminID, Role: models.RoleAdmin}, nil).AnyTimes()
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &userID).Return(student, nil).AnyTimes()
+	usersRepoMock.EXPECT().CreateUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(userID.String(), nil).AnyTimes()
+	lectureRepoMock.EXPECT().GetLecturesAndStudentsPP(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.LecturesPage{Lectures: []*models.Lecture{lecture}}, nil).AnyTimes()
+
+	// Responses of real handlers must fit the documented schema.
+	responseTable := []struct {
+		scenario string
+		method   string
+		template string
+		path     string
+		body     string
+		status   int
+	}{
+		{"create_user", http.MethodPost, apiV1Prefix + "/users", apiV1Prefix + "/users", `{"email": "har@name.one", "password": "BoBEEEEEEER3", "first_name": "First", "last_name": "Last"}`, http.StatusCreated},
+		{"get_user", http.MethodGet, apiV1Prefix + "/users/{user_id}", apiV1Prefix + "/users/" + userID.String(), "", http.StatusOK},
+		{"get_lectures", http.MethodGet, apiV1Prefix + "/lectures", apiV1Prefix + "/lectures", "", http.StatusOK},
+		{"get_user_error", http.MethodGet, apiV1Prefix + "/users/{user_id}", apiV1Prefix + "/users/not-a-uuid", "", http.StatusBadRequest},
+		{"healthz", http.MethodGet, "/healthz", "/healthz", "", http.StatusOK},
+		{"log_level", http.MethodGet, "/admin/log-level", "/admin/log-level", "", http.StatusOK},
+	}
+
+	for _, tc := range responseTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set(auth.UserIDHeader, adminID.String())
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if !assert.Equal(t, tc.status, rec.Code, rec.Body.String()) {
+				return
+			}
+
+			var body interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			schema := responseSchema(t, spec.Paths[tc.template][strings.ToLower(tc.method)], tc.status)
+			assert.Empty(t, schemaViolations(schema, spec.Components.Schemas, body, "response"))
+		})
+	}
+
+	// Every documented request field must be accepted by the handler's
+	// decoder. The trailing value makes decoding fail right after the
+	// document, so no handler gets further than that. The log level body is
+	// zap's to decode.
+	for template, operations := range spec.Paths {
+		for method, operation := range operations {
+			requestBody, ok := operation["requestBody"].(map[string]interface{})
+			if !ok || template == "/admin/log-level" {
+				continue
+			}
+
+			t.Run(method+" "+template, func(t *testing.T) {
+				schema := requestBody["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+				sample, err := json.Marshal(sampleFor(schema, spec.Components.Schemas))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				path := regexp.MustCompile(`\{[a-z_]+\}`).ReplaceAllString(template, lectureID.String())
+				req := httptest.NewRequest(strings.ToUpper(method), path, bytes.NewReader(append(sample, []byte(" {}")...)))
+				req.Header.Set(auth.UserIDHeader, adminID.String())
+				req.Header.Set("If-Match", "*")
+				rec := httptest.NewRecorder()
+				srv.ServeHTTP(rec, req)
+
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), "single JSON value", "sample %s", sample)
+			})
+		}
+	}
+}
+
+func responseSchema(t *testing.T, operation map[string]interface{}, status int) map[string]interface{} {
+	response, ok := operation["responses"].(map[string]interface{})[strconv.Itoa(status)].(map[string]interface{})
+	if !assert.True(t, ok, "status %d is not documented", status) {
+		return map[string]interface{}{}
+	}
+
+	return response["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+}
+
+func resolveSchema(schema map[string]interface{}, schemas map[string]interface{}) map[string]interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		return schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+	}
+
+	return schema
+}
+
+// schemaViolations lists where value, as decoded from JSON, doesn't fit schema.
+func schemaViolations(schema map[string]interface{}, schemas map[string]interface{}, value interface{}, path string) []string {
+	schema = resolveSchema(schema, schemas)
+	violations := []string{}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(violations, fmt.Sprintf("%s: %v is not an object", path, value))
+		}
+
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				violations = append(violations, path+"."+name.(string)+": required but missing")
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for name, field := range object {
+			fieldSchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				fieldSchema = additional
+			}
+
+			if fieldSchema == nil {
+				violations = append(violations, path+"."+name+": not in the schema")
+				continue
+			}
+
+			violations = append(violations, schemaViolations(fieldSchema, schemas, field, path+"."+name)...)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(violations, fmt.Sprintf("%s: %v is not an array", path, value))
+		}
+
+		for i, item := range items {
+			violations = append(violations, schemaViolations(schema["items"].(map[string]interface{}), schemas, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			violations = append(violations, fmt.Sprintf("%s: %v is not a string", path, value))
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			violations = append(violations, fmt.Sprintf("%s: %v is not an integer", path, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			violations = append(violations, fmt.Sprintf("%s: %v is not a number", path, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			violations = append(violations, fmt.Sprintf("%s: %v is not a boolean", path, value))
+		}
+	}
+
+	return violations
+}
+
+// sampleFor builds a value of schema with every property set.
+func sampleFor(schema map[string]interface{}, schemas map[string]interface{}) interface{} {
+	schema = resolveSchema(schema, schemas)
+	switch schema["type"] {
+	case "object":
+		object := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range properties {
+			object[name] = sampleFor(property.(map[string]interface{}), schemas)
+		}
+
+		return object
+	case "array":
+		return []interface{}{sampleFor(schema["items"].(map[string]interface{}), schemas)}
+	case "string":
+		return "sample"
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	default:
+		return nil
+	}
+}
+
+func TestSwaggerUIServedLocally(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), mock.NewMockUserRepo(ctrl), logger.Sugar(), metrics.New())
+	srv.swaggerUI = fstest.MapFS{
+		"swagger-ui-bundle.js": {Data: []byte("window.SwaggerUIBundle = function() {};")},
+		"swagger-ui.css":       {Data: []byte("body {}")},
+		"README.md":            {Data: []byte("# Swagger UI assets")},
+	}
+	srv.initializeRoutes()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "https://")
+	assert.NotContains(t, rec.Header().Get("Content-Security-Policy"), "https://")
+
+	testTable := []struct {
+		scenario    string
+		path        string
+		httpCode    int
+		contentType string
+	}{
+		{"script", "/docs/swagger-ui-bundle.js", http.StatusOK, "text/javascript; charset=utf-8"},
+		{"stylesheet", "/docs/swagger-ui.css", http.StatusOK, "text/css; charset=utf-8"},
+		{"other_embedded_file", "/docs/README.md", http.StatusNotFound, "application/json"},
+		{"unknown_asset", "/docs/index.html", http.StatusNotFound, "application/json"},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+// TestSwaggerUIAssetsEmbedded checks the assets the binary actually ships,
+// which `make swagger_ui` fetches. It is skipped until they are committed.
+func TestSwaggerUIAssetsEmbedded(t *testing.T) {
+	for asset := range swaggerUIAssets {
+		if _, err := fs.Stat(swaggerUIFiles, "swagger-ui/"+asset); err != nil {
+			t.Skipf("%s is not committed yet, run make swagger_ui: %v", asset, err)
+		}
+	}
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), mock.NewMockUserRepo(ctrl), logger.Sugar(), metrics.New())
+	srv.initializeRoutes()
+
+	for asset, contentType := range swaggerUIAssets {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/"+asset, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, asset)
+		assert.Equal(t, contentType, rec.Header().Get("Content-Type"), asset)
+		assert.NotEmpty(t, rec.Body.Bytes(), asset)
+	}
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.initializeRoutes()
+
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &adminID).Return(&models.User{ID: &adminID, Role: models.RoleAdmin}, nil).AnyTimes()
+
+	testTable := []struct {
+		scenario   string
+		path       string
+		deprecated bool
+	}{
+		{scenario: "versioned route", path: "/api/v1/lectures/not-a-uuid/status"},
+		{scenario: "legacy alias", path: "/lectures/not-a-uuid/status", deprecated: true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tc.path, strings.NewReader(`{"status": `))
+			req.Header.Set(auth.UserIDHeader, adminID.String())
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			if !tc.deprecated {
+				assert.Empty(t, rec.Header().Get("Deprecation"))
+				assert.Empty(t, rec.Header().Get("Sunset"))
+				return
+			}
+
+			assert.Equal(t, "@"+strconv.FormatInt(legacyRoutesDeprecatedAt.Unix(), 10), rec.Header().Get("Deprecation"))
+			assert.Equal(t, legacyRoutesSunset.Format(http.TimeFormat), rec.Header().Get("Sunset"))
+			assert.Equal(t, `</api/v1/lectures/not-a-uuid/status>; rel="successor-version"`, rec.Header().Get("Link"))
+		})
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lecturesRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	idempotencyRepoMock := mock.NewMockIdempotencyRepo(ctrl)
+	srv := NewServer(lecturesRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.repoIdempotency = idempotencyRepoMock
+	srv.initializeRoutes()
+
+	createLecture := func(title string) string {
+		return `{"title": "` + title + `", "speaker": "Santa Claus", "date": "2024-12-25T08:00:00Z", "location": "Christmas tree", "duration": "60"}`
+	}
+
+	signUp := `{"email": "rudolph@north.pole", "password": "BoBEEEEEEER3"}`
+	userID := "5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11"
+	otherUserID := "0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b21"
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id *uuid.UUID) (*models.User, error) {
+		return &models.User{ID: id, Role: models.RoleAdmin}, nil
+	}).AnyTimes()
+	usersRepoMock.EXPECT().CreateUser(gomock.Any(), gomock.Any(), gomock.Any()).Return("9ead1870-0962-4f24-ac0b-c1901af0899b", nil).Times(1)
+	stored := map[string]*models.IdempotencyKey{
+		userID + "/in-progress": {
+			Key:         "in-progress",
+			Scope:       userID,
+			RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/api/v1/lectures", nil), []byte(createLecture("newYear"))),
+		},
+	}
+	idempotencyRepoMock.EXPECT().Reserve(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+		assert.True(t, key.LockedUntil.After(key.CreatedAt) && key.LockedUntil.Before(key.ExpiresAt), "locked until %v", key.LockedUntil)
+		if existing, ok := stored[key.Scope+"/"+key.Key]; ok {
+			return existing, nil
+		}
+
+		stored[key.Scope+"/"+key.Key] = key
+		return nil, nil
+	}).AnyTimes()
+	idempotencyRepoMock.EXPECT().Complete(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key *models.IdempotencyKey) error {
+		if key.Key == "store-fails" {
+			return apperrors.IdempotencyRepoErr.AppendMessage("connection refused")
+		}
+
+		return nil
+	}).AnyTimes()
+	idempotencyRepoMock.EXPECT().Release(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key *models.IdempotencyKey) error {
+		delete(stored, key.Scope+"/"+key.Key)
+		return nil
+	}).Times(3)
+
+	lectureID := "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf"
+	lecturesRepoMock.EXPECT().CreateLecture(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, lecture *models.Lecture, _ ...*models.OutboxEvent) (string, error) {
+		if lecture.Title == "broken" {
+			return "", &apperrors.AppError{Message: "connection refused", Code: "Lecture_REPO", HTTPCode: http.StatusInternalServerError}
+		}
+
+		return lectureID, nil
+	}).Times(5)
+
+	// Lectures are created by admins; anonymous keys are exercised on sign-up.
+	testTable := []struct {
+		scenario string
+		path     string
+		key      string
+		userID   string
+		body     string
+		httpCode int
+		replayed bool
+	}{
+		{scenario: "first request", key: "key-1", userID: userID, body: createLecture("newYear"), httpCode: http.StatusCreated},
+		{scenario: "retry is replayed", key: "key-1", userID: userID, body: createLecture("newYear"), httpCode: http.StatusCreated, replayed: true},
+		{scenario: "key reused for another body", key: "key-1", userID: userID, body: createLecture("easter"), httpCode: http.StatusUnprocessableEntity},
+		{scenario: "same key of another user", key: "key-1", userID: otherUserID, body: createLecture("newYear"), httpCode: http.StatusCreated},
+		{scenario: "request still in progress", key: "in-progress", userID: userID, body: createLecture("newYear"), httpCode: http.StatusConflict},
+		{scenario: "server error isn't stored", key: "key-2", userID: userID, body: createLecture("broken"), httpCode: http.StatusInternalServerError},
+		{scenario: "failing to store frees the key", key: "store-fails", userID: userID, body: createLecture("newYear"), httpCode: http.StatusCreated},
+		{scenario: "retry after failing to store runs again", key: "store-fails", userID: userID, body: createLecture("newYear"), httpCode: http.StatusCreated},
+		{scenario: "anonymous request apart from users", path: "/api/v1/users", key: "key-1", body: signUp, httpCode: http.StatusCreated},
+		{scenario: "anonymous retry is replayed", path: "/api/v1/users", key: "key-1", body: signUp, httpCode: http.StatusCreated, replayed: true},
+		{scenario: "key too long", key: strings.Repeat("k", maxIdempotencyKeyLength+1), userID: userID, body: createLecture("newYear"), httpCode: http.StatusBadRequest},
+	}
+
+	var firstBody, anonymousBody string
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			path := "/api/v1/lectures"
+			if tc.path != "" {
+				path = tc.path
+			}
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tc.body))
+			req.Header.Set(idempotencyKeyHeader, tc.key)
+			if tc.userID != "" {
+				req.Header.Set(auth.UserIDHeader, tc.userID)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, tc.replayed, rec.Header().Get(idempotentReplayedHeader) == "true")
+			switch tc.scenario {
+			case "first request":
+				firstBody = rec.Body.String()
+				assert.Contains(t, firstBody, lectureID)
+			case "anonymous request apart from users":
+				anonymousBody = rec.Body.String()
+			}
+
+			if tc.replayed {
+				want := firstBody
+				if tc.userID == "" {
+					want = anonymousBody
+				}
+
+				assert.Equal(t, want, rec.Body.String())
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	for _, key := range []string{"key-2", "store-fails"} {
+		_, stillReserved := stored[userID+"/"+key]
+		assert.False(t, stillReserved, key)
+	}
+
+	_, anonymous := stored[anonymousIdempotency+"/key-1"]
+	assert.True(t, anonymous)
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lecturesRepoMock := mock.NewMockRepoLecture(ctrl)
+	usersRepoMock := mock.NewMockUserRepo(ctrl)
+	srv := NewServer(lecturesRepoMock, usersRepoMock, logger.Sugar(), metrics.New())
+	srv.trustedProxies = testGateway
+	srv.templates, err = notifications.LoadTemplates("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.initializeRoutes()
+
+	lectureID := "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf"
+	busyLectureID := "9b2d4f6a-8c0e-4a2b-9d4f-6a8c0e2a4b6d"
+	userID := "5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11"
+	userUUID := uuid.MustParse(userID)
+	otherUserID := "0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b21"
+	otherUserUUID := uuid.MustParse(otherUserID)
+	adminID := "0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d"
+	adminUUID := uuid.MustParse(adminID)
+
+	lecturesRepoMock.EXPECT().GetLectureByID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id *uuid.UUID) (*models.Lecture, error) {
+		return &models.Lecture{ID: id, Title: "newYear", Date: time.Date(2024, 12, 25, 8, 0, 0, 0, time.UTC), Duration: 60, Status: models.LectureStatusDraft, Version: 3}, nil
+	}).AnyTimes()
+	// A concurrent edit lands between the read and the write of the "race" update.
+	lecturesRepoMock.EXPECT().UpdateLecture(gomock.Any(), gomock.Any(), outboxEvent(models.EventLectureUpdated)).DoAndReturn(func(_ context.Context, lecture *models.Lecture, _ ...*models.OutboxEvent) error {
+		if lecture.Title == "race" {
+			return apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID)
+		}
+
+		lecture.Version++
+		return nil
+	}).Times(2)
+	lecturesRepoMock.EXPECT().DeleteLecture(gomock.Any(), gomock.Any(), outboxEvent(models.EventLectureDeleted)).DoAndReturn(func(_ context.Context, lecture *models.Lecture, _ ...*models.OutboxEvent) error {
+		if lecture.ID.String() == busyLectureID {
+			return apperrors.LectureHasStudentsErr.AppendMessage("students:", 1)
+		}
+
+		return nil
+	}).Times(2)
+	usersRepoMock.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id *uuid.UUID) (*models.User, error) {
+		switch *id {
+		case adminUUID:
+			return &models.User{ID: &adminUUID, Role: models.RoleAdmin, Version: 1}, nil
+		case otherUserUUID:
+			return &models.User{ID: &otherUserUUID, Role: models.RoleStudent, Version: 1}, nil
+		}
+
+		return &models.User{ID: &userUUID, FirstName: "Santa", LastName: "Claus", Role: models.RoleStudent, Version: 1}, nil
+	}).AnyTimes()
+	usersRepoMock.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user *models.User) error {
+		user.Version++
+		return nil
+	}).Times(4)
+	usersRepoMock.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	testTable := []struct {
+		scenario    string
+		method      string
+		path        string
+		caller      string
+		ifMatch     string
+		ifNoneMatch string
+		body        string
+		httpCode    int
+		etag        string
+	}{
+		{scenario: "get lecture", method: http.MethodGet, path: "/api/v1/lectures/" + lectureID, httpCode: http.StatusOK, etag: `"3"`},
+		{scenario: "get lecture not modified", method: http.MethodGet, path: "/api/v1/lectures/" + lectureID, ifNoneMatch: `W/"3"`, httpCode: http.StatusNotModified, etag: `"3"`},
+		{scenario: "get lecture modified", method: http.MethodGet, path: "/api/v1/lectures/" + lectureID, ifNoneMatch: `"2"`, httpCode: http.StatusOK, etag: `"3"`},
+		{scenario: "update lecture anonymous", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, ifMatch: `"3"`, body: `{"title": "easter"}`, httpCode: http.StatusUnauthorized},
+		{scenario: "update lecture as student", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: userID, ifMatch: `"3"`, body: `{"title": "easter"}`, httpCode: http.StatusForbidden},
+		{scenario: "update lecture without If-Match", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: adminID, body: `{"title": "easter"}`, httpCode: http.StatusPreconditionRequired},
+		{scenario: "update lecture stale version", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: adminID, ifMatch: `"2"`, body: `{"title": "easter"}`, httpCode: http.StatusPreconditionFailed},
+		{scenario: "update lecture weak tag never matches", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: adminID, ifMatch: `W/"3"`, body: `{"title": "easter"}`, httpCode: http.StatusPreconditionFailed},
+		{scenario: "update lecture lost race", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: adminID, ifMatch: `"3"`, body: `{"title": "race"}`, httpCode: http.StatusPreconditionFailed},
+		{scenario: "update lecture invalid field", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: adminID, ifMatch: `"3"`, body: `{"capacity": "-1"}`, httpCode: http.StatusBadRequest},
+		{scenario: "update lecture", method: http.MethodPatch, path: "/api/v1/lectures/" + lectureID, caller: adminID, ifMatch: `"2", "3"`, body: `{"title": "easter"}`, httpCode: http.StatusOK, etag: `"4"`},
+		{scenario: "delete lecture as student", method: http.MethodDelete, path: "/api/v1/lectures/" + lectureID, caller: userID, ifMatch: "*", httpCode: http.StatusForbidden},
+		{scenario: "delete lecture without If-Match", method: http.MethodDelete, path: "/api/v1/lectures/" + lectureID, caller: adminID, httpCode: http.StatusPreconditionRequired},
+		{scenario: "delete lecture with students", method: http.MethodDelete, path: "/api/v1/lectures/" + busyLectureID, caller: adminID, ifMatch: "*", httpCode: http.StatusConflict},
+		{scenario: "delete lecture", method: http.MethodDelete, path: "/api/v1/lectures/" + lectureID, caller: adminID, ifMatch: "*", httpCode: http.StatusNoContent},
+		{scenario: "get user anonymous", method: http.MethodGet, path: "/api/v1/users/" + userID, httpCode: http.StatusUnauthorized},
+		{scenario: "get another user", method: http.MethodGet, path: "/api/v1/users/" + userID, caller: otherUserID, httpCode: http.StatusForbidden},
+		{scenario: "get user", method: http.MethodGet, path: "/api/v1/users/" + userID, caller: userID, httpCode: http.StatusOK, etag: `"1"`},
+		{scenario: "admin gets user", method: http.MethodGet, path: "/api/v1/users/" + userID, caller: adminID, httpCode: http.StatusOK, etag: `"1"`},
+		{scenario: "update user anonymous", method: http.MethodPatch, path: "/api/v1/users/" + userID, ifMatch: `"1"`, body: `{"first_name": "Father"}`, httpCode: http.StatusUnauthorized},
+		{scenario: "update another user", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: otherUserID, ifMatch: `"1"`, body: `{"first_name": "Father"}`, httpCode: http.StatusForbidden},
+		{scenario: "update own role", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: userID, ifMatch: `"1"`, body: `{"role": "admin"}`, httpCode: http.StatusForbidden},
+		{scenario: "update user stale version", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: userID, ifMatch: `"7"`, body: `{"first_name": "Father"}`, httpCode: http.StatusPreconditionFailed},
+		{scenario: "update own user", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: userID, ifMatch: `"1"`, body: `{"first_name": "Father"}`, httpCode: http.StatusOK, etag: `"2"`},
+		{scenario: "update own unknown locale", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: userID, ifMatch: `"1"`, body: `{"locale": "xx"}`, httpCode: http.StatusBadRequest},
+		{scenario: "update own regional locale", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: userID, ifMatch: `"1"`, body: `{"locale": "ru-RU"}`, httpCode: http.StatusOK, etag: `"2"`},
+		{scenario: "admin updates user", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: adminID, ifMatch: `"1"`, body: `{"last_name": "Christmas"}`, httpCode: http.StatusOK, etag: `"2"`},
+		{scenario: "admin changes role", method: http.MethodPatch, path: "/api/v1/users/" + userID, caller: adminID, ifMatch: `"1"`, body: `{"role": "admin"}`, httpCode: http.StatusOK, etag: `"2"`},
+		{scenario: "delete own user", method: http.MethodDelete, path: "/api/v1/users/" + userID, caller: userID, ifMatch: `"1"`, httpCode: http.StatusForbidden},
+		{scenario: "delete user stale version", method: http.MethodDelete, path: "/api/v1/users/" + userID, caller: adminID, ifMatch: `"0"`, httpCode: http.StatusPreconditionFailed},
+		{scenario: "delete user", method: http.MethodDelete, path: "/api/v1/users/" + userID, caller: adminID, ifMatch: `"1"`, httpCode: http.StatusNoContent},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+
+			if tc.caller != "" {
+				req.Header.Set(auth.UserIDHeader, tc.caller)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			assert.Equal(t, tc.etag, rec.Header().Get("ETag"))
+			if rec.Code == http.StatusNotModified || rec.Code == http.StatusNoContent {
+				assert.Empty(t, rec.Body.String())
+				return
+			}
+
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			body := map[string]interface{}{}
+			if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body)) {
+				assert.Equal(t, strings.Trim(tc.etag, `"`), fmt.Sprint(body["version"]))
+			}
+		})
+	}
+}
+
+func TestGetAuditEventsHandler(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	eventID := uuid.MustParse("2f1c7b7e-5d0b-4c43-9b1e-7c7f7f0a9d11")
+	actorID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	lectureID := "c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf"
+	createdAt := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	eventsPage := &models.AuditEventsPage{
+		Events: []*models.AuditEvent{{
+			ID:         &eventID,
+			CreatedAt:  createdAt,
+			ActorID:    &actorID,
+			Action:     models.AuditActionLectureUpdated,
+			EntityType: models.EntityLecture,
+			EntityID:   lectureID,
+			Before:     []byte(`{"title": "newYear"}`),
+			After:      []byte(`{"title": "easter"}`),
+			RequestID:  "req-1",
+		}},
+		PageInfo: models.PageInfo{NextCursor: "next"},
+	}
+
+	getAuditEventsResp := &responses.GetAuditEventsPageResponse{
+		Events: []*responses.AuditEventResp{{
+			ID:         eventID.String(),
+			CreatedAt:  createdAt.Format(time.RFC3339Nano),
+			ActorId:    actorID.String(),
+			Action:     models.AuditActionLectureUpdated,
+			EntityType: models.EntityLecture,
+			EntityId:   lectureID,
+			Before:     map[string]interface{}{"title": "newYear"},
+			After:      map[string]interface{}{"title": "easter"},
+			RequestId:  "req-1",
+		}},
+		PerPage:    models.DefaultPerPage,
+		NextCursor: "next",
+	}
+
+	testTable := []struct {
+		scenario    string
+		inputQuery  string
+		expectedErr error
+		filter      *models.AuditFilter
+		response    *responses.GetAuditEventsPageResponse
+		httpCode    int
+	}{
+		{
+			scenario:   "get_audit_unknown_entity",
+			inputQuery: "?entity=speaker",
+			httpCode:   apperrors.GetAuditEventsServiceErr.HTTPCode,
+		},
+		{
+			scenario:   "get_audit_invalid_actor",
+			inputQuery: "?actor=santa",
+			httpCode:   apperrors.GetAuditEventsServiceErr.HTTPCode,
+		},
+		{
+			scenario:   "get_audit_invalid_from",
+			inputQuery: "?from=yesterday",
+			httpCode:   apperrors.GetAuditEventsServiceErr.HTTPCode,
+		},
+		{
+			scenario:    "get_audit_repo_err",
+			inputQuery:  "",
+			expectedErr: apperrors.AuditRepoErr.AppendMessage("connection refused"),
+			filter:      &models.AuditFilter{},
+			httpCode:    apperrors.AuditRepoErr.HTTPCode,
+		},
+		{
+			scenario:   "get_audit_POSITIVE",
+			inputQuery: "?entity=lecture&entity_id=" + lectureID + "&actor=" + actorID.String() + "&from=2024-12-01T00:00:00Z&to=2024-12-02T00:00:00Z",
+			filter: &models.AuditFilter{
+				EntityType: models.EntityLecture,
+				EntityID:   lectureID,
+				ActorID:    &actorID,
+				From:       func() *time.Time { from := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC); return &from }(),
+				To:         func() *time.Time { to := time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC); return &to }(),
+			},
+			response: getAuditEventsResp,
+			httpCode: http.StatusOK,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			auditRepoMock := mock.NewMockAuditRepo(ctrl)
+			srv := &server{repoAudit: auditRepoMock, logger: logger.Sugar()}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit"+tc.inputQuery, nil)
+			rec := httptest.NewRecorder()
+
+			if tc.filter != nil {
+				auditRepoMock.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), tc.filter).Return(eventsPage, tc.expectedErr).Times(1)
+			}
+
+			getAuditEvents := srv.getAuditEventsHandler()
+			getAuditEvents(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			marshalledResponse, err := json.Marshal(tc.response)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(marshalledResponse), strings.TrimSuffix(rec.Body.String(), "\n"))
+			}
+		})
+	}
+}
+
+func TestUserCreatedEventOmitsEmail(t *testing.T) {
+	userID := uuid.MustParse("0b8f6f3e-2d4c-4a8e-9b1f-5c7d9e0a1b21")
+	userCreated, err := mappers.MapUserToUserCreatedEvent(&models.User{ID: &userID, Email: "santa@example.com", FirstName: "Santa", Role: models.RoleStudent})
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(userCreated.Payload), "santa@example.com")
+	}
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	webhookID := uuid.MustParse("2f1c7b7e-5d0b-4c43-9b1e-7c7f7f0a9d11")
+	deliveryID := uuid.MustParse("8d3e2a61-0b7c-4f5e-a1d2-3c4b5a697e80")
+	eventID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	createdAt := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	subscription := &models.WebhookSubscription{
+		ID:         &webhookID,
+		URL:        "https://partner.example/hooks",
+		EventTypes: []byte(`["LectureUpdated", "StudentEnrolled"]`),
+		Secret:     "0123456789abcdef",
+		CreatedAt:  createdAt,
+	}
+	deadDelivery := &models.WebhookDelivery{
+		ID:             &deliveryID,
+		SubscriptionID: &webhookID,
+		EventID:        &eventID,
+		EventType:      models.EventStudentEnrolled,
+		Status:         models.WebhookDeliveryDead,
+		Attempts:       10,
+		LastError:      "503 Service Unavailable",
+		CreatedAt:      createdAt,
+		AttemptLog:     []*models.WebhookDeliveryAttempt{{Attempt: 10, AttemptedAt: createdAt, StatusCode: http.StatusServiceUnavailable, Error: "503 Service Unavailable", DurationMs: 12}},
+	}
+
+	testTable := []struct {
+		scenario string
+		method   string
+		path     string
+		body     string
+		prepare  func(webhookRepoMock *mock.MockWebhookRepo)
+		response string
+		httpCode int
+	}{
+		{
+			scenario: "create_webhook_invalid_url",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "ftp://partner.example", "event_types": ["LectureUpdated"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_private_address",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "http://10.0.0.5/hooks", "event_types": ["LectureUpdated"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_metadata_service",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "http://169.254.169.254/latest/meta-data", "event_types": ["LectureUpdated"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_host_resolving_to_loopback",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "http://internal.example:8080/hooks", "event_types": ["LectureUpdated"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_unresolvable_host",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "https://nowhere.example/hooks", "event_types": ["LectureUpdated"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_short_secret",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "https://partner.example/hooks", "event_types": ["LectureUpdated"], "secret": "hunter2"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_unknown_event_type",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "https://partner.example/hooks", "event_types": ["LectureRenamed"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_unknown_field",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "https://partner.example/hooks", "events": ["LectureUpdated"], "secret": "0123456789abcdef"}`,
+			httpCode: apperrors.CreateWebhookHandlerErr.HTTPCode,
+		},
+		{
+			scenario: "create_webhook_POSITIVE",
+			method:   http.MethodPost,
+			path:     "/admin/webhooks",
+			body:     `{"url": "https://partner.example/hooks", "event_types": ["StudentEnrolled", "LectureUpdated", "StudentEnrolled"], "secret": "0123456789abcdef"}`,
+			prepare: func(webhookRepoMock *mock.MockWebhookRepo) {
+				webhookRepoMock.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, created *models.WebhookSubscription) error {
+					assert.JSONEq(t, `["LectureUpdated", "StudentEnrolled"]`, string(created.EventTypes))
+					assert.Equal(t, "0123456789abcdef", created.Secret)
+					created.ID, created.CreatedAt = &webhookID, createdAt
+					return nil
+				}).Times(1)
+			},
+			response: `{"webhook_id":"` + webhookID.String() + `","url":"https://partner.example/hooks","event_types":["LectureUpdated","StudentEnrolled"],"created_at":"2024-12-01T10:00:00Z"}`,
+			httpCode: http.StatusCreated,
+		},
+		{
+			scenario: "get_webhooks_POSITIVE",
+			method:   http.MethodGet,
+			path:     "/admin/webhooks",
+			prepare: func(webhookRepoMock *mock.MockWebhookRepo) {
+				webhookRepoMock.EXPECT().GetWebhookSubscriptions(gomock.Any()).Return([]*models.WebhookSubscription{subscription}, nil).Times(1)
+			},
+			response: `{"webhooks":[{"webhook_id":"` + webhookID.String() + `","url":"https://partner.example/hooks","event_types":["LectureUpdated","StudentEnrolled"],"created_at":"2024-12-01T10:00:00Z"}]}`,
+			httpCode: http.StatusOK,
+		},
+		{
+			scenario: "delete_webhook_not_found",
+			method:   http.MethodDelete,
+			path:     "/admin/webhooks/" + webhookID.String(),
+			prepare: func(webhookRepoMock *mock.MockWebhookRepo) {
+				webhookRepoMock.EXPECT().DeleteWebhookSubscription(gomock.Any(), &webhookID).Return(apperrors.WebhookSubscriptionNotFoundErr.AppendMessage(webhookID)).Times(1)
+			},
+			httpCode: apperrors.WebhookSubscriptionNotFoundErr.HTTPCode,
+		},
+		{
+			scenario: "delete_webhook_POSITIVE",
+			method:   http.MethodDelete,
+			path:     "/admin/webhooks/" + webhookID.String(),
+			prepare: func(webhookRepoMock *mock.MockWebhookRepo) {
+				webhookRepoMock.EXPECT().DeleteWebhookSubscription(gomock.Any(), &webhookID).Return(nil).Times(1)
+			},
+			httpCode: http.StatusNoContent,
+		},
+		{
+			scenario: "get_deliveries_unknown_status",
+			method:   http.MethodGet,
+			path:     "/admin/webhooks/" + webhookID.String() + "/deliveries?status=lost",
+			httpCode: apperrors.WebhookServiceErr.HTTPCode,
+		},
+		{
+			scenario: "get_deliveries_POSITIVE",
+			method:   http.MethodGet,
+			path:     "/admin/webhooks/" + webhookID.String() + "/deliveries?status=dead",
+			prepare: func(webhookRepoMock *mock.MockWebhookRepo) {
+				filter := &models.WebhookDeliveryFilter{SubscriptionID: &webhookID, Status: models.WebhookDeliveryDead}
+				webhookRepoMock.EXPECT().GetWebhookDeliveries(gomock.Any(), gomock.Any(), filter).Return(&models.WebhookDeliveriesPage{Deliveries: []*models.WebhookDelivery{deadDelivery}}, nil).Times(1)
+			},
+			response: `{"deliveries":[{"delivery_id":"` + deliveryID.String() + `","webhook_id":"` + webhookID.String() + `","event_id":"` + eventID.String() + `","event_type":"StudentEnrolled","status":"dead","attempts":10,"last_error":"503 Service Unavailable","created_at":"2024-12-01T10:00:00Z","attempt_log":[{"attempt":10,"attempted_at":"2024-12-01T10:00:00Z","status_code":503,"error":"503 Service Unavailable","duration_ms":12}]}],"per_page":10}`,
+			httpCode: http.StatusOK,
+		},
+		{
+			scenario: "retry_delivery_not_dead",
+			method:   http.MethodPost,
+			path:     "/admin/webhook-deliveries/" + deliveryID.String() + "/retry",
+			prepare: func(webhookRepoMock *mock.MockWebhookRepo) {
+				webhookRepoMock.EXPECT().RetryWebhookDelivery(gomock.Any(), &deliveryID, gomock.Any()).Return(nil, apperrors.WebhookDeliveryNotDeadErr.AppendMessage(deliveryID)).Times(1)
+			},
+			httpCode: apperrors.WebhookDeliveryNotDeadErr.HTTPCode,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	adminID := uuid.MustParse("0d7c5b3a-1e2f-4a6b-8c9d-0e1f2a3b4c5d")
+	addresses := &webhooks.Addresses{Lookup: func(_ context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "partner.example":
+			return []netip.Addr{netip.MustParseAddr("203.0.113.10")}, nil
+		case "internal.example":
+			return []netip.Addr{netip.MustParseAddr("203.0.113.11"), netip.MustParseAddr("127.0.0.1")}, nil
+		}
+
+		return nil, errors.New("no such host")
+	}}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			webhookRepoMock := mock.NewMockWebhookRepo(ctrl)
+			usersRepoMock := mock.NewMockUserRepo(ctrl)
+			usersRepoMock.EXPECT().GetUserByID(gomock.Any(), &adminID).Return(&models.User{ID: &adminID, Role: models.RoleAdmin}, nil).AnyTimes()
+			srv := NewServer(mock.NewMockRepoLecture(ctrl), usersRepoMock, logger.Sugar(), metrics.New())
+			srv.repoWebhooks = webhookRepoMock
+			srv.webhookAddresses = addresses
+			srv.trustedProxies = testGateway
+			srv.initializeRoutes()
+			if tc.prepare != nil {
+				tc.prepare(webhookRepoMock)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set(auth.UserIDHeader, adminID.String())
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.httpCode, rec.Code)
+			if tc.response != "" {
+				assert.Equal(t, tc.response, strings.TrimSuffix(rec.Body.String(), "\n"))
+			}
+		})
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const secret = "0123456789abcdef"
+	var received []outbox.Message
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if err := webhooks.Verify(secret, r.Header.Get(webhooks.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		message := outbox.Message{}
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, message.ID, r.Header.Get(outbox.EventIDHeader))
+		assert.NotEmpty(t, r.Header.Get(webhooks.DeliveryIDHeader))
+		received = append(received, message)
+		if message.Type == models.EventStudentDropped {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	lectureID := uuid.MustParse("c616fed8-e6d2-45f5-80e5-d2eacfd8e4bf")
+	studentID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	enrolled, err := mappers.MapEnrollmentToStudentEnrolledEvent(&models.Lecture{ID: &lectureID}, &models.User{ID: &studentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := mappers.MapEnrollmentToStudentDroppedEvent(&models.Lecture{ID: &lectureID}, &models.User{ID: &studentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dispatcher queues the event as the exact body the deliverer signs.
+	webhookRepoMock := mock.NewMockWebhookRepo(ctrl)
+	var enrolledBody []byte
+	webhookRepoMock.EXPECT().EnqueueWebhookDeliveries(gomock.Any(), enrolled, gomock.Any()).DoAndReturn(func(_ context.Context, _ *models.OutboxEvent, body []byte) (int64, error) {
+		enrolledBody = body
+		return 1, nil
+	}).Times(1)
+	assert.NoError(t, webhooks.NewDispatcher(webhookRepoMock).Publish(context.Background(), enrolled))
+	droppedBody, err := json.Marshal(outbox.NewMessage(dropped))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription := &models.WebhookSubscription{URL: receiver.URL, Secret: secret}
+	delivery := func(event *models.OutboxEvent, body []byte, attempts int) *models.WebhookDelivery {
+		id := uuid.New()
+		return &models.WebhookDelivery{ID: &id, Subscription: subscription, EventID: event.ID, EventType: event.Type, Body: body, Status: models.WebhookDeliveryPending, Attempts: attempts}
+	}
+
+	cfg := config.Default().Webhooks
+	cfg.AllowPrivateNetworks = true
+	delivered := delivery(enrolled, enrolledBody, 0)
+	retried := delivery(dropped, droppedBody, 2)
+	dead := delivery(dropped, droppedBody, cfg.MaxAttempts-1)
+	retriedByHand := delivery(dropped, droppedBody, cfg.MaxAttempts)
+	retriedByHand.AttemptsBeforeRetry = cfg.MaxAttempts
+	webhookRepoMock.EXPECT().ClaimWebhookDeliveries(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return([]*models.WebhookDelivery{delivered, retried, dead, retriedByHand}, nil).Times(1)
+
+	start := time.Now()
+	attempts := map[*models.WebhookDelivery]*models.WebhookDeliveryAttempt{}
+	webhookRepoMock.EXPECT().RecordWebhookDeliveryAttempt(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+		attempts[delivery] = attempt
+		return nil
+	}).Times(4)
+
+	claimed, err := webhooks.NewDeliverer(webhookRepoMock, cfg, logger.Sugar()).DeliverOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, claimed)
+	if assert.Len(t, received, 4) {
+		assert.Equal(t, enrolled.ID.String(), received[0].ID)
+		assert.JSONEq(t, `{"lecture_id": "`+lectureID.String()+`", "student_id": "`+studentID.String()+`"}`, string(received[0].Payload))
+	}
+
+	assert.Equal(t, models.WebhookDeliveryDelivered, delivered.Status)
+	assert.NotNil(t, delivered.DeliveredAt)
+	assert.Equal(t, http.StatusNoContent, attempts[delivered].StatusCode)
+	assert.Equal(t, 1, attempts[delivered].Attempt)
+
+	// The third failure waits two doublings of the base backoff.
+	assert.Equal(t, models.WebhookDeliveryPending, retried.Status)
+	assert.Equal(t, 3, retried.Attempts)
+	assert.WithinDuration(t, start.Add(4*cfg.RetryBackoff), retried.NextAttemptAt, time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[retried].StatusCode)
+	assert.Contains(t, attempts[retried].Error, "503")
+
+	assert.Equal(t, models.WebhookDeliveryDead, dead.Status)
+	assert.Equal(t, cfg.MaxAttempts, dead.Attempts)
+	assert.Contains(t, dead.LastError, "503")
+
+	// A delivery retried by hand numbers its attempts on and starts over with
+	// the backoff and the attempt limit.
+	assert.Equal(t, models.WebhookDeliveryPending, retriedByHand.Status)
+	assert.Equal(t, cfg.MaxAttempts+1, attempts[retriedByHand].Attempt)
+	assert.WithinDuration(t, start.Add(cfg.RetryBackoff), retriedByHand.NextAttemptAt, time.Second)
+
+	signature := webhooks.Sign(secret, start, enrolledBody)
+	assert.NoError(t, webhooks.Verify(secret, signature, enrolledBody, start, time.Minute))
+	assert.Error(t, webhooks.Verify("another-secret-entirely", signature, enrolledBody, start, time.Minute))
+	assert.Error(t, webhooks.Verify(secret, signature, droppedBody, start, time.Minute))
+	assert.Error(t, webhooks.Verify(secret, signature, enrolledBody, start.Add(time.Hour), time.Minute))
+}
+
+func TestWebhookDeliveryGuards(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer logger.Sync()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	redirectedTo := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectedTo++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+
+	redirecting := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirecting.Close()
+
+	eventID := uuid.MustParse("5a1b0a4e-8c3f-4b4e-9d6a-0e7a6f5b2c11")
+	delivery := func(url string) *models.WebhookDelivery {
+		id := uuid.New()
+		return &models.WebhookDelivery{ID: &id, Subscription: &models.WebhookSubscription{URL: url, Secret: "0123456789abcdef"}, EventID: &eventID, EventType: models.EventLectureUpdated, Body: []byte(`{}`), Status: models.WebhookDeliveryPending}
+	}
+
+	testTable := []struct {
+		scenario     string
+		allowPrivate bool
+		url          string
+		recordErr    error
+		statusCode   int
+		attemptErr   string
+		expectedErr  *apperrors.AppError
+	}{
+		{scenario: "private address refused at dial time", url: target.URL, attemptErr: "not a public address"},
+		{scenario: "redirect not followed", allowPrivate: true, url: redirecting.URL, statusCode: http.StatusFound, attemptErr: "302"},
+		{scenario: "failing to record the attempt", allowPrivate: true, url: target.URL, statusCode: http.StatusNoContent, recordErr: apperrors.WebhookRepoErr.AppendMessage("connection refused"), expectedErr: &apperrors.WebhookRepoErr},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			cfg := config.Default().Webhooks
+			cfg.AllowPrivateNetworks = tc.allowPrivate
+			webhookRepoMock := mock.NewMockWebhookRepo(ctrl)
+			webhookRepoMock.EXPECT().ClaimWebhookDeliveries(gomock.Any(), gomock.Any(), cfg.BatchSize, cfg.Lease).Return([]*models.WebhookDelivery{delivery(tc.url)}, nil).Times(1)
+			var recorded *models.WebhookDeliveryAttempt
+			webhookRepoMock.EXPECT().RecordWebhookDeliveryAttempt(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+				recorded = attempt
+				return tc.recordErr
+			}).Times(1)
+
+			_, err := webhooks.NewDeliverer(webhookRepoMock, cfg, logger.Sugar()).DeliverOnce(context.Background())
+			if tc.expectedErr != nil {
+				assert.True(t, apperrors.IsAppError(err, tc.expectedErr))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if assert.NotNil(t, recorded) {
+				assert.Equal(t, tc.statusCode, recorded.StatusCode)
+				if tc.attemptErr != "" {
+					assert.Contains(t, recorded.Error, tc.attemptErr)
+				}
+			}
+		})
+	}
+
+	assert.Equal(t, 1, redirectedTo, "only the direct delivery reaches the target")
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/mappers"
+	"web_service/internal/domain/requests"
+	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
+	"web_service/internal/repositories"
+
+	"go.uber.org/zap"
+)
+
+type AuditService struct {
+	auditRepo repositories.AuditRepo
+	logger    *zap.SugaredLogger
+}
+
+func NewAuditService(auditRepo repositories.AuditRepo, logger *zap.SugaredLogger) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+// GetAuditEvents lists the audit log, newest first.
+func (service *AuditService) GetAuditEvents(ctx context.Context, getAuditEventsRequest *requests.GetAuditEventsRequest) (*responses.GetAuditEventsPageResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.GetAuditEvents")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	filter, err := mappers.MapGetAuditEventsRequestToAuditFilter(getAuditEventsRequest)
+	if err != nil {
+		appErr := apperrors.GetAuditEventsServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	pageRequest, err := parsePageRequest(getAuditEventsRequest.Page, getAuditEventsRequest.PerPage, getAuditEventsRequest.Cursor, getAuditEventsRequest.IncludeTotal)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	eventsPage, err := service.auditRepo.GetAuditEvents(ctx, pageRequest, filter)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	events, err := mappers.MapAuditEventsToAuditEventResponses(eventsPage.Events)
+	if err != nil {
+		appErr := apperrors.AuditRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return &responses.GetAuditEventsPageResponse{
+		Events:     events,
+		PerPage:    pageRequest.PerPage,
+		NextCursor: eventsPage.NextCursor,
+		PrevCursor: eventsPage.PrevCursor,
+		TotalCount: eventsPage.TotalCount,
+	}, nil
+}
@@ -2,18 +2,22 @@ package services
 
 import (
 	"context"
-	"strconv"
+	"time"
 	"web_service/internal/apperrors"
 	"web_service/internal/domain/mappers"
 	"web_service/internal/domain/models"
 	"web_service/internal/domain/requests"
 	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
 	"web_service/internal/repositories"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 )
 
+var tracer = otel.Tracer("web_service/internal/services")
+
 type LectureService struct {
 	lectureRepo repositories.RepoLecture
 	logger      *zap.SugaredLogger
@@ -27,16 +31,27 @@ func NewLectureService(lectureRepo repositories.RepoLecture, logger *zap.Sugared
 }
 
 func (service *LectureService) CreateLecture(ctx context.Context, createLectureRequest *requests.CreateLectureRequest) (*responses.CreateLectureResponse, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.CreateLecture")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
 	lecture, err := mappers.MapCreateLectureReqToLecture(createLectureRequest)
 	if err != nil {
 		appErr := apperrors.CreateLectureServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
+		logger.Error(appErr)
 		return nil, appErr
 	}
 
-	insertedLectureId, err := service.lectureRepo.CreateLecture(ctx, lecture)
+	lectureCreated, err := mappers.MapLectureToLectureCreatedEvent(lecture)
 	if err != nil {
-		service.logger.Error(err)
+		appErr := apperrors.CreateLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	insertedLectureId, err := service.lectureRepo.CreateLecture(ctx, lecture, lectureCreated)
+	if err != nil {
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -44,10 +59,14 @@ func (service *LectureService) CreateLecture(ctx context.Context, createLectureR
 }
 
 func (service *LectureService) AddUserToLecture(ctx context.Context, lectureId string, userId string) (*responses.AddUserToLecture, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.AddUserToLecture")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
 	userUUID, err := uuid.Parse(userId)
 	if err != nil {
 		appErr := apperrors.AddStudentToLectureServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
+		logger.Error(appErr)
 		return nil, appErr
 	}
 
@@ -56,15 +75,34 @@ func (service *LectureService) AddUserToLecture(ctx context.Context, lectureId s
 	lectureUUID, err := uuid.Parse(lectureId)
 	if err != nil {
 		appErr := apperrors.AddStudentToLectureServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
+		logger.Error(appErr)
 		return nil, appErr
 	}
 
-	lecture := &models.Lecture{ID: &lectureUUID}
+	lecture, err := service.lectureRepo.GetLectureByID(ctx, &lectureUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
 
-	err = service.lectureRepo.AddUserToLecture(ctx, lecture, user)
+	// The repository checks this again, along with the free seats, once the
+	// lecture is locked; this only turns obvious cases away early.
+	if !lecture.IsBookable(time.Now()) {
+		appErr := apperrors.LectureNotBookableErr.AppendMessage("status:", lecture.Status, "date:", lecture.Date)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	studentEnrolled, err := mappers.MapEnrollmentToStudentEnrolledEvent(lecture, user)
 	if err != nil {
-		service.logger.Error(err)
+		appErr := apperrors.AddStudentToLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	err = service.lectureRepo.AddUserToLecture(ctx, lecture, user, studentEnrolled)
+	if err != nil {
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -72,10 +110,14 @@ func (service *LectureService) AddUserToLecture(ctx context.Context, lectureId s
 }
 
 func (service *LectureService) DeleteUserFromLecture(ctx context.Context, lectureId string, userId string) error {
+	ctx, span := tracer.Start(ctx, "LectureService.DeleteUserFromLecture")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
 	userUUID, err := uuid.Parse(userId)
 	if err != nil {
 		appErr := apperrors.DeleteUserFromLectureServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
+		logger.Error(appErr)
 		return appErr
 	}
 
@@ -84,47 +126,271 @@ func (service *LectureService) DeleteUserFromLecture(ctx context.Context, lectur
 	lectureUUID, err := uuid.Parse(lectureId)
 	if err != nil {
 		appErr := apperrors.DeleteUserFromLectureServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
+		logger.Error(appErr)
 		return appErr
 	}
 
 	lecture := &models.Lecture{ID: &lectureUUID}
 
-	err = service.lectureRepo.DropUserFromLecture(ctx, lecture, user)
+	studentDropped, err := mappers.MapEnrollmentToStudentDroppedEvent(lecture, user)
 	if err != nil {
-		service.logger.Error(err)
+		appErr := apperrors.DeleteUserFromLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	err = service.lectureRepo.DropUserFromLecture(ctx, lecture, user, studentDropped)
+	if err != nil {
+		logger.Error(err)
 		return err
 	}
 
 	return nil
 }
 
-func (service *LectureService) GetLecturesAndStudentsPP(ctx context.Context, page string, perPage string) ([]*responses.GetLecturesAndStudentsPPResponse, error) {
-	pageNum, err := strconv.Atoi(page)
+func (service *LectureService) GetLecturesAndStudentsPP(ctx context.Context, getLectsPPRequest *requests.GetLecturesPPRequest) (*responses.GetLecturesPageResponse, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.GetLecturesAndStudentsPP")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	pageRequest, err := parsePageRequest(getLectsPPRequest.Page, getLectsPPRequest.PerPage, getLectsPPRequest.Cursor, getLectsPPRequest.IncludeTotal)
 	if err != nil {
-		appErr := apperrors.GetLecturesPPServiceErr.AppendMessage("strconv err:", page)
-		service.logger.Error(appErr)
-		return nil, appErr
+		logger.Error(err)
+		return nil, err
 	}
 
-	perPageNum, err := strconv.Atoi(perPage)
+	filter, err := mappers.MapGetLecturesPPRequestToLectureFilter(getLectsPPRequest)
 	if err != nil {
-		appErr := apperrors.GetLecturesPPServiceErr.AppendMessage("strconv err:", perPage)
-		service.logger.Error(appErr)
+		appErr := apperrors.InvalidLecturesQueryErr.AppendMessage(err)
+		logger.Error(appErr)
 		return nil, appErr
 	}
-	lecturesAndStudents, err := service.lectureRepo.GetLecturesAndStudentsPP(ctx, pageNum, perPageNum)
+
+	lecturesPage, err := service.lectureRepo.GetLecturesAndStudentsPP(ctx, pageRequest, filter)
 	if err != nil {
-		service.logger.Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
-	getLecturesAndStudentsPPResp, err := mappers.MapGetAllLecturesAndStudentsToGetLecturesAndStudentsPPRespResponse(lecturesAndStudents)
+	getLecturesAndStudentsPPResp, err := mappers.MapGetAllLecturesAndStudentsToGetLecturesAndStudentsPPRespResponse(lecturesPage.Lectures)
 	if err != nil {
 		appErr := apperrors.GetLecturesPPServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return &responses.GetLecturesPageResponse{
+		Lectures:   getLecturesAndStudentsPPResp,
+		PerPage:    pageRequest.PerPage,
+		NextCursor: lecturesPage.NextCursor,
+		PrevCursor: lecturesPage.PrevCursor,
+		TotalCount: lecturesPage.TotalCount,
+	}, nil
+}
+
+func (service *LectureService) GetLectureStudents(ctx context.Context, lectureId string, getStudentsRequest *requests.GetLectureStudentsRequest) (*responses.GetLectureStudentsPageResponse, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.GetLectureStudents")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	lectureUUID, err := uuid.Parse(lectureId)
+	if err != nil {
+		appErr := apperrors.GetLectureStudentsServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	pageRequest, err := parsePageRequest(getStudentsRequest.Page, getStudentsRequest.PerPage, getStudentsRequest.Cursor, getStudentsRequest.IncludeTotal)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	lecture, err := service.lectureRepo.GetLectureByID(ctx, &lectureUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	studentsPage, err := service.lectureRepo.GetLectureStudentsPP(ctx, lecture, pageRequest)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &responses.GetLectureStudentsPageResponse{
+		LectureId:  lecture.ID.String(),
+		Students:   mappers.MapUsersToStudentResponses(studentsPage.Users),
+		PerPage:    pageRequest.PerPage,
+		NextCursor: studentsPage.NextCursor,
+		PrevCursor: studentsPage.PrevCursor,
+		TotalCount: studentsPage.TotalCount,
+	}, nil
+}
+
+func (service *LectureService) ChangeLectureStatus(ctx context.Context, lectureId string, changeStatusRequest *requests.ChangeLectureStatusRequest) (*responses.ChangeLectureStatusResponse, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.ChangeLectureStatus")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	lectureUUID, err := uuid.Parse(lectureId)
+	if err != nil {
+		appErr := apperrors.ChangeLectureStatusServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	nextStatus, ok := models.ParseLectureStatus(changeStatusRequest.Status)
+	if !ok {
+		appErr := apperrors.ChangeLectureStatusServiceErr.AppendMessage("unknown status:", changeStatusRequest.Status)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	if nextStatus == models.LectureStatusCancelled && changeStatusRequest.Reason == "" {
+		appErr := apperrors.ChangeLectureStatusServiceErr.AppendMessage("cancellation reason is required")
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	lecture, err := service.lectureRepo.GetLectureByID(ctx, &lectureUUID)
+	if err != nil {
+		logger.Error(err)
 		return nil, err
 	}
 
-	return getLecturesAndStudentsPPResp, nil
+	currentStatus := lecture.Status
+	if !currentStatus.CanTransitionTo(nextStatus) {
+		appErr := apperrors.LectureStatusTransitionErr.AppendMessage(currentStatus, "->", nextStatus)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	lecture.Status = nextStatus
+	if nextStatus == models.LectureStatusCancelled {
+		lecture.CancelReason = changeStatusRequest.Reason
+		lecture.Students, err = service.lectureRepo.GetLectureStudents(ctx, lecture)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+	}
+
+	lectureUpdated, err := mappers.MapLectureToLectureUpdatedEvent(lecture)
+	if err != nil {
+		appErr := apperrors.ChangeLectureStatusServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	err = service.lectureRepo.UpdateLectureStatus(ctx, lecture, currentStatus, lectureUpdated)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &responses.ChangeLectureStatusResponse{LectureId: lecture.ID.String(), Status: string(lecture.Status)}, nil
+}
+
+func (service *LectureService) GetLecture(ctx context.Context, lectureId string) (*responses.LectureResp, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.GetLecture")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	lectureUUID, err := uuid.Parse(lectureId)
+	if err != nil {
+		appErr := apperrors.GetLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	lecture, err := service.lectureRepo.GetLectureByID(ctx, &lectureUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappers.MapLectureToLectureResponse(lecture), nil
+}
+
+// UpdateLecture edits a lecture, provided its current version satisfies the
+// caller's precondition. The repository re-checks the version on write, so an
+// edit racing this one can't be overwritten either.
+func (service *LectureService) UpdateLecture(ctx context.Context, lectureId string, precondition *models.VersionPrecondition, updateLectureRequest *requests.UpdateLectureRequest) (*responses.LectureResp, error) {
+	ctx, span := tracer.Start(ctx, "LectureService.UpdateLecture")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	lectureUUID, err := uuid.Parse(lectureId)
+	if err != nil {
+		appErr := apperrors.UpdateLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	lecture, err := service.lectureRepo.GetLectureByID(ctx, &lectureUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if !precondition.Matches(lecture.Version) {
+		appErr := apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID, "is at version", lecture.Version)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	if err := mappers.MapUpdateLectureReqToLecture(updateLectureRequest, lecture); err != nil {
+		appErr := apperrors.UpdateLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	lectureUpdated, err := mappers.MapLectureToLectureUpdatedEvent(lecture)
+	if err != nil {
+		appErr := apperrors.UpdateLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	if err := service.lectureRepo.UpdateLecture(ctx, lecture, lectureUpdated); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappers.MapLectureToLectureResponse(lecture), nil
+}
+
+func (service *LectureService) DeleteLecture(ctx context.Context, lectureId string, precondition *models.VersionPrecondition) error {
+	ctx, span := tracer.Start(ctx, "LectureService.DeleteLecture")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	lectureUUID, err := uuid.Parse(lectureId)
+	if err != nil {
+		appErr := apperrors.DeleteLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	lecture, err := service.lectureRepo.GetLectureByID(ctx, &lectureUUID)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if !precondition.Matches(lecture.Version) {
+		appErr := apperrors.StaleVersionErr.AppendMessage("lecture", lecture.ID, "is at version", lecture.Version)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	lectureDeleted, err := mappers.MapLectureToLectureDeletedEvent(lecture)
+	if err != nil {
+		appErr := apperrors.DeleteLectureServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return service.lectureRepo.DeleteLecture(ctx, lecture, lectureDeleted)
 }
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"strconv"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+)
+
+// parsePageRequest validates the raw pagination parameters. per_page above the
+// server maximum is capped rather than rejected.
+func parsePageRequest(page string, perPage string, cursor string, includeTotal string) (*models.PageRequest, error) {
+	pageRequest := &models.PageRequest{Page: 1, PerPage: models.DefaultPerPage, Cursor: cursor}
+	if page != "" {
+		pageNum, err := strconv.Atoi(page)
+		if err != nil || pageNum < 1 {
+			return nil, apperrors.InvalidPageRequestErr.AppendMessage("page must be a positive integer:", page)
+		}
+
+		pageRequest.Page = pageNum
+	}
+
+	if perPage != "" {
+		perPageNum, err := strconv.Atoi(perPage)
+		if err != nil || perPageNum < 1 {
+			return nil, apperrors.InvalidPageRequestErr.AppendMessage("per_page must be a positive integer:", perPage)
+		}
+
+		pageRequest.PerPage = perPageNum
+	}
+
+	if pageRequest.PerPage > models.MaxPerPage {
+		pageRequest.PerPage = models.MaxPerPage
+	}
+
+	if includeTotal != "" {
+		withTotal, err := strconv.ParseBool(includeTotal)
+		if err != nil {
+			return nil, apperrors.InvalidPageRequestErr.AppendMessage("include_total must be a boolean:", includeTotal)
+		}
+
+		pageRequest.WithTotal = withTotal
+	}
+
+	return pageRequest, nil
+}
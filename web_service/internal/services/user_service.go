@@ -2,12 +2,16 @@ package services
 
 import (
 	"context"
+	"time"
 	"web_service/internal/apperrors"
 	"web_service/internal/domain/mappers"
+	"web_service/internal/domain/models"
 	"web_service/internal/domain/requests"
 	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
 	"web_service/internal/repositories"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -25,24 +29,178 @@ func NewUserService(urRepo repositories.UserRepo, logger *zap.SugaredLogger) *Us
 }
 
 func (service *UserService) CreateUser(ctx context.Context, createUserRequest *requests.CreateUserRequest) (*responses.CreateUserResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.CreateUser")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
 	user := mappers.MapCreateUserRequestToUser(createUserRequest)
 	userHashPassword, err := hashPassword(createUserRequest.Password)
 	if err != nil {
 		appErr := apperrors.CreateUserServiceErr.AppendMessage(err)
-		service.logger.Error(appErr)
-		return nil, err
+		logger.Error(appErr)
+		return nil, appErr
 	}
 
 	user.Password = userHashPassword
-	insertedUserID, err := service.userRepo.CreateUser(ctx, user)
+	userCreated, err := mappers.MapUserToUserCreatedEvent(user)
 	if err != nil {
-		service.logger.Error(err)
+		appErr := apperrors.CreateUserServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	insertedUserID, err := service.userRepo.CreateUser(ctx, user, userCreated)
+	if err != nil {
+		logger.Error(err)
 		return nil, err
 	}
 
 	return &responses.CreateUserResponse{UserId: insertedUserID}, nil
 }
 
+func (service *UserService) GetUsers(ctx context.Context, getUsersRequest *requests.GetUsersRequest) (*responses.GetUsersPageResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUsers")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	pageRequest, err := parsePageRequest(getUsersRequest.Page, getUsersRequest.PerPage, getUsersRequest.Cursor, getUsersRequest.IncludeTotal)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	usersPage, err := service.userRepo.GetUsers(ctx, pageRequest)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &responses.GetUsersPageResponse{
+		Users:      mappers.MapUsersToUserResponses(usersPage.Users),
+		PerPage:    pageRequest.PerPage,
+		NextCursor: usersPage.NextCursor,
+		PrevCursor: usersPage.PrevCursor,
+		TotalCount: usersPage.TotalCount,
+	}, nil
+}
+
+func (service *UserService) GetUserLectures(ctx context.Context, userId string, getScheduleRequest *requests.GetUserScheduleRequest) (*responses.GetUserScheduleResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserLectures")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		appErr := apperrors.GetUserLecturesServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	filter, err := mappers.MapGetUserScheduleRequestToScheduleFilter(getScheduleRequest)
+	if err != nil {
+		appErr := apperrors.GetUserLecturesServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, &userUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	lectures, err := service.userRepo.GetUserLectures(ctx, user, filter)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappers.MapUserLecturesToGetUserScheduleResponse(user, lectures, time.Now()), nil
+}
+
+func (service *UserService) GetUser(ctx context.Context, userId string) (*responses.UserResp, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUser")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		appErr := apperrors.GetUserServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, &userUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappers.MapUserToUserResponse(user), nil
+}
+
+// UpdateUser edits a user's profile, provided its current version satisfies
+// the caller's precondition.
+func (service *UserService) UpdateUser(ctx context.Context, userId string, precondition *models.VersionPrecondition, updateUserRequest *requests.UpdateUserRequest) (*responses.UserResp, error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUser")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		appErr := apperrors.UpdateUserServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, &userUUID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if !precondition.Matches(user.Version) {
+		appErr := apperrors.StaleVersionErr.AppendMessage("user", user.ID, "is at version", user.Version)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	mappers.MapUpdateUserReqToUser(updateUserRequest, user)
+	if err := service.userRepo.UpdateUser(ctx, user); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappers.MapUserToUserResponse(user), nil
+}
+
+func (service *UserService) DeleteUser(ctx context.Context, userId string, precondition *models.VersionPrecondition) error {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		appErr := apperrors.DeleteUserServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, &userUUID)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if !precondition.Matches(user.Version) {
+		appErr := apperrors.StaleVersionErr.AppendMessage("user", user.ID, "is at version", user.Version)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return service.userRepo.DeleteUser(ctx, user)
+}
+
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/mappers"
+	"web_service/internal/domain/models"
+	"web_service/internal/domain/requests"
+	"web_service/internal/domain/responses"
+	"web_service/internal/logging"
+	"web_service/internal/repositories"
+	"web_service/internal/webhooks"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type WebhookService struct {
+	webhookRepo repositories.WebhookRepo
+	addresses   *webhooks.Addresses
+	logger      *zap.SugaredLogger
+}
+
+func NewWebhookService(webhookRepo repositories.WebhookRepo, addresses *webhooks.Addresses, logger *zap.SugaredLogger) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		addresses:   addresses,
+		logger:      logger,
+	}
+}
+
+func (service *WebhookService) CreateWebhook(ctx context.Context, createWebhookRequest *requests.CreateWebhookRequest) (*responses.WebhookResp, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	subscription, err := mappers.MapCreateWebhookRequestToWebhookSubscription(createWebhookRequest)
+	if err != nil {
+		appErr := apperrors.CreateWebhookServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	if err := service.addresses.CheckURL(ctx, subscription.URL); err != nil {
+		appErr := apperrors.CreateWebhookServiceErr.AppendMessage("url:", err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	if err := service.webhookRepo.CreateWebhookSubscription(ctx, subscription); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	webhooksResp, err := mappers.MapWebhookSubscriptionsToWebhookResponses([]*models.WebhookSubscription{subscription})
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return webhooksResp[0], nil
+}
+
+func (service *WebhookService) GetWebhooks(ctx context.Context) (*responses.GetWebhooksResponse, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetWebhooks")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	subscriptions, err := service.webhookRepo.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	webhooksResp, err := mappers.MapWebhookSubscriptionsToWebhookResponses(subscriptions)
+	if err != nil {
+		appErr := apperrors.WebhookRepoErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	return &responses.GetWebhooksResponse{Webhooks: webhooksResp}, nil
+}
+
+func (service *WebhookService) DeleteWebhook(ctx context.Context, webhookId string) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	subscriptionID, err := uuid.Parse(webhookId)
+	if err != nil {
+		appErr := apperrors.WebhookServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return appErr
+	}
+
+	return service.webhookRepo.DeleteWebhookSubscription(ctx, &subscriptionID)
+}
+
+// GetWebhookDeliveries lists a subscription's deliveries, newest first, each
+// with its attempt log.
+func (service *WebhookService) GetWebhookDeliveries(ctx context.Context, webhookId string, getWebhookDeliveriesRequest *requests.GetWebhookDeliveriesRequest) (*responses.GetWebhookDeliveriesPageResponse, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetWebhookDeliveries")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	filter, err := mappers.MapGetWebhookDeliveriesRequestToFilter(webhookId, getWebhookDeliveriesRequest)
+	if err != nil {
+		appErr := apperrors.WebhookServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	pageRequest, err := parsePageRequest(getWebhookDeliveriesRequest.Page, getWebhookDeliveriesRequest.PerPage, getWebhookDeliveriesRequest.Cursor, getWebhookDeliveriesRequest.IncludeTotal)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	deliveriesPage, err := service.webhookRepo.GetWebhookDeliveries(ctx, pageRequest, filter)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &responses.GetWebhookDeliveriesPageResponse{
+		Deliveries: mappers.MapWebhookDeliveriesToWebhookDeliveryResponses(deliveriesPage.Deliveries),
+		PerPage:    pageRequest.PerPage,
+		NextCursor: deliveriesPage.NextCursor,
+		PrevCursor: deliveriesPage.PrevCursor,
+		TotalCount: deliveriesPage.TotalCount,
+	}, nil
+}
+
+// RetryWebhookDelivery requeues a dead-lettered delivery for an attempt now.
+func (service *WebhookService) RetryWebhookDelivery(ctx context.Context, deliveryId string) (*responses.WebhookDeliveryResp, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.RetryWebhookDelivery")
+	defer span.End()
+	logger := logging.FromContext(ctx, service.logger)
+
+	deliveryID, err := uuid.Parse(deliveryId)
+	if err != nil {
+		appErr := apperrors.WebhookServiceErr.AppendMessage(err)
+		logger.Error(appErr)
+		return nil, appErr
+	}
+
+	delivery, err := service.webhookRepo.RetryWebhookDelivery(ctx, &deliveryID, time.Now())
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappers.MapWebhookDeliveryToWebhookDeliveryResponse(delivery), nil
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup installs the global tracer provider and the W3C traceparent/baggage
+// propagators. The returned func flushes pending spans and must be called on
+// shutdown. With the "none" exporter spans are still created, so traceparent
+// is propagated, but nothing is exported.
+func Setup(ctx context.Context, cfg *config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, apperrors.TracingSetupErr.AppendMessage(err)
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+
+	switch cfg.Exporter {
+	case ExporterNone, "":
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, apperrors.TracingSetupErr.AppendMessage(err)
+		}
+
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, apperrors.TracingSetupErr.AppendMessage(err)
+		}
+
+		options = append(options, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, apperrors.TracingSetupErr.AppendMessage("unknown exporter", cfg.Exporter)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(tracerProvider)
+	return tracerProvider.Shutdown, nil
+}
@@ -0,0 +1,97 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// nonPublic are the ranges netip has no predicate for that still must not be
+// reached from outside: "this network" and carrier-grade NAT.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// Addresses decides where webhooks may be sent. Unless AllowPrivate is set,
+// only public addresses are allowed, so a subscription can't make the server
+// call the database, a cloud metadata service or anything else on its own
+// network. URLs are checked when they are registered and every connection
+// again when it is dialled, since DNS may answer differently by then.
+type Addresses struct {
+	AllowPrivate bool
+	// Lookup resolves host names; net.DefaultResolver when nil.
+	Lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// CheckURL fails unless every address rawURL's host resolves to is allowed.
+func (addresses *Addresses) CheckURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := parsed.Hostname()
+	ips, err := addresses.lookup(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolving %q: %w", host, err)
+	}
+
+	for _, ip := range ips {
+		if err := addresses.check(ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Control is a net.Dialer Control function refusing connections to addresses
+// that aren't allowed.
+func (addresses *Addresses) Control(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	return addresses.check(addrPort.Addr())
+}
+
+func (addresses *Addresses) lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+
+	if addresses.Lookup != nil {
+		return addresses.Lookup(ctx, host)
+	}
+
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+func (addresses *Addresses) check(ip netip.Addr) error {
+	if addresses.AllowPrivate || isPublic(ip) {
+		return nil
+	}
+
+	return fmt.Errorf("%s is not a public address", ip)
+}
+
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	// Global unicast already leaves out loopback, link-local and multicast.
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublic {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
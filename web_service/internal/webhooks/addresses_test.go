@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+	"web_service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublic(t *testing.T) {
+	testTable := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"127.8.8.8", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"fc00::1", false},
+		{"fd12:3456:789a::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.address, func(t *testing.T) {
+			assert.Equal(t, tc.public, isPublic(netip.MustParseAddr(tc.address)))
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	lookup := func(_ context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "partner.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		case "rebinding.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.5")}, nil
+		default:
+			return nil, errors.New("no such host")
+		}
+	}
+
+	testTable := []struct {
+		scenario     string
+		url          string
+		allowPrivate bool
+		allowed      bool
+	}{
+		{scenario: "public host", url: "https://partner.example.com/hooks", allowed: true},
+		{scenario: "any private answer", url: "https://rebinding.example.com/hooks"},
+		{scenario: "unknown host", url: "https://nowhere.example.com/hooks"},
+		{scenario: "loopback literal", url: "http://127.0.0.1:5432/"},
+		{scenario: "IPv6 loopback literal", url: "http://[::1]/"},
+		{scenario: "metadata service", url: "http://169.254.169.254/latest/meta-data/"},
+		{scenario: "IPv6 link-local literal", url: "http://[fe80::1]/"},
+		{scenario: "private network", url: "http://192.168.0.10/hooks"},
+		{scenario: "IPv6 unique local", url: "http://[fd00::10]/hooks"},
+		{scenario: "private network allowed", url: "http://192.168.0.10/hooks", allowPrivate: true, allowed: true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			addresses := &Addresses{AllowPrivate: tc.allowPrivate, Lookup: lookup}
+			err := addresses.CheckURL(context.Background(), tc.url)
+			if tc.allowed {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestControl(t *testing.T) {
+	addresses := &Addresses{}
+	assert.NoError(t, addresses.Control("tcp4", "93.184.216.34:443", nil))
+	assert.Error(t, addresses.Control("tcp4", "127.0.0.1:5432", nil))
+	assert.Error(t, addresses.Control("tcp6", "[fe80::1]:443", nil))
+	assert.Error(t, addresses.Control("tcp4", "not-an-address", nil))
+}
+
+func TestClientGuards(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer target.Close()
+
+	t.Run("private address is never dialled", func(t *testing.T) {
+		client := newClient(&config.WebhooksConfig{Timeout: time.Second})
+		_, err := client.Post(target.URL, "application/json", nil)
+		assert.ErrorContains(t, err, "is not a public address")
+	})
+
+	t.Run("redirect is not followed", func(t *testing.T) {
+		client := newClient(&config.WebhooksConfig{Timeout: time.Second, AllowPrivateNetworks: true})
+		response, err := client.Post(target.URL, "application/json", nil)
+		if assert.NoError(t, err) {
+			defer response.Body.Close()
+			assert.Equal(t, http.StatusFound, response.StatusCode)
+		}
+	})
+}
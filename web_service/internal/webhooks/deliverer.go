@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+	"web_service/internal/apperrors"
+	"web_service/internal/config"
+	"web_service/internal/domain/models"
+	"web_service/internal/outbox"
+	"web_service/internal/repositories"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Deliverer POSTs queued deliveries to their subscriptions and logs every
+// attempt. Any status outside 2xx is a failure: the delivery is retried with
+// exponential backoff and dead-lettered once it has used up its attempts.
+type Deliverer struct {
+	repo   repositories.WebhookRepo
+	client *http.Client
+	logger *zap.SugaredLogger
+	cfg    *config.WebhooksConfig
+}
+
+func NewDeliverer(repo repositories.WebhookRepo, cfg *config.WebhooksConfig, logger *zap.SugaredLogger) *Deliverer {
+	return &Deliverer{repo: repo, client: newClient(cfg), logger: logger, cfg: cfg}
+}
+
+// newClient dials only the addresses cfg allows, without a proxy that would
+// dial on its behalf, and doesn't follow redirects: a 3xx is a failed
+// attempt, not a way to reach another host.
+func newClient(cfg *config.WebhooksConfig) *http.Client {
+	addresses := &Addresses{AllowPrivate: cfg.AllowPrivateNetworks}
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: addresses.Control}
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: cfg.Timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run delivers due deliveries every poll interval until ctx is done.
+func (deliverer *Deliverer) Run(ctx context.Context) {
+	poll := time.NewTicker(deliverer.cfg.PollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			for ctx.Err() == nil {
+				claimed, err := deliverer.DeliverOnce(ctx)
+				if err != nil || claimed < deliverer.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// DeliverOnce claims one batch of due deliveries and attempts each of them. It
+// returns how many were claimed, whether or not they went through, and the
+// first error recording an attempt; such a delivery is attempted again once
+// its lease runs out.
+func (deliverer *Deliverer) DeliverOnce(ctx context.Context) (int, error) {
+	deliveries, err := deliverer.repo.ClaimWebhookDeliveries(ctx, time.Now(), deliverer.cfg.BatchSize, deliverer.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var recordErr error
+	for _, delivery := range deliveries {
+		if err := deliverer.attempt(ctx, delivery); err != nil && recordErr == nil {
+			recordErr = err
+		}
+	}
+
+	return len(deliveries), recordErr
+}
+
+// attempt posts delivery once. Attempts are numbered across manual retries,
+// while the attempt limit and the backoff count only those since the last one.
+func (deliverer *Deliverer) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	start := time.Now()
+	statusCode, err := deliverer.post(ctx, delivery, start)
+	id := uuid.New()
+	delivery.Attempts++
+	attempt := &models.WebhookDeliveryAttempt{
+		ID:          &id,
+		DeliveryID:  delivery.ID,
+		Attempt:     delivery.Attempts,
+		AttemptedAt: start,
+		StatusCode:  statusCode,
+		DurationMs:  time.Since(start).Milliseconds(),
+	}
+
+	switch {
+	case err == nil:
+		deliveredAt := time.Now()
+		delivery.Status, delivery.DeliveredAt, delivery.LastError = models.WebhookDeliveryDelivered, &deliveredAt, ""
+	case delivery.Attempts-delivery.AttemptsBeforeRetry >= deliverer.cfg.MaxAttempts:
+		attempt.Error, delivery.LastError = err.Error(), err.Error()
+		delivery.Status = models.WebhookDeliveryDead
+		deliverer.logger.Errorf("Webhook delivery %s of %s event %s is dead after %d attempts: %v", delivery.ID, delivery.EventType, delivery.EventID, delivery.Attempts, err)
+	default:
+		attempt.Error, delivery.LastError = err.Error(), err.Error()
+		delivery.NextAttemptAt = time.Now().Add(outbox.Backoff(delivery.Attempts-delivery.AttemptsBeforeRetry, deliverer.cfg.RetryBackoff, deliverer.cfg.RetryBackoffMax))
+		deliverer.logger.Warnf("Webhook delivery %s of %s event %s failed, attempt %d, retrying at %v: %v", delivery.ID, delivery.EventType, delivery.EventID, delivery.Attempts, delivery.NextAttemptAt, err)
+	}
+
+	if err := deliverer.repo.RecordWebhookDeliveryAttempt(ctx, delivery, attempt); err != nil {
+		deliverer.logger.Errorf("Recording attempt %d of webhook delivery %s, it will be attempted again: %v", attempt.Attempt, delivery.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// post sends the delivery once and returns the response status, 0 when there
+// was no response.
+func (deliverer *Deliverer) post(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Subscription.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, apperrors.WebhookDeliveryErr.AppendMessage(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(outbox.EventIDHeader, delivery.EventID.String())
+	req.Header.Set(outbox.EventTypeHeader, delivery.EventType)
+	req.Header.Set(DeliveryIDHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(delivery.Subscription.Secret, now, delivery.Body))
+	resp, err := deliverer.client.Do(req)
+	if err != nil {
+		return 0, apperrors.WebhookDeliveryErr.AppendMessage(err)
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, apperrors.WebhookDeliveryErr.AppendMessage(delivery.Subscription.URL, "answered", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,30 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"web_service/internal/apperrors"
+	"web_service/internal/domain/models"
+	"web_service/internal/outbox"
+	"web_service/internal/repositories"
+)
+
+// Dispatcher is the outbox publisher for webhooks: it queues a delivery of the
+// event to every subscription asking for its type. The Deliverer sends them.
+type Dispatcher struct {
+	repo repositories.WebhookRepo
+}
+
+func NewDispatcher(repo repositories.WebhookRepo) *Dispatcher {
+	return &Dispatcher{repo: repo}
+}
+
+func (dispatcher *Dispatcher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	body, err := json.Marshal(outbox.NewMessage(event))
+	if err != nil {
+		return apperrors.WebhookDeliveryErr.AppendMessage(err)
+	}
+
+	_, err = dispatcher.repo.EnqueueWebhookDeliveries(ctx, event, body)
+	return err
+}
@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"web_service/internal/apperrors"
+)
+
+const (
+	SignatureHeader  = "X-Webhook-Signature"
+	DeliveryIDHeader = "X-Webhook-Delivery-ID"
+)
+
+// Sign computes the signature header of a request carrying body:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed with secret>".
+// Signing the timestamp along with the body lets receivers reject replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, signature(secret, t, body))
+}
+
+// Verify checks a signature header made by Sign against body, and that it was
+// made no more than tolerance away from now.
+func Verify(secret string, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || v1 == "" {
+		return apperrors.WebhookSignatureErr.AppendMessage("malformed header", header)
+	}
+
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return apperrors.WebhookSignatureErr.AppendMessage("timestamp outside tolerance", t)
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return apperrors.WebhookSignatureErr.AppendMessage("signature mismatch")
+	}
+
+	return nil
+}
+
+func signature(secret string, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
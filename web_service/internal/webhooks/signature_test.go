@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+	"web_service/internal/apperrors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// Computed independently: HMAC-SHA256 of `1700000000.{"event":"LectureCreated"}`
+	// keyed with "whsec-test-secret". Receivers in other languages check
+	// against the same value.
+	header := Sign("whsec-test-secret", time.Unix(1700000000, 0), []byte(`{"event":"LectureCreated"}`))
+	assert.Equal(t, "t=1700000000,v1=31f3862b60bb55ce58de0b78a0617d5963bde4177c730f8a9f7a6168b308f15d", header)
+}
+
+func TestVerify(t *testing.T) {
+	secret := "whsec-test-secret"
+	body := []byte(`{"event":"LectureCreated"}`)
+	signedAt := time.Unix(1700000000, 0)
+	header := Sign(secret, signedAt, body)
+
+	testTable := []struct {
+		scenario string
+		secret   string
+		header   string
+		body     []byte
+		now      time.Time
+		valid    bool
+	}{
+		{scenario: "valid", secret: secret, header: header, body: body, now: signedAt.Add(time.Minute), valid: true},
+		{scenario: "fields in any order with spaces", secret: secret, header: "v1=31f3862b60bb55ce58de0b78a0617d5963bde4177c730f8a9f7a6168b308f15d, t=1700000000", body: body, now: signedAt, valid: true},
+		{scenario: "other secret", secret: "whsec-other-secret", header: header, body: body, now: signedAt},
+		{scenario: "tampered body", secret: secret, header: header, body: []byte(`{"event":"LectureDeleted"}`), now: signedAt},
+		{scenario: "replayed too late", secret: secret, header: header, body: body, now: signedAt.Add(6 * time.Minute)},
+		{scenario: "from the future", secret: secret, header: header, body: body, now: signedAt.Add(-6 * time.Minute)},
+		{scenario: "timestamp swapped", secret: secret, header: "t=1700000060,v1=31f3862b60bb55ce58de0b78a0617d5963bde4177c730f8a9f7a6168b308f15d", body: body, now: signedAt},
+		{scenario: "missing signature", secret: secret, header: "t=1700000000", body: body, now: signedAt},
+		{scenario: "malformed timestamp", secret: secret, header: "t=soon,v1=31f3862b60bb55ce58de0b78a0617d5963bde4177c730f8a9f7a6168b308f15d", body: body, now: signedAt},
+		{scenario: "empty header", secret: secret, body: body, now: signedAt},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.scenario, func(t *testing.T) {
+			err := Verify(tc.secret, tc.header, tc.body, tc.now, 5*time.Minute)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.True(t, apperrors.IsAppError(err, &apperrors.WebhookSignatureErr), "%v", err)
+		})
+	}
+}